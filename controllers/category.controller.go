@@ -0,0 +1,331 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetGroupCategories godoc
+// @Summary      Get Group Categories
+// @Description  lists the categories a group's expenses can be filed under, built-in ones first
+// @Tags         categories
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Group ID"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /groups/{id}/categories [get]
+// @Security     ApiKeyAuth
+func GetGroupCategories(c *gin.Context) {
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	groupId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		response.Message = "invalid group id"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	categories, err := transactionService.GetGroupCategories(groupId, userId.(primitive.ObjectID))
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Data = gin.H{"categories": categories}
+	response.SendResponse(c)
+}
+
+// CreateCategory godoc
+// @Summary      Create Category
+// @Description  adds a category of the group's own
+// @Tags         categories
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Group ID"
+// @Param        req  body      models.CreateCategoryRequest  true  "Category Request"
+// @Success      201  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /groups/{id}/categories [post]
+// @Security     ApiKeyAuth
+func CreateCategory(c *gin.Context) {
+	var requestBody models.CreateCategoryRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	groupId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		response.Message = "invalid group id"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	category, err := transactionService.CreateCategory(groupId, userId.(primitive.ObjectID), requestBody)
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusCreated
+	response.Success = true
+	response.Data = gin.H{"category": category.Info()}
+	response.Message = "Category created successfully"
+	response.SendResponse(c)
+}
+
+// UpdateCategory godoc
+// @Summary      Update Category
+// @Description  renames a group's own category or changes its icon
+// @Tags         categories
+// @Accept       json
+// @Produce      json
+// @Param        id          path      string  true  "Group ID"
+// @Param        categoryId  path      string  true  "Category ID"
+// @Param        req         body      models.UpdateCategoryRequest  true  "Category Request"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /groups/{id}/categories/{categoryId} [put]
+// @Security     ApiKeyAuth
+func UpdateCategory(c *gin.Context) {
+	var requestBody models.UpdateCategoryRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	groupId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		response.Message = "invalid group id"
+		response.SendResponse(c)
+		return
+	}
+
+	categoryId, err := primitive.ObjectIDFromHex(c.Param("categoryId"))
+	if err != nil {
+		response.Message = "built-in categories cannot be changed"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	category, err := transactionService.UpdateCategory(groupId, categoryId, userId.(primitive.ObjectID), requestBody)
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Data = gin.H{"category": category.Info()}
+	response.Message = "Category updated successfully"
+	response.SendResponse(c)
+}
+
+// DeleteCategory godoc
+// @Summary      Delete Category
+// @Description  removes a group's own category, moving its expenses to General
+// @Tags         categories
+// @Accept       json
+// @Produce      json
+// @Param        id          path      string  true  "Group ID"
+// @Param        categoryId  path      string  true  "Category ID"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /groups/{id}/categories/{categoryId} [delete]
+// @Security     ApiKeyAuth
+func DeleteCategory(c *gin.Context) {
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	groupId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		response.Message = "invalid group id"
+		response.SendResponse(c)
+		return
+	}
+
+	categoryId, err := primitive.ObjectIDFromHex(c.Param("categoryId"))
+	if err != nil {
+		response.Message = "built-in categories cannot be deleted"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	if err := transactionService.DeleteCategory(groupId, categoryId, userId.(primitive.ObjectID)); err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Message = "Category deleted successfully"
+	response.SendResponse(c)
+}
+
+// GetCategoryRules godoc
+// @Summary      Get Category Rules
+// @Description  lists the rules that pick the category of the user's expenses, in the order they run
+// @Tags         categories
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /users/me/category-rules [get]
+// @Security     ApiKeyAuth
+func GetCategoryRules(c *gin.Context) {
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	rules, err := transactionService.GetCategoryRules(userId.(primitive.ObjectID))
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Data = gin.H{"rules": rules}
+	response.SendResponse(c)
+}
+
+// CreateCategoryRule godoc
+// @Summary      Create Category Rule
+// @Description  files the user's expenses created without a category under one when their description matches a pattern
+// @Tags         categories
+// @Accept       json
+// @Produce      json
+// @Param        req  body      models.CreateCategoryRuleRequest  true  "Category Rule Request"
+// @Success      201  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /users/me/category-rules [post]
+// @Security     ApiKeyAuth
+func CreateCategoryRule(c *gin.Context) {
+	var requestBody models.CreateCategoryRuleRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	rule, err := transactionService.CreateCategoryRule(userId.(primitive.ObjectID), requestBody)
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusCreated
+	response.Success = true
+	response.Data = gin.H{"rule": rule}
+	response.Message = "Category rule created successfully"
+	response.SendResponse(c)
+}
+
+// DeleteCategoryRule godoc
+// @Summary      Delete Category Rule
+// @Description  removes one of the user's category rules
+// @Tags         categories
+// @Accept       json
+// @Produce      json
+// @Param        ruleId  path      string  true  "Rule ID"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /users/me/category-rules/{ruleId} [delete]
+// @Security     ApiKeyAuth
+func DeleteCategoryRule(c *gin.Context) {
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	ruleId, err := primitive.ObjectIDFromHex(c.Param("ruleId"))
+	if err != nil {
+		response.Message = "invalid rule id"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	if err := transactionService.DeleteCategoryRule(ruleId, userId.(primitive.ObjectID)); err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Message = "Category rule deleted successfully"
+	response.SendResponse(c)
+}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models"
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ImportExchangeRates godoc
+// @Summary      Import Exchange Rates
+// @Description  loads daily exchange rates from a CSV or ECB-style XML file (admin only)
+// @Tags         exchange-rates
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        file    formData  file    true   "Rates file"
+// @Param        format  formData  string  false  "csv or xml, defaults to the file extension"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Failure      403  {object}  models.Response
+// @Router       /admin/exchange-rates/import [post]
+// @Security     ApiKeyAuth
+func ImportExchangeRates(c *gin.Context) {
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		response.Message = "rates file is required"
+		response.SendResponse(c)
+		return
+	}
+
+	format := c.PostForm("format")
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(fileHeader.Filename)), ".")
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		response.Message = "cannot read rates file"
+		response.SendResponse(c)
+		return
+	}
+	defer file.Close()
+
+	imported, err := services.ImportExchangeRates(file, format)
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Data = gin.H{"imported": imported}
+	response.Message = "Exchange rates imported successfully"
+	response.SendResponse(c)
+}
+
+// GetExchangeRate godoc
+// @Summary      Get Exchange Rate
+// @Description  gets the rate between two currencies on a date, falling back to the nearest earlier date
+// @Tags         exchange-rates
+// @Accept       json
+// @Produce      json
+// @Param        from  query  string  true   "Currency to convert from"
+// @Param        to    query  string  true   "Currency to convert to"
+// @Param        date  query  string  false  "Date (YYYY-MM-DD), defaults to today"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /exchange-rates [get]
+// @Security     ApiKeyAuth
+func GetExchangeRate(c *gin.Context) {
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	from := strings.ToUpper(c.Query("from"))
+	to := strings.ToUpper(c.Query("to"))
+	if len(from) != 3 || len(to) != 3 {
+		response.Message = "from and to must be 3-letter currency codes"
+		response.SendResponse(c)
+		return
+	}
+
+	date := time.Now()
+	if dateQuery := c.Query("date"); dateQuery != "" {
+		parsed, err := time.Parse("2006-01-02", dateQuery)
+		if err != nil {
+			response.Message = "invalid date, expected YYYY-MM-DD"
+			response.SendResponse(c)
+			return
+		}
+		date = parsed
+	}
+
+	if services.Rates == nil {
+		response.Message = "exchange rates are not available"
+		response.SendResponse(c)
+		return
+	}
+
+	rate, err := services.Rates.Rate(from, to, date)
+	if err != nil {
+		response.StatusCode = http.StatusNotFound
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Data = gin.H{
+		"from": from,
+		"to":   to,
+		"date": date.Format("2006-01-02"),
+		"rate": rate,
+	}
+	response.SendResponse(c)
+}
@@ -2,7 +2,6 @@ package controllers
 
 import (
 	"net/http"
-	"strconv"
 
 	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models"
 	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/services"
@@ -38,7 +37,7 @@ func CreateGroup(c *gin.Context) {
 		return
 	}
 
-	group, err := services.CreateGroup(requestBody.Name, requestBody.Description, requestBody.Currency, userId.(primitive.ObjectID), requestBody.MemberIDs)
+	group, err := services.CreateGroup(requestBody.Name, requestBody.Description, requestBody.Currency, requestBody.Timezone, userId.(primitive.ObjectID), requestBody.MemberIDs)
 	if err != nil {
 		response.Message = err.Error()
 		response.SendResponse(c)
@@ -58,6 +57,7 @@ func CreateGroup(c *gin.Context) {
 // @Accept       json
 // @Produce      json
 // @Param        page  query    string  false  "Switch page by 'page'"
+// @Param        limit query    int     false  "Items per page (default: 10)"
 // @Success      200  {object}  models.Response
 // @Failure      400  {object}  models.Response
 // @Router       /groups [get]
@@ -75,9 +75,8 @@ func GetUserGroups(c *gin.Context) {
 		return
 	}
 
-	pageQuery := c.DefaultQuery("page", "0")
-	page, _ := strconv.Atoi(pageQuery)
-	limit := 10
+	pageQuery := parsePageQuery(c, 10)
+	page, limit := pageQuery.Page, pageQuery.Limit
 
 	groups, err := services.GetUserGroups(userId.(primitive.ObjectID), page, limit)
 	if err != nil {
@@ -379,7 +378,7 @@ func UpdateGroup(c *gin.Context) {
 		return
 	}
 
-	updatedGroup, err := services.UpdateGroup(groupId, userId.(primitive.ObjectID), requestBody.Name, requestBody.Description, requestBody.Currency)
+	updatedGroup, err := services.UpdateGroup(groupId, userId.(primitive.ObjectID), requestBody.Name, requestBody.Description, requestBody.Currency, requestBody.Timezone)
 	if err != nil {
 		response.Message = err.Error()
 		response.SendResponse(c)
@@ -392,3 +391,100 @@ func UpdateGroup(c *gin.Context) {
 	response.Message = "Group updated successfully"
 	response.SendResponse(c)
 }
+
+// PinGroupExchangeRate godoc
+// @Summary      Pin Group Exchange Rate
+// @Description  pins the rate used to convert a currency into the group currency
+// @Tags         groups
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Group ID"
+// @Param        req  body      models.PinExchangeRateRequest true "Pin Exchange Rate Request"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /groups/{id}/exchange-rates [put]
+// @Security     ApiKeyAuth
+func PinGroupExchangeRate(c *gin.Context) {
+	var requestBody models.PinExchangeRateRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	idHex := c.Param("id")
+	groupId, err := primitive.ObjectIDFromHex(idHex)
+	if err != nil {
+		response.Message = "invalid group id"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	group, err := services.PinGroupExchangeRate(groupId, userId.(primitive.ObjectID), requestBody.Currency, requestBody.Rate)
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Data = gin.H{"group": group}
+	response.Message = "Exchange rate pinned successfully"
+	response.SendResponse(c)
+}
+
+// UnpinGroupExchangeRate godoc
+// @Summary      Unpin Group Exchange Rate
+// @Description  removes a pinned exchange rate from a group
+// @Tags         groups
+// @Accept       json
+// @Produce      json
+// @Param        id        path      string  true  "Group ID"
+// @Param        currency  path      string  true  "Currency"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /groups/{id}/exchange-rates/{currency} [delete]
+// @Security     ApiKeyAuth
+func UnpinGroupExchangeRate(c *gin.Context) {
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	idHex := c.Param("id")
+	groupId, err := primitive.ObjectIDFromHex(idHex)
+	if err != nil {
+		response.Message = "invalid group id"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	group, err := services.UnpinGroupExchangeRate(groupId, userId.(primitive.ObjectID), c.Param("currency"))
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Data = gin.H{"group": group}
+	response.Message = "Exchange rate unpinned successfully"
+	response.SendResponse(c)
+}
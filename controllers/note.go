@@ -7,7 +7,6 @@ import (
 	"github.com/gin-gonic/gin/binding"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"net/http"
-	"strconv"
 )
 
 // CreateNewNote godoc
@@ -57,6 +56,7 @@ func CreateNewNote(c *gin.Context) {
 // @Accept       json
 // @Produce      json
 // @Param        page  query    string  false  "Switch page by 'page'"
+// @Param        limit query    int     false  "Items per page (default: 5)"
 // @Success      200  {object}  models.Response
 // @Failure      400  {object}  models.Response
 // @Router       /notes [get]
@@ -74,9 +74,8 @@ func GetNotes(c *gin.Context) {
 		return
 	}
 
-	pageQuery := c.DefaultQuery("page", "0")
-	page, _ := strconv.Atoi(pageQuery)
-	limit := 5
+	pageQuery := parsePageQuery(c, 5)
+	page, limit := pageQuery.Page, pageQuery.Limit
 
 	notes, _ := services.GetNotes(userId.(primitive.ObjectID), page, limit)
 	hasPrev := page > 0
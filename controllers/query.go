@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models"
+	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxPageLimit is the most items a client can ask for in one page
+const maxPageLimit = 100
+
+// parsePageQuery reads the cursor, page and limit query parameters of list endpoints.
+// Missing or out of range values fall back to the first page and defaultLimit.
+func parsePageQuery(c *gin.Context, defaultLimit int) models.PageQuery {
+	query := models.PageQuery{Cursor: c.Query("cursor"), Limit: defaultLimit}
+
+	if p, err := strconv.Atoi(c.Query("page")); err == nil && p >= 0 {
+		query.Page = p
+	}
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= maxPageLimit {
+		query.Limit = l
+	}
+
+	return query
+}
+
+// parseTransactionQuery reads the paging and filter query parameters of transaction lists
+func parseTransactionQuery(c *gin.Context) (models.TransactionQuery, error) {
+	query := models.TransactionQuery{
+		PageQuery: parsePageQuery(c, 20),
+		Type:      c.Query("type"),
+		From:      c.Query("from"),
+		To:        c.Query("to"),
+		Timezone:  c.Query("timezone"),
+		Category:  c.Query("category"),
+		Search:    c.Query("q"),
+	}
+
+	var err error
+	if query.PayerID, err = queryObjectID(c, "payer"); err != nil {
+		return query, err
+	}
+	if query.ParticipantID, err = queryObjectID(c, "participant"); err != nil {
+		return query, err
+	}
+	if query.MinAmount, err = queryMoney(c, "min_amount"); err != nil {
+		return query, err
+	}
+	if query.MaxAmount, err = queryMoney(c, "max_amount"); err != nil {
+		return query, err
+	}
+
+	if value := c.Query("completed"); value != "" {
+		completed, err := strconv.ParseBool(value)
+		if err != nil {
+			return query, errors.New("completed must be true or false")
+		}
+		query.Completed = &completed
+	}
+
+	return query, nil
+}
+
+// queryObjectID reads an optional ID query parameter
+func queryObjectID(c *gin.Context, key string) (primitive.ObjectID, error) {
+	value := c.Query(key)
+	if value == "" {
+		return primitive.NilObjectID, nil
+	}
+	id, err := primitive.ObjectIDFromHex(value)
+	if err != nil {
+		return primitive.NilObjectID, errors.New("invalid " + key + " id")
+	}
+	return id, nil
+}
+
+// queryMoney reads an optional amount query parameter in minor units
+func queryMoney(c *gin.Context, key string) (*db.Money, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+	amount, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || amount < 0 {
+		return nil, errors.New(key + " must be a whole amount in minor units")
+	}
+	money := db.Money(amount)
+	return &money, nil
+}
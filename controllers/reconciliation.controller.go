@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models"
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/services"
+	"github.com/gin-gonic/gin"
+)
+
+// GetReconciliationReport godoc
+// @Summary      Get Reconciliation Report
+// @Description  gets the report of the latest balance reconciliation run (admin only)
+// @Tags         reconciliation
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Failure      403  {object}  models.Response
+// @Router       /admin/reconciliation [get]
+// @Security     ApiKeyAuth
+func GetReconciliationReport(c *gin.Context) {
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	report, err := services.LatestReconciliationReport()
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Data = gin.H{"report": report}
+	response.SendResponse(c)
+}
+
+// RunReconciliation godoc
+// @Summary      Run Reconciliation
+// @Description  reconciles every group's balances against its transactions now, repairing them only if RECONCILE_AUTO_REPAIR is on (admin only)
+// @Tags         reconciliation
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Failure      403  {object}  models.Response
+// @Router       /admin/reconciliation [post]
+// @Security     ApiKeyAuth
+func RunReconciliation(c *gin.Context) {
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	report, err := services.RunReconciliation(services.Config.ReconcileAutoRepair)
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Data = gin.H{"report": report}
+	response.Message = "Balances reconciled"
+	response.SendResponse(c)
+}
@@ -0,0 +1,408 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CreateRecurringExpense godoc
+// @Summary      Create Recurring Expense
+// @Description  stores an expense template that is added to the group on a daily, weekly, monthly or yearly schedule
+// @Tags         recurring-expenses
+// @Accept       json
+// @Produce      json
+// @Param        req  body      models.CreateRecurringExpenseRequest  true  "Recurring Expense Request"
+// @Success      201  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /recurring-expenses [post]
+// @Security     ApiKeyAuth
+func CreateRecurringExpense(c *gin.Context) {
+	var requestBody models.CreateRecurringExpenseRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	recurring, err := transactionService.CreateRecurringExpense(userId.(primitive.ObjectID), requestBody)
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusCreated
+	response.Success = true
+	response.Data = gin.H{"recurring_expense": recurring}
+	response.Message = "Recurring expense created successfully"
+	response.SendResponse(c)
+}
+
+// GetGroupRecurringExpenses godoc
+// @Summary      Get Group Recurring Expenses
+// @Description  lists a group's recurring expenses, soonest occurrence first
+// @Tags         recurring-expenses
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Group ID"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /groups/{id}/recurring-expenses [get]
+// @Security     ApiKeyAuth
+func GetGroupRecurringExpenses(c *gin.Context) {
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	groupIdHex := c.Param("id")
+	groupId, err := primitive.ObjectIDFromHex(groupIdHex)
+	if err != nil {
+		response.Message = "invalid group id"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	recurring, err := transactionService.GetGroupRecurringExpenses(groupId, userId.(primitive.ObjectID))
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Data = gin.H{"recurring_expenses": recurring}
+	response.SendResponse(c)
+}
+
+// GetRecurringExpense godoc
+// @Summary      Get Recurring Expense
+// @Description  gets a single recurring expense by ID
+// @Tags         recurring-expenses
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Recurring Expense ID"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /recurring-expenses/{id} [get]
+// @Security     ApiKeyAuth
+func GetRecurringExpense(c *gin.Context) {
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	idHex := c.Param("id")
+	recurringId, err := primitive.ObjectIDFromHex(idHex)
+	if err != nil {
+		response.Message = "invalid recurring expense id"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	recurring, err := transactionService.GetRecurringExpense(recurringId, userId.(primitive.ObjectID))
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Data = gin.H{"recurring_expense": recurring}
+	response.SendResponse(c)
+}
+
+// UpdateRecurringExpense godoc
+// @Summary      Update Recurring Expense
+// @Description  changes the template or schedule of a recurring expense; expenses already created are not changed
+// @Tags         recurring-expenses
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Recurring Expense ID"
+// @Param        req  body      models.UpdateRecurringExpenseRequest  true  "Update Request"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /recurring-expenses/{id} [put]
+// @Security     ApiKeyAuth
+func UpdateRecurringExpense(c *gin.Context) {
+	var requestBody models.UpdateRecurringExpenseRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	idHex := c.Param("id")
+	recurringId, err := primitive.ObjectIDFromHex(idHex)
+	if err != nil {
+		response.Message = "invalid recurring expense id"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	recurring, err := transactionService.UpdateRecurringExpense(recurringId, userId.(primitive.ObjectID), requestBody)
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Data = gin.H{"recurring_expense": recurring}
+	response.Message = "Recurring expense updated successfully"
+	response.SendResponse(c)
+}
+
+// PauseRecurringExpense godoc
+// @Summary      Pause Recurring Expense
+// @Description  stops a recurring expense from creating expenses until it is resumed
+// @Tags         recurring-expenses
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Recurring Expense ID"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /recurring-expenses/{id}/pause [post]
+// @Security     ApiKeyAuth
+func PauseRecurringExpense(c *gin.Context) {
+	setRecurringExpensePaused(c, true)
+}
+
+// ResumeRecurringExpense godoc
+// @Summary      Resume Recurring Expense
+// @Description  resumes a paused recurring expense from its next occurrence; occurrences missed while paused are not created
+// @Tags         recurring-expenses
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Recurring Expense ID"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /recurring-expenses/{id}/resume [post]
+// @Security     ApiKeyAuth
+func ResumeRecurringExpense(c *gin.Context) {
+	setRecurringExpensePaused(c, false)
+}
+
+func setRecurringExpensePaused(c *gin.Context, paused bool) {
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	idHex := c.Param("id")
+	recurringId, err := primitive.ObjectIDFromHex(idHex)
+	if err != nil {
+		response.Message = "invalid recurring expense id"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	recurring, err := transactionService.SetRecurringExpensePaused(recurringId, userId.(primitive.ObjectID), paused)
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Data = gin.H{"recurring_expense": recurring}
+	if paused {
+		response.Message = "Recurring expense paused"
+	} else {
+		response.Message = "Recurring expense resumed"
+	}
+	response.SendResponse(c)
+}
+
+// SkipRecurringOccurrence godoc
+// @Summary      Skip Recurring Occurrence
+// @Description  stops the occurrence of a recurring expense on a given day from being created
+// @Tags         recurring-expenses
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Recurring Expense ID"
+// @Param        req  body      models.SkipRecurringOccurrenceRequest  true  "Skip Request"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /recurring-expenses/{id}/skip [post]
+// @Security     ApiKeyAuth
+func SkipRecurringOccurrence(c *gin.Context) {
+	var requestBody models.SkipRecurringOccurrenceRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	idHex := c.Param("id")
+	recurringId, err := primitive.ObjectIDFromHex(idHex)
+	if err != nil {
+		response.Message = "invalid recurring expense id"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	recurring, err := transactionService.SkipRecurringOccurrence(recurringId, userId.(primitive.ObjectID), requestBody.Date)
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Data = gin.H{"recurring_expense": recurring}
+	response.Message = "Occurrence skipped"
+	response.SendResponse(c)
+}
+
+// GetUpcomingOccurrences godoc
+// @Summary      Get Upcoming Occurrences
+// @Description  lists the next occurrences of a recurring expense, with skipped ones marked
+// @Tags         recurring-expenses
+// @Accept       json
+// @Produce      json
+// @Param        id     path      string  true   "Recurring Expense ID"
+// @Param        count  query     int     false  "Number of occurrences (default: 10, max: 100)"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /recurring-expenses/{id}/upcoming [get]
+// @Security     ApiKeyAuth
+func GetUpcomingOccurrences(c *gin.Context) {
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	idHex := c.Param("id")
+	recurringId, err := primitive.ObjectIDFromHex(idHex)
+	if err != nil {
+		response.Message = "invalid recurring expense id"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	count := 10
+	if countStr := c.Query("count"); countStr != "" {
+		if n, err := strconv.Atoi(countStr); err == nil && n > 0 && n <= 100 {
+			count = n
+		}
+	}
+
+	occurrences, err := transactionService.GetUpcomingOccurrences(recurringId, userId.(primitive.ObjectID), count)
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Data = gin.H{"occurrences": occurrences}
+	response.SendResponse(c)
+}
+
+// DeleteRecurringExpense godoc
+// @Summary      Delete Recurring Expense
+// @Description  stops a recurring expense; expenses already created from it are kept
+// @Tags         recurring-expenses
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Recurring Expense ID"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /recurring-expenses/{id} [delete]
+// @Security     ApiKeyAuth
+func DeleteRecurringExpense(c *gin.Context) {
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	idHex := c.Param("id")
+	recurringId, err := primitive.ObjectIDFromHex(idHex)
+	if err != nil {
+		response.Message = "invalid recurring expense id"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	if err := transactionService.DeleteRecurringExpense(recurringId, userId.(primitive.ObjectID)); err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Message = "Recurring expense deleted successfully"
+	response.SendResponse(c)
+}
@@ -0,0 +1,259 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CreateRotation godoc
+// @Summary      Create Rotation
+// @Description  takes turns paying for a category of the group's expenses, in a fixed order or by balance
+// @Tags         rotations
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Group ID"
+// @Param        req  body      models.CreateRotationRequest  true  "Rotation Request"
+// @Success      201  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /groups/{id}/rotations [post]
+// @Security     ApiKeyAuth
+func CreateRotation(c *gin.Context) {
+	var requestBody models.CreateRotationRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	groupId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		response.Message = "invalid group id"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	rotation, err := transactionService.CreateRotation(groupId, userId.(primitive.ObjectID), requestBody)
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusCreated
+	response.Success = true
+	response.Data = gin.H{"rotation": rotation}
+	response.Message = "Rotation created successfully"
+	response.SendResponse(c)
+}
+
+// GetGroupRotations godoc
+// @Summary      Get Group Rotations
+// @Description  lists a group's rotations with their recent turns
+// @Tags         rotations
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Group ID"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /groups/{id}/rotations [get]
+// @Security     ApiKeyAuth
+func GetGroupRotations(c *gin.Context) {
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	groupId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		response.Message = "invalid group id"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	rotations, err := transactionService.GetGroupRotations(groupId, userId.(primitive.ObjectID))
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Data = gin.H{"rotations": rotations}
+	response.SendResponse(c)
+}
+
+// SuggestNextPayer godoc
+// @Summary      Suggest Next Payer
+// @Description  suggests whose turn it is to pay the next expense of a category
+// @Tags         rotations
+// @Accept       json
+// @Produce      json
+// @Param        id        path      string  true  "Group ID"
+// @Param        category  query     string  true  "Category ID or name"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /groups/{id}/rotations/next [get]
+// @Security     ApiKeyAuth
+func SuggestNextPayer(c *gin.Context) {
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	groupId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		response.Message = "invalid group id"
+		response.SendResponse(c)
+		return
+	}
+
+	category := c.Query("category")
+	if category == "" {
+		response.Message = "category is required"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	suggestion, err := transactionService.SuggestNextPayer(groupId, userId.(primitive.ObjectID), category)
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Data = gin.H{"suggestion": suggestion}
+	response.SendResponse(c)
+}
+
+// RemindRotation godoc
+// @Summary      Remind Rotation
+// @Description  sends a notification to whoever's turn it is to pay next
+// @Tags         rotations
+// @Accept       json
+// @Produce      json
+// @Param        id          path      string  true  "Group ID"
+// @Param        rotationId  path      string  true  "Rotation ID"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /groups/{id}/rotations/{rotationId}/remind [post]
+// @Security     ApiKeyAuth
+func RemindRotation(c *gin.Context) {
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	groupId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		response.Message = "invalid group id"
+		response.SendResponse(c)
+		return
+	}
+
+	rotationId, err := primitive.ObjectIDFromHex(c.Param("rotationId"))
+	if err != nil {
+		response.Message = "invalid rotation id"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	suggestion, err := transactionService.RemindRotation(groupId, rotationId, userId.(primitive.ObjectID))
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Data = gin.H{"suggestion": suggestion}
+	response.Message = "Reminder sent"
+	response.SendResponse(c)
+}
+
+// DeleteRotation godoc
+// @Summary      Delete Rotation
+// @Description  stops taking turns for a category
+// @Tags         rotations
+// @Accept       json
+// @Produce      json
+// @Param        id          path      string  true  "Group ID"
+// @Param        rotationId  path      string  true  "Rotation ID"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /groups/{id}/rotations/{rotationId} [delete]
+// @Security     ApiKeyAuth
+func DeleteRotation(c *gin.Context) {
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	groupId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		response.Message = "invalid group id"
+		response.SendResponse(c)
+		return
+	}
+
+	rotationId, err := primitive.ObjectIDFromHex(c.Param("rotationId"))
+	if err != nil {
+		response.Message = "invalid rotation id"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	if err := transactionService.DeleteRotation(groupId, rotationId, userId.(primitive.ObjectID)); err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Message = "Rotation deleted successfully"
+	response.SendResponse(c)
+}
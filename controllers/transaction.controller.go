@@ -3,6 +3,7 @@ package controllers
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models"
 	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/services"
@@ -102,7 +103,7 @@ func CreateSettlementTransaction(c *gin.Context) {
 		return
 	}
 
-	transaction, err := transactionService.CreateSettlementTransaction(groupId, payerId, payeeId, requestBody.Amount, requestBody.Currency, requestBody.Notes, requestBody.IsCompleted, userId.(primitive.ObjectID))
+	transaction, err := transactionService.CreateSettlementTransaction(groupId, payerId, payeeId, requestBody.Amount, requestBody.Currency, requestBody.ExchangeRate, requestBody.Notes, requestBody.IsCompleted, userId.(primitive.ObjectID), requestBody.Date, requestBody.Timezone)
 	if err != nil {
 		response.Message = err.Error()
 		response.SendResponse(c)
@@ -167,7 +168,8 @@ func GetGroupBalancesV2(c *gin.Context) {
 // @Tags         transactions
 // @Accept       json
 // @Produce      json
-// @Param        groupId  path      string  true  "Group ID"
+// @Param        groupId   path      string  true   "Group ID"
+// @Param        strategy  query     string  false  "greedy (default), minimal or pairwise-only"
 // @Success      200  {object}  models.Response
 // @Failure      400  {object}  models.Response
 // @Router       /groups/{groupId}/simplify [get]
@@ -193,7 +195,9 @@ func SimplifyDebtsV2(c *gin.Context) {
 		return
 	}
 
-	settlements, err := transactionService.SimplifyDebtsFromBalances(groupId, userId.(primitive.ObjectID))
+	strategy := c.DefaultQuery("strategy", services.SimplifyGreedy)
+
+	settlements, err := transactionService.SimplifyDebtsFromBalances(groupId, userId.(primitive.ObjectID), strategy)
 	if err != nil {
 		response.Message = err.Error()
 		response.SendResponse(c)
@@ -202,7 +206,7 @@ func SimplifyDebtsV2(c *gin.Context) {
 
 	response.StatusCode = http.StatusOK
 	response.Success = true
-	response.Data = gin.H{"suggested_settlements": settlements}
+	response.Data = gin.H{"suggested_settlements": settlements, "strategy": strategy}
 	response.SendResponse(c)
 }
 
@@ -255,30 +259,31 @@ func MarkTransactionComplete(c *gin.Context) {
 	response.SendResponse(c)
 }
 
-// GetGroupTransactions godoc
-// @Summary      Get Group Transactions
-// @Description  gets all transactions for a specific group
+// UpdateSettlementStatus godoc
+// @Summary      Update Settlement Status
+// @Description  moves a settlement to paid (payer), confirmed or disputed (payee), or cancelled (either); balances move on confirmation
 // @Tags         transactions
 // @Accept       json
 // @Produce      json
-// @Param        groupId  path      string  true  "Group ID"
-// @Param        type     query     string  false  "Filter by transaction type (expense, settlement)"
-// @Param        page     query     int     false  "Page number (default: 0)"
-// @Param        limit    query     int     false  "Items per page (default: 20)"
+// @Param        id   path      string  true  "Transaction ID"
+// @Param        req  body      models.UpdateSettlementStatusRequest true "Update Settlement Status Request"
 // @Success      200  {object}  models.Response
 // @Failure      400  {object}  models.Response
-// @Router       /groups/{groupId}/transactions [get]
+// @Router       /transactions/{id}/status [post]
 // @Security     ApiKeyAuth
-func GetGroupTransactions(c *gin.Context) {
+func UpdateSettlementStatus(c *gin.Context) {
+	var requestBody models.UpdateSettlementStatusRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
 	response := &models.Response{
 		StatusCode: http.StatusBadRequest,
 		Success:    false,
 	}
 
-	groupIdHex := c.Param("id")
-	groupId, err := primitive.ObjectIDFromHex(groupIdHex)
+	idHex := c.Param("id")
+	transactionId, err := primitive.ObjectIDFromHex(idHex)
 	if err != nil {
-		response.Message = "invalid group id"
+		response.Message = "invalid transaction id"
 		response.SendResponse(c)
 		return
 	}
@@ -290,59 +295,36 @@ func GetGroupTransactions(c *gin.Context) {
 		return
 	}
 
-	// Parse query parameters
-	transactionType := c.Query("type")
-
-	page := 0
-	if pageStr := c.Query("page"); pageStr != "" {
-		if p, err := strconv.Atoi(pageStr); err == nil && p >= 0 {
-			page = p
-		}
-	}
-
-	limit := 20
-	if limitStr := c.Query("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
-			limit = l
-		}
-	}
-
-	transactions, err := transactionService.GetGroupTransactions(groupId, userId.(primitive.ObjectID), transactionType, page, limit)
+	transaction, err := transactionService.UpdateSettlementStatus(transactionId, userId.(primitive.ObjectID), requestBody)
 	if err != nil {
 		response.Message = err.Error()
 		response.SendResponse(c)
 		return
 	}
 
-	// Check if there are more results
-	hasMore := len(transactions) > limit
-	if hasMore {
-		transactions = transactions[:limit] // Remove the extra item
-	}
-
 	response.StatusCode = http.StatusOK
 	response.Success = true
-	response.Data = gin.H{
-		"transactions": transactions,
-		"page":         page,
-		"limit":        limit,
-		"has_more":     hasMore,
-	}
+	response.Data = gin.H{"transaction": transaction}
+	response.Message = "Settlement marked as " + string(transaction.Status)
 	response.SendResponse(c)
 }
 
-// GetTransactionById godoc
-// @Summary      Get Transaction by ID
-// @Description  gets a single transaction by ID
+// RecordSettlementPayment godoc
+// @Summary      Record Settlement Payment
+// @Description  records an installment paid towards a settlement; payments recorded by the payer wait for the payee to confirm them
 // @Tags         transactions
 // @Accept       json
 // @Produce      json
 // @Param        id   path      string  true  "Transaction ID"
+// @Param        req  body      models.RecordSettlementPaymentRequest true "Record Settlement Payment Request"
 // @Success      200  {object}  models.Response
 // @Failure      400  {object}  models.Response
-// @Router       /transactions/{id} [get]
+// @Router       /transactions/{id}/payments [post]
 // @Security     ApiKeyAuth
-func GetTransactionById(c *gin.Context) {
+func RecordSettlementPayment(c *gin.Context) {
+	var requestBody models.RecordSettlementPaymentRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
 	response := &models.Response{
 		StatusCode: http.StatusBadRequest,
 		Success:    false,
@@ -363,7 +345,7 @@ func GetTransactionById(c *gin.Context) {
 		return
 	}
 
-	transaction, err := transactionService.GetTransactionById(transactionId, userId.(primitive.ObjectID))
+	transaction, err := transactionService.RecordSettlementPayment(transactionId, userId.(primitive.ObjectID), requestBody)
 	if err != nil {
 		response.Message = err.Error()
 		response.SendResponse(c)
@@ -373,38 +355,42 @@ func GetTransactionById(c *gin.Context) {
 	response.StatusCode = http.StatusOK
 	response.Success = true
 	response.Data = gin.H{"transaction": transaction}
+	response.Message = "Payment recorded"
 	response.SendResponse(c)
 }
 
-// UpdateTransaction godoc
-// @Summary      Update Transaction
-// @Description  updates an expense transaction
+// ConfirmSettlementPayment godoc
+// @Summary      Confirm Settlement Payment
+// @Description  confirms, as the payee, that a payment towards a settlement has arrived
 // @Tags         transactions
 // @Accept       json
 // @Produce      json
-// @Param        id   path      string  true  "Transaction ID"
-// @Param        req  body      models.UpdateTransactionRequest  true  "Update Request"
+// @Param        id         path      string  true  "Transaction ID"
+// @Param        paymentId  path      string  true  "Payment ID"
 // @Success      200  {object}  models.Response
 // @Failure      400  {object}  models.Response
-// @Router       /transactions/{id} [put]
+// @Router       /transactions/{id}/payments/{paymentId}/confirm [post]
 // @Security     ApiKeyAuth
-func UpdateTransaction(c *gin.Context) {
-	var requestBody models.UpdateTransactionRequest
-	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
-
+func ConfirmSettlementPayment(c *gin.Context) {
 	response := &models.Response{
 		StatusCode: http.StatusBadRequest,
 		Success:    false,
 	}
 
-	idHex := c.Param("id")
-	transactionId, err := primitive.ObjectIDFromHex(idHex)
+	transactionId, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
 		response.Message = "invalid transaction id"
 		response.SendResponse(c)
 		return
 	}
 
+	paymentId, err := primitive.ObjectIDFromHex(c.Param("paymentId"))
+	if err != nil {
+		response.Message = "invalid payment id"
+		response.SendResponse(c)
+		return
+	}
+
 	userId, exists := c.Get("userId")
 	if !exists {
 		response.Message = "cannot get user"
@@ -412,7 +398,7 @@ func UpdateTransaction(c *gin.Context) {
 		return
 	}
 
-	err = transactionService.UpdateTransaction(transactionId, userId.(primitive.ObjectID), requestBody)
+	transaction, err := transactionService.ConfirmSettlementPayment(transactionId, paymentId, userId.(primitive.ObjectID))
 	if err != nil {
 		response.Message = err.Error()
 		response.SendResponse(c)
@@ -421,31 +407,46 @@ func UpdateTransaction(c *gin.Context) {
 
 	response.StatusCode = http.StatusOK
 	response.Success = true
-	response.Message = "Transaction updated successfully"
+	response.Data = gin.H{"transaction": transaction}
+	response.Message = "Payment confirmed"
 	response.SendResponse(c)
 }
 
-// DeleteTransaction godoc
-// @Summary      Delete Transaction
-// @Description  deletes a transaction
+// GetGroupTransactions godoc
+// @Summary      Get Group Transactions
+// @Description  gets all transactions for a specific group
 // @Tags         transactions
 // @Accept       json
 // @Produce      json
-// @Param        id   path      string  true  "Transaction ID"
+// @Param        groupId  path      string  true  "Group ID"
+// @Param        type     query     string  false  "Filter by transaction type (expense, settlement)"
+// @Param        from         query     string  false  "Earliest date, a day (YYYY-MM-DD) or RFC 3339 timestamp"
+// @Param        to           query     string  false  "Latest date, inclusive"
+// @Param        timezone     query     string  false  "IANA timezone days are read in"
+// @Param        category     query     string  false  "Filter by category ID"
+// @Param        payer        query     string  false  "Filter by payer ID"
+// @Param        participant  query     string  false  "Filter by participant ID"
+// @Param        min_amount   query     int     false  "Minimum amount in the group currency"
+// @Param        max_amount   query     int     false  "Maximum amount in the group currency"
+// @Param        completed    query     bool    false  "Filter by completion"
+// @Param        q            query     string  false  "Search words in the description and notes"
+// @Param        cursor       query     string  false  "next_cursor of the previous page"
+// @Param        page     query     int     false  "Page number (default: 0)"
+// @Param        limit    query     int     false  "Items per page (default: 20)"
 // @Success      200  {object}  models.Response
 // @Failure      400  {object}  models.Response
-// @Router       /transactions/{id} [delete]
+// @Router       /groups/{groupId}/transactions [get]
 // @Security     ApiKeyAuth
-func DeleteTransaction(c *gin.Context) {
+func GetGroupTransactions(c *gin.Context) {
 	response := &models.Response{
 		StatusCode: http.StatusBadRequest,
 		Success:    false,
 	}
 
-	idHex := c.Param("id")
-	transactionId, err := primitive.ObjectIDFromHex(idHex)
+	groupIdHex := c.Param("id")
+	groupId, err := primitive.ObjectIDFromHex(groupIdHex)
 	if err != nil {
-		response.Message = "invalid transaction id"
+		response.Message = "invalid group id"
 		response.SendResponse(c)
 		return
 	}
@@ -457,7 +458,14 @@ func DeleteTransaction(c *gin.Context) {
 		return
 	}
 
-	err = transactionService.DeleteTransaction(transactionId, userId.(primitive.ObjectID))
+	query, err := parseTransactionQuery(c)
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	page, err := transactionService.GetGroupTransactions(groupId, userId.(primitive.ObjectID), query)
 	if err != nil {
 		response.Message = err.Error()
 		response.SendResponse(c)
@@ -466,55 +474,37 @@ func DeleteTransaction(c *gin.Context) {
 
 	response.StatusCode = http.StatusOK
 	response.Success = true
-	response.Message = "Transaction deleted successfully"
+	response.Data = gin.H{
+		"transactions": page.Transactions,
+		"page":         query.Page,
+		"limit":        query.Limit,
+		"has_more":     page.HasMore,
+		"next_cursor":  page.NextCursor,
+	}
 	response.SendResponse(c)
 }
 
-// GetGroupExpenseTransactions godoc
-// @Summary      Get Group Expense Transactions
-// @Description  gets all expense transactions for a group
-// @Tags         transactions
-// @Accept       json
-// @Produce      json
-// @Param        groupId  path      string  true  "Group ID"
-// @Param        page     query     int     false  "Page number (default: 0)"
-// @Param        limit    query     int     false  "Items per page (default: 20)"
-// @Success      200  {object}  models.Response
-// @Failure      400  {object}  models.Response
-// @Router       /groups/{groupId}/transactions/expenses [get]
-// @Security     ApiKeyAuth
-func GetGroupExpenseTransactions(c *gin.Context) {
-	getGroupTransactionsByType(c, "expense")
-}
-
-// GetGroupSettlementTransactions godoc
-// @Summary      Get Group Settlement Transactions
-// @Description  gets all settlement transactions for a group
+// GetTransactionById godoc
+// @Summary      Get Transaction by ID
+// @Description  gets a single transaction by ID
 // @Tags         transactions
 // @Accept       json
 // @Produce      json
-// @Param        groupId  path      string  true  "Group ID"
-// @Param        page     query     int     false  "Page number (default: 0)"
-// @Param        limit    query     int     false  "Items per page (default: 20)"
+// @Param        id   path      string  true  "Transaction ID"
 // @Success      200  {object}  models.Response
 // @Failure      400  {object}  models.Response
-// @Router       /groups/{groupId}/transactions/settlements [get]
+// @Router       /transactions/{id} [get]
 // @Security     ApiKeyAuth
-func GetGroupSettlementTransactions(c *gin.Context) {
-	getGroupTransactionsByType(c, "settlement")
-}
-
-// getGroupTransactionsByType is a helper function for filtering transactions by type
-func getGroupTransactionsByType(c *gin.Context, transactionType string) {
+func GetTransactionById(c *gin.Context) {
 	response := &models.Response{
 		StatusCode: http.StatusBadRequest,
 		Success:    false,
 	}
 
-	groupIdHex := c.Param("id")
-	groupId, err := primitive.ObjectIDFromHex(groupIdHex)
+	idHex := c.Param("id")
+	transactionId, err := primitive.ObjectIDFromHex(idHex)
 	if err != nil {
-		response.Message = "invalid group id"
+		response.Message = "invalid transaction id"
 		response.SendResponse(c)
 		return
 	}
@@ -526,68 +516,40 @@ func getGroupTransactionsByType(c *gin.Context, transactionType string) {
 		return
 	}
 
-	// Parse query parameters
-	page := 0
-	if pageStr := c.Query("page"); pageStr != "" {
-		if p, err := strconv.Atoi(pageStr); err == nil && p >= 0 {
-			page = p
-		}
-	}
-
-	limit := 20
-	if limitStr := c.Query("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
-			limit = l
-		}
-	}
-
-	transactions, err := transactionService.GetGroupTransactions(groupId, userId.(primitive.ObjectID), transactionType, page, limit)
+	transaction, err := transactionService.GetTransactionById(transactionId, userId.(primitive.ObjectID))
 	if err != nil {
 		response.Message = err.Error()
 		response.SendResponse(c)
 		return
 	}
 
-	// Check if there are more results
-	hasMore := len(transactions) > limit
-	if hasMore {
-		transactions = transactions[:limit]
-	}
-
 	response.StatusCode = http.StatusOK
 	response.Success = true
-	response.Data = gin.H{
-		"transactions": transactions,
-		"type":         transactionType,
-		"page":         page,
-		"limit":        limit,
-		"has_more":     hasMore,
-	}
+	response.Data = gin.H{"transaction": transaction}
 	response.SendResponse(c)
 }
 
-// GetGroupBalanceHistory godoc
-// @Summary      Get Group Balance History
-// @Description  gets balance change history for a group
+// GetTransactionHistory godoc
+// @Summary      Get Transaction History
+// @Description  lists every revision of a transaction, oldest first, with the fields each one changed
 // @Tags         transactions
 // @Accept       json
 // @Produce      json
-// @Param        groupId  path      string  true  "Group ID"
-// @Param        days     query     int     false  "Number of days (default: 30)"
+// @Param        id   path      string  true  "Transaction ID"
 // @Success      200  {object}  models.Response
 // @Failure      400  {object}  models.Response
-// @Router       /groups/{groupId}/balance-history [get]
+// @Router       /transactions/{id}/history [get]
 // @Security     ApiKeyAuth
-func GetGroupBalanceHistory(c *gin.Context) {
+func GetTransactionHistory(c *gin.Context) {
 	response := &models.Response{
 		StatusCode: http.StatusBadRequest,
 		Success:    false,
 	}
 
-	groupIdHex := c.Param("id")
-	groupId, err := primitive.ObjectIDFromHex(groupIdHex)
+	idHex := c.Param("id")
+	transactionId, err := primitive.ObjectIDFromHex(idHex)
 	if err != nil {
-		response.Message = "invalid group id"
+		response.Message = "invalid transaction id"
 		response.SendResponse(c)
 		return
 	}
@@ -599,14 +561,7 @@ func GetGroupBalanceHistory(c *gin.Context) {
 		return
 	}
 
-	days := 30
-	if daysStr := c.Query("days"); daysStr != "" {
-		if d, err := strconv.Atoi(daysStr); err == nil && d > 0 && d <= 365 {
-			days = d
-		}
-	}
-
-	history, err := transactionService.GetGroupBalanceHistory(groupId, userId.(primitive.ObjectID), days)
+	revisions, err := transactionService.GetTransactionHistory(transactionId, userId.(primitive.ObjectID))
 	if err != nil {
 		response.Message = err.Error()
 		response.SendResponse(c)
@@ -615,35 +570,30 @@ func GetGroupBalanceHistory(c *gin.Context) {
 
 	response.StatusCode = http.StatusOK
 	response.Success = true
-	response.Data = gin.H{"balance_history": history}
+	response.Data = gin.H{"revisions": revisions}
 	response.SendResponse(c)
 }
 
-// GetGroupAnalytics godoc
-// @Summary      Get Group Analytics
-// @Description  gets analytics data for a group
+// CreateRefundTransaction godoc
+// @Summary      Create Refund
+// @Description  refunds part or all of an expense, lowering what its payers paid and its splits owe
 // @Tags         transactions
 // @Accept       json
 // @Produce      json
-// @Param        groupId  path      string  true  "Group ID"
-// @Success      200  {object}  models.Response
+// @Param        refund  body      models.CreateRefundTransactionRequest  true  "Refund Request"
+// @Success      201  {object}  models.Response
 // @Failure      400  {object}  models.Response
-// @Router       /groups/{groupId}/analytics [get]
+// @Router       /transactions/refund [post]
 // @Security     ApiKeyAuth
-func GetGroupAnalytics(c *gin.Context) {
+func CreateRefundTransaction(c *gin.Context) {
+	var requestBody models.CreateRefundTransactionRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
 	response := &models.Response{
 		StatusCode: http.StatusBadRequest,
 		Success:    false,
 	}
 
-	groupIdHex := c.Param("id")
-	groupId, err := primitive.ObjectIDFromHex(groupIdHex)
-	if err != nil {
-		response.Message = "invalid group id"
-		response.SendResponse(c)
-		return
-	}
-
 	userId, exists := c.Get("userId")
 	if !exists {
 		response.Message = "cannot get user"
@@ -651,33 +601,33 @@ func GetGroupAnalytics(c *gin.Context) {
 		return
 	}
 
-	analytics, err := transactionService.GetGroupAnalytics(groupId, userId.(primitive.ObjectID))
+	transaction, err := transactionService.CreateRefundTransaction(userId.(primitive.ObjectID), requestBody)
 	if err != nil {
 		response.Message = err.Error()
 		response.SendResponse(c)
 		return
 	}
 
-	response.StatusCode = http.StatusOK
+	response.StatusCode = http.StatusCreated
 	response.Success = true
-	response.Data = gin.H{"analytics": analytics}
+	response.Data = gin.H{"transaction": transaction}
+	response.Message = "Refund created successfully"
 	response.SendResponse(c)
 }
 
-// CreateBulkSettlements godoc
-// @Summary      Create Bulk Settlements
-// @Description  creates multiple settlements from suggested settlements
+// CreateAdjustmentTransaction godoc
+// @Summary      Create Balance Adjustment
+// @Description  corrects group balances by hand with a reason (group creator or admin only)
 // @Tags         transactions
 // @Accept       json
 // @Produce      json
-// @Param        groupId  path      string  true  "Group ID"
-// @Param        req      body      models.BulkSettlementsTransactionRequest  true  "Bulk Settlements Request"
+// @Param        adjustment  body      models.CreateAdjustmentTransactionRequest  true  "Adjustment Request"
 // @Success      201  {object}  models.Response
 // @Failure      400  {object}  models.Response
-// @Router       /groups/{groupId}/bulk-settlements [post]
+// @Router       /transactions/adjustment [post]
 // @Security     ApiKeyAuth
-func CreateBulkSettlements(c *gin.Context) {
-	var requestBody models.BulkSettlementsTransactionRequest
+func CreateAdjustmentTransaction(c *gin.Context) {
+	var requestBody models.CreateAdjustmentTransactionRequest
 	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
 
 	response := &models.Response{
@@ -685,14 +635,6 @@ func CreateBulkSettlements(c *gin.Context) {
 		Success:    false,
 	}
 
-	groupIdHex := c.Param("id")
-	groupId, err := primitive.ObjectIDFromHex(groupIdHex)
-	if err != nil {
-		response.Message = "invalid group id"
-		response.SendResponse(c)
-		return
-	}
-
 	userId, exists := c.Get("userId")
 	if !exists {
 		response.Message = "cannot get user"
@@ -700,7 +642,7 @@ func CreateBulkSettlements(c *gin.Context) {
 		return
 	}
 
-	settlements, err := transactionService.CreateBulkSettlements(groupId, userId.(primitive.ObjectID), requestBody.Settlements)
+	transaction, err := transactionService.CreateAdjustmentTransaction(userId.(primitive.ObjectID), requestBody)
 	if err != nil {
 		response.Message = err.Error()
 		response.SendResponse(c)
@@ -709,32 +651,36 @@ func CreateBulkSettlements(c *gin.Context) {
 
 	response.StatusCode = http.StatusCreated
 	response.Success = true
-	response.Data = gin.H{"settlements": settlements}
-	response.Message = "Bulk settlements created successfully"
+	response.Data = gin.H{"transaction": transaction}
+	response.Message = "Balances adjusted successfully"
 	response.SendResponse(c)
 }
 
-// RecalculateGroupBalances godoc
-// @Summary      Recalculate Group Balances
-// @Description  recalculates all balances for a group (admin operation)
+// UpdateTransaction godoc
+// @Summary      Update Transaction
+// @Description  updates an expense; changing amount, split type, payers or splits recomputes the split and rebalances the group
 // @Tags         transactions
 // @Accept       json
 // @Produce      json
-// @Param        groupId  path      string  true  "Group ID"
+// @Param        id   path      string  true  "Transaction ID"
+// @Param        req  body      models.UpdateTransactionRequest  true  "Update Request"
 // @Success      200  {object}  models.Response
 // @Failure      400  {object}  models.Response
-// @Router       /groups/{groupId}/recalculate-balances [post]
+// @Router       /transactions/{id} [put]
 // @Security     ApiKeyAuth
-func RecalculateGroupBalances(c *gin.Context) {
+func UpdateTransaction(c *gin.Context) {
+	var requestBody models.UpdateTransactionRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
 	response := &models.Response{
 		StatusCode: http.StatusBadRequest,
 		Success:    false,
 	}
 
-	groupIdHex := c.Param("id")
-	groupId, err := primitive.ObjectIDFromHex(groupIdHex)
+	idHex := c.Param("id")
+	transactionId, err := primitive.ObjectIDFromHex(idHex)
 	if err != nil {
-		response.Message = "invalid group id"
+		response.Message = "invalid transaction id"
 		response.SendResponse(c)
 		return
 	}
@@ -746,7 +692,7 @@ func RecalculateGroupBalances(c *gin.Context) {
 		return
 	}
 
-	err = transactionService.RecalculateGroupBalances(groupId, userId.(primitive.ObjectID))
+	transaction, err := transactionService.UpdateTransaction(transactionId, userId.(primitive.ObjectID), requestBody)
 	if err != nil {
 		response.Message = err.Error()
 		response.SendResponse(c)
@@ -755,29 +701,36 @@ func RecalculateGroupBalances(c *gin.Context) {
 
 	response.StatusCode = http.StatusOK
 	response.Success = true
-	response.Message = "Group balances recalculated successfully"
+	response.Data = gin.H{"transaction": transaction}
+	response.Message = "Transaction updated successfully"
 	response.SendResponse(c)
 }
 
-// GetUserTransactions godoc
-// @Summary      Get User Transactions
-// @Description  gets all transactions for the authenticated user across all groups
+// DeleteTransaction godoc
+// @Summary      Delete Transaction
+// @Description  moves a transaction to the trash, reversing its balance effects; it can be restored for 30 days
 // @Tags         transactions
 // @Accept       json
 // @Produce      json
-// @Param        page     query     int     false  "Page number (default: 0)"
-// @Param        limit    query     int     false  "Items per page (default: 20)"
-// @Param        type     query     string  false  "Filter by transaction type"
+// @Param        id   path      string  true  "Transaction ID"
 // @Success      200  {object}  models.Response
 // @Failure      400  {object}  models.Response
-// @Router       /users/me/transactions [get]
+// @Router       /transactions/{id} [delete]
 // @Security     ApiKeyAuth
-func GetUserTransactions(c *gin.Context) {
+func DeleteTransaction(c *gin.Context) {
 	response := &models.Response{
 		StatusCode: http.StatusBadRequest,
 		Success:    false,
 	}
 
+	idHex := c.Param("id")
+	transactionId, err := primitive.ObjectIDFromHex(idHex)
+	if err != nil {
+		response.Message = "invalid transaction id"
+		response.SendResponse(c)
+		return
+	}
+
 	userId, exists := c.Get("userId")
 	if !exists {
 		response.Message = "cannot get user"
@@ -785,43 +738,553 @@ func GetUserTransactions(c *gin.Context) {
 		return
 	}
 
-	// Parse query parameters
-	transactionType := c.Query("type")
-
-	page := 0
-	if pageStr := c.Query("page"); pageStr != "" {
-		if p, err := strconv.Atoi(pageStr); err == nil && p >= 0 {
-			page = p
-		}
+	err = transactionService.DeleteTransaction(transactionId, userId.(primitive.ObjectID))
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
 	}
 
-	limit := 20
-	if limitStr := c.Query("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
-			limit = l
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Message = "Transaction deleted successfully"
+	response.SendResponse(c)
+}
+
+// RestoreTransaction godoc
+// @Summary      Restore Transaction
+// @Description  takes a deleted transaction out of the trash and reapplies its balance effects
+// @Tags         transactions
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Transaction ID"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /transactions/{id}/restore [post]
+// @Security     ApiKeyAuth
+func RestoreTransaction(c *gin.Context) {
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	idHex := c.Param("id")
+	transactionId, err := primitive.ObjectIDFromHex(idHex)
+	if err != nil {
+		response.Message = "invalid transaction id"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	transaction, err := transactionService.RestoreTransaction(transactionId, userId.(primitive.ObjectID))
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Data = gin.H{"transaction": transaction}
+	response.Message = "Transaction restored successfully"
+	response.SendResponse(c)
+}
+
+// GetGroupTrash godoc
+// @Summary      Get Group Trash
+// @Description  gets the deleted transactions of a group that can still be restored
+// @Tags         transactions
+// @Accept       json
+// @Produce      json
+// @Param        groupId  path      string  true  "Group ID"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /groups/{groupId}/trash [get]
+// @Security     ApiKeyAuth
+func GetGroupTrash(c *gin.Context) {
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	groupIdHex := c.Param("id")
+	groupId, err := primitive.ObjectIDFromHex(groupIdHex)
+	if err != nil {
+		response.Message = "invalid group id"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	transactions, err := transactionService.GetGroupTrash(groupId, userId.(primitive.ObjectID))
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Data = gin.H{"transactions": transactions}
+	response.SendResponse(c)
+}
+
+// GetGroupExpenseTransactions godoc
+// @Summary      Get Group Expense Transactions
+// @Description  gets all expense transactions for a group
+// @Tags         transactions
+// @Accept       json
+// @Produce      json
+// @Param        groupId  path      string  true  "Group ID"
+// @Param        page     query     int     false  "Page number (default: 0)"
+// @Param        limit    query     int     false  "Items per page (default: 20)"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /groups/{groupId}/transactions/expenses [get]
+// @Security     ApiKeyAuth
+func GetGroupExpenseTransactions(c *gin.Context) {
+	getGroupTransactionsByType(c, "expense")
+}
+
+// GetGroupSettlementTransactions godoc
+// @Summary      Get Group Settlement Transactions
+// @Description  gets all settlement transactions for a group
+// @Tags         transactions
+// @Accept       json
+// @Produce      json
+// @Param        groupId  path      string  true  "Group ID"
+// @Param        page     query     int     false  "Page number (default: 0)"
+// @Param        limit    query     int     false  "Items per page (default: 20)"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /groups/{groupId}/transactions/settlements [get]
+// @Security     ApiKeyAuth
+func GetGroupSettlementTransactions(c *gin.Context) {
+	getGroupTransactionsByType(c, "settlement")
+}
+
+// getGroupTransactionsByType is a helper function for filtering transactions by type
+func getGroupTransactionsByType(c *gin.Context, transactionType string) {
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	groupIdHex := c.Param("id")
+	groupId, err := primitive.ObjectIDFromHex(groupIdHex)
+	if err != nil {
+		response.Message = "invalid group id"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	query, err := parseTransactionQuery(c)
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+	query.Type = transactionType
+
+	page, err := transactionService.GetGroupTransactions(groupId, userId.(primitive.ObjectID), query)
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Data = gin.H{
+		"transactions": page.Transactions,
+		"type":         transactionType,
+		"page":         query.Page,
+		"limit":        query.Limit,
+		"has_more":     page.HasMore,
+		"next_cursor":  page.NextCursor,
+	}
+	response.SendResponse(c)
+}
+
+// GetGroupBalanceHistory godoc
+// @Summary      Get Group Balance History
+// @Description  gets balance change history for a group
+// @Tags         transactions
+// @Accept       json
+// @Produce      json
+// @Param        groupId  path      string  true  "Group ID"
+// @Param        days     query     int     false  "Number of days (default: 30)"
+// @Param        timezone query     string  false  "IANA timezone days are counted in (default: the user's, then the group's)"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /groups/{groupId}/balance-history [get]
+// @Security     ApiKeyAuth
+func GetGroupBalanceHistory(c *gin.Context) {
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	groupIdHex := c.Param("id")
+	groupId, err := primitive.ObjectIDFromHex(groupIdHex)
+	if err != nil {
+		response.Message = "invalid group id"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	days := 30
+	if daysStr := c.Query("days"); daysStr != "" {
+		if d, err := strconv.Atoi(daysStr); err == nil && d > 0 && d <= 365 {
+			days = d
 		}
 	}
 
-	transactions, err := transactionService.GetUserTransactions(userId.(primitive.ObjectID), transactionType, page, limit)
+	history, timezone, err := transactionService.GetGroupBalanceHistory(groupId, userId.(primitive.ObjectID), days, c.Query("timezone"))
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Data = gin.H{"balance_history": history, "timezone": timezone}
+	response.SendResponse(c)
+}
+
+// GetGroupAnalytics godoc
+// @Summary      Get Group Analytics
+// @Description  gets analytics data for a group
+// @Tags         transactions
+// @Accept       json
+// @Produce      json
+// @Param        groupId  path      string  true  "Group ID"
+// @Param        timezone query     string  false  "IANA timezone months are counted in (default: the user's, then the group's)"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /groups/{groupId}/analytics [get]
+// @Security     ApiKeyAuth
+func GetGroupAnalytics(c *gin.Context) {
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	groupIdHex := c.Param("id")
+	groupId, err := primitive.ObjectIDFromHex(groupIdHex)
+	if err != nil {
+		response.Message = "invalid group id"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	analytics, err := transactionService.GetGroupAnalytics(groupId, userId.(primitive.ObjectID), c.Query("timezone"))
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Data = gin.H{"analytics": analytics}
+	response.SendResponse(c)
+}
+
+// CreateBulkSettlements godoc
+// @Summary      Create Bulk Settlements
+// @Description  creates multiple settlements from suggested settlements, all or nothing, as one batch
+// @Tags         transactions
+// @Accept       json
+// @Produce      json
+// @Param        groupId  path      string  true  "Group ID"
+// @Param        req      body      models.BulkSettlementsTransactionRequest  true  "Bulk Settlements Request"
+// @Success      201  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /groups/{groupId}/bulk-settlements [post]
+// @Security     ApiKeyAuth
+func CreateBulkSettlements(c *gin.Context) {
+	var requestBody models.BulkSettlementsTransactionRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	groupIdHex := c.Param("id")
+	groupId, err := primitive.ObjectIDFromHex(groupIdHex)
+	if err != nil {
+		response.Message = "invalid group id"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	settlements, err := transactionService.CreateBulkSettlements(groupId, userId.(primitive.ObjectID), requestBody.Settlements)
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusCreated
+	response.Success = true
+	response.Data = gin.H{"settlements": settlements, "batch_id": settlements[0].BatchID}
+	response.Message = "Bulk settlements created successfully"
+	response.SendResponse(c)
+}
+
+// GetSettlementBatch godoc
+// @Summary      Get Settlement Batch
+// @Description  gets the settlements created together in a batch
+// @Tags         transactions
+// @Accept       json
+// @Produce      json
+// @Param        groupId  path      string  true  "Group ID"
+// @Param        batchId  path      string  true  "Batch ID"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /groups/{groupId}/settlement-batches/{batchId} [get]
+// @Security     ApiKeyAuth
+func GetSettlementBatch(c *gin.Context) {
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	groupId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		response.Message = "invalid group id"
+		response.SendResponse(c)
+		return
+	}
+
+	batchId, err := primitive.ObjectIDFromHex(c.Param("batchId"))
+	if err != nil {
+		response.Message = "invalid batch id"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	settlements, err := transactionService.GetSettlementBatch(groupId, batchId, userId.(primitive.ObjectID))
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Data = gin.H{"settlements": settlements, "batch_id": batchId}
+	response.SendResponse(c)
+}
+
+// UndoSettlementBatch godoc
+// @Summary      Undo Settlement Batch
+// @Description  deletes every settlement of a batch and reverses their balance changes, all or nothing
+// @Tags         transactions
+// @Accept       json
+// @Produce      json
+// @Param        groupId  path      string  true  "Group ID"
+// @Param        batchId  path      string  true  "Batch ID"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /groups/{groupId}/settlement-batches/{batchId} [delete]
+// @Security     ApiKeyAuth
+func UndoSettlementBatch(c *gin.Context) {
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	groupId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		response.Message = "invalid group id"
+		response.SendResponse(c)
+		return
+	}
+
+	batchId, err := primitive.ObjectIDFromHex(c.Param("batchId"))
+	if err != nil {
+		response.Message = "invalid batch id"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	err = transactionService.UndoSettlementBatch(groupId, batchId, userId.(primitive.ObjectID))
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Message = "Settlement batch undone"
+	response.SendResponse(c)
+}
+
+// RecalculateGroupBalances godoc
+// @Summary      Recalculate Group Balances
+// @Description  rebuilds all balances for a group from its ledger (admin operation)
+// @Tags         transactions
+// @Accept       json
+// @Produce      json
+// @Param        groupId  path      string  true  "Group ID"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Failure      403  {object}  models.Response
+// @Router       /groups/{groupId}/recalculate-balances [post]
+// @Security     ApiKeyAuth
+func RecalculateGroupBalances(c *gin.Context) {
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	groupIdHex := c.Param("id")
+	groupId, err := primitive.ObjectIDFromHex(groupIdHex)
+	if err != nil {
+		response.Message = "invalid group id"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	err = transactionService.RecalculateGroupBalances(groupId, userId.(primitive.ObjectID))
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Message = "Group balances recalculated successfully"
+	response.SendResponse(c)
+}
+
+// GetUserTransactions godoc
+// @Summary      Get User Transactions
+// @Description  gets all transactions for the authenticated user across all groups
+// @Tags         transactions
+// @Accept       json
+// @Produce      json
+// @Param        page     query     int     false  "Page number (default: 0)"
+// @Param        limit    query     int     false  "Items per page (default: 20)"
+// @Param        type     query     string  false  "Filter by transaction type"
+// @Param        from         query     string  false  "Earliest date, a day (YYYY-MM-DD) or RFC 3339 timestamp"
+// @Param        to           query     string  false  "Latest date, inclusive"
+// @Param        timezone     query     string  false  "IANA timezone days are read in"
+// @Param        category     query     string  false  "Filter by category ID"
+// @Param        payer        query     string  false  "Filter by payer ID"
+// @Param        participant  query     string  false  "Filter by participant ID"
+// @Param        min_amount   query     int     false  "Minimum amount in the group currency"
+// @Param        max_amount   query     int     false  "Maximum amount in the group currency"
+// @Param        completed    query     bool    false  "Filter by completion"
+// @Param        q            query     string  false  "Search words in the description and notes"
+// @Param        cursor       query     string  false  "next_cursor of the previous page"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /users/me/transactions [get]
+// @Security     ApiKeyAuth
+func GetUserTransactions(c *gin.Context) {
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	query, err := parseTransactionQuery(c)
 	if err != nil {
 		response.Message = err.Error()
 		response.SendResponse(c)
 		return
 	}
 
-	// Check if there are more results
-	hasMore := len(transactions) > limit
-	if hasMore {
-		transactions = transactions[:limit]
+	page, err := transactionService.GetUserTransactions(userId.(primitive.ObjectID), query)
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
 	}
 
 	response.StatusCode = http.StatusOK
 	response.Success = true
 	response.Data = gin.H{
-		"transactions": transactions,
-		"page":         page,
-		"limit":        limit,
-		"has_more":     hasMore,
+		"transactions": page.Transactions,
+		"page":         query.Page,
+		"limit":        query.Limit,
+		"has_more":     page.HasMore,
+		"next_cursor":  page.NextCursor,
 	}
 	response.SendResponse(c)
 }
@@ -862,12 +1325,266 @@ func GetUserBalances(c *gin.Context) {
 	response.SendResponse(c)
 }
 
+// GetFriendBalances godoc
+// @Summary      Get Friend Balances
+// @Description  gets what the authenticated user and each person they share a group with owe each other, per group and netted across groups
+// @Tags         transactions
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /users/me/friends/balances [get]
+// @Security     ApiKeyAuth
+func GetFriendBalances(c *gin.Context) {
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	balances, err := transactionService.GetFriendBalances(userId.(primitive.ObjectID))
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Data = gin.H{"friends": balances}
+	response.SendResponse(c)
+}
+
+// SettleUpWithFriend godoc
+// @Summary      Settle Up With Friend
+// @Description  settles everything the authenticated user and a friend owe each other, creating one linked settlement in each shared group
+// @Tags         transactions
+// @Accept       json
+// @Produce      json
+// @Param        friendId  path      string                            true  "Friend's user ID"
+// @Param        req       body      models.SettleUpWithFriendRequest  true  "Settle Up Request"
+// @Success      201  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /users/me/friends/{friendId}/settle-up [post]
+// @Security     ApiKeyAuth
+func SettleUpWithFriend(c *gin.Context) {
+	var requestBody models.SettleUpWithFriendRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	friendId, err := primitive.ObjectIDFromHex(c.Param("friendId"))
+	if err != nil {
+		response.Message = "invalid friend id"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	transactions, err := transactionService.SettleUpWithFriend(userId.(primitive.ObjectID), friendId, requestBody)
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusCreated
+	response.Success = true
+	response.Data = gin.H{"settlements": transactions, "batch_id": transactions[0].BatchID}
+	response.Message = "Settled up successfully"
+	response.SendResponse(c)
+}
+
+// CreateFriendExpense godoc
+// @Summary      Create Friend Expense
+// @Description  records an expense between the authenticated user and a friend outside any group
+// @Tags         transactions
+// @Accept       json
+// @Produce      json
+// @Param        friendId  path      string                             true  "Friend's user ID"
+// @Param        expense   body      models.CreateFriendExpenseRequest  true  "Expense Request"
+// @Success      201  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /users/me/friends/{friendId}/expenses [post]
+// @Security     ApiKeyAuth
+func CreateFriendExpense(c *gin.Context) {
+	var requestBody models.CreateFriendExpenseRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	friendId, err := primitive.ObjectIDFromHex(c.Param("friendId"))
+	if err != nil {
+		response.Message = "invalid friend id"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	transaction, err := transactionService.CreateFriendExpense(userId.(primitive.ObjectID), friendId, requestBody)
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusCreated
+	response.Success = true
+	response.Data = gin.H{"transaction": transaction}
+	response.Message = "Expense created successfully"
+	response.SendResponse(c)
+}
+
+// CreateFriendSettlement godoc
+// @Summary      Create Friend Settlement
+// @Description  records a payment between the authenticated user and a friend outside any group
+// @Tags         transactions
+// @Accept       json
+// @Produce      json
+// @Param        friendId    path      string                                true  "Friend's user ID"
+// @Param        settlement  body      models.CreateFriendSettlementRequest  true  "Settlement Request"
+// @Success      201  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /users/me/friends/{friendId}/settlements [post]
+// @Security     ApiKeyAuth
+func CreateFriendSettlement(c *gin.Context) {
+	var requestBody models.CreateFriendSettlementRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	friendId, err := primitive.ObjectIDFromHex(c.Param("friendId"))
+	if err != nil {
+		response.Message = "invalid friend id"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	transaction, err := transactionService.CreateFriendSettlement(userId.(primitive.ObjectID), friendId, requestBody)
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusCreated
+	response.Success = true
+	response.Data = gin.H{"transaction": transaction}
+	response.Message = "Settlement created successfully"
+	response.SendResponse(c)
+}
+
+// GetFriendTransactions godoc
+// @Summary      Get Friend Transactions
+// @Description  gets the direct expenses and settlements between the authenticated user and a friend
+// @Tags         transactions
+// @Accept       json
+// @Produce      json
+// @Param        friendId  path      string  true   "Friend's user ID"
+// @Param        page      query     int     false  "Page number (default: 0)"
+// @Param        limit     query     int     false  "Items per page (default: 20)"
+// @Param        from         query     string  false  "Earliest date, a day (YYYY-MM-DD) or RFC 3339 timestamp"
+// @Param        to           query     string  false  "Latest date, inclusive"
+// @Param        timezone     query     string  false  "IANA timezone days are read in"
+// @Param        category     query     string  false  "Filter by category ID"
+// @Param        payer        query     string  false  "Filter by payer ID"
+// @Param        participant  query     string  false  "Filter by participant ID"
+// @Param        min_amount   query     int     false  "Minimum amount in the group currency"
+// @Param        max_amount   query     int     false  "Maximum amount in the group currency"
+// @Param        completed    query     bool    false  "Filter by completion"
+// @Param        q            query     string  false  "Search words in the description and notes"
+// @Param        cursor       query     string  false  "next_cursor of the previous page"
+// @Success      200  {object}  models.Response
+// @Failure      400  {object}  models.Response
+// @Router       /users/me/friends/{friendId}/transactions [get]
+// @Security     ApiKeyAuth
+func GetFriendTransactions(c *gin.Context) {
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	friendId, err := primitive.ObjectIDFromHex(c.Param("friendId"))
+	if err != nil {
+		response.Message = "invalid friend id"
+		response.SendResponse(c)
+		return
+	}
+
+	userId, exists := c.Get("userId")
+	if !exists {
+		response.Message = "cannot get user"
+		response.SendResponse(c)
+		return
+	}
+
+	query, err := parseTransactionQuery(c)
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	page, err := transactionService.GetFriendTransactions(userId.(primitive.ObjectID), friendId, query)
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	response.StatusCode = http.StatusOK
+	response.Success = true
+	response.Data = gin.H{
+		"transactions": page.Transactions,
+		"page":         query.Page,
+		"limit":        query.Limit,
+		"has_more":     page.HasMore,
+		"next_cursor":  page.NextCursor,
+	}
+	response.SendResponse(c)
+}
+
 // GetUserAnalytics godoc
 // @Summary      Get User Analytics
 // @Description  gets analytics data for the authenticated user
 // @Tags         transactions
 // @Accept       json
 // @Produce      json
+// @Param        currency  query  string  false  "Currency to report the net balance in"
 // @Success      200  {object}  models.Response
 // @Failure      400  {object}  models.Response
 // @Router       /users/me/analytics [get]
@@ -885,7 +1602,7 @@ func GetUserAnalytics(c *gin.Context) {
 		return
 	}
 
-	analytics, err := transactionService.GetUserAnalytics(userId.(primitive.ObjectID))
+	analytics, err := transactionService.GetUserAnalytics(userId.(primitive.ObjectID), strings.ToUpper(c.Query("currency")))
 	if err != nil {
 		response.Message = err.Error()
 		response.SendResponse(c)
@@ -48,7 +48,7 @@ func UpdateProfile(c *gin.Context) {
 	}
 
 	// Update user profile (name only)
-	updatedUser, err := services.UpdateUserProfile(userObjID, request.Name)
+	updatedUser, err := services.UpdateUserProfile(userObjID, request.Name, request.Timezone)
 	if err != nil {
 		models.SendErrorResponse(c, http.StatusInternalServerError, "Failed to update profile")
 		return
@@ -33,7 +33,26 @@ import (
 func main() {
 	services.LoadConfig()
 	services.InitMongoDB()
+
+	// `main migrate [status]` manages the database schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrateCommand(os.Args[2:])
+		return
+	}
+
 	services.InitWebPush()
+	services.InitRateProvider()
+
+	// Bring the indexes and any legacy documents up to date before serving requests
+	if services.Config.MigrateOnStartup {
+		if err := services.RunMigrations(); err != nil {
+			log.Fatalf("Migrations failed: %s", err.Error())
+		}
+	}
+
+	services.StartReconciliationJob()
+	services.StartTrashPurgeJob()
+	services.StartRecurringExpenseJob()
 
 	if services.Config.UseRedis {
 		services.CheckRedisConnection()
@@ -64,7 +83,7 @@ func main() {
 
 	// Wait for interrupt signal to gracefully shut down the server with
 	// a timeout of 15 seconds.
-	quit := make(chan os.Signal)
+	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt)
 	<-quit
 	log.Println("Shutdown Server ...")
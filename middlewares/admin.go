@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models"
+	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/services"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+)
+
+// AdminMiddleware only lets users with the admin role through. It must run after JWTMiddleware.
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, exists := c.Get("userId")
+		if !exists {
+			models.SendErrorResponse(c, http.StatusUnauthorized, "cannot get user")
+			return
+		}
+
+		user, err := services.FindUserById(userId.(primitive.ObjectID))
+		if err != nil || user.Role != db.RoleAdmin {
+			models.SendErrorResponse(c, http.StatusForbidden, "admin access required")
+			return
+		}
+
+		c.Next()
+	}
+}
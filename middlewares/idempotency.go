@@ -0,0 +1,106 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models"
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/services"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// responseRecorder keeps a copy of everything written to the response
+type responseRecorder struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware makes a POST safe to retry. A request sent with an
+// Idempotency-Key header runs once; retries with the same key and body get the original
+// response back, and reusing the key for a different request is a 409. Requests without
+// the header are not affected. It must run after JWTMiddleware, since keys are per user.
+func IdempotencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > 255 {
+			models.SendErrorResponse(c, http.StatusBadRequest, "Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		userId, exists := c.Get("userId")
+		if !exists {
+			models.SendErrorResponse(c, http.StatusUnauthorized, "cannot get user")
+			return
+		}
+
+		body, err := c.GetRawData()
+		if err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, "cannot read request body")
+			return
+		}
+		// Validators and controllers bind with ShouldBindBodyWith, which reads the body from here
+		c.Set(gin.BodyBytesKey, body)
+
+		hash := sha256.New()
+		hash.Write([]byte(c.Request.Method + " " + c.Request.URL.Path + "\n"))
+		hash.Write(body)
+		requestHash := hex.EncodeToString(hash.Sum(nil))
+
+		request, existing, err := services.BeginIdempotentRequest(userId.(primitive.ObjectID), key, requestHash)
+		if err != nil {
+			models.SendErrorResponse(c, http.StatusInternalServerError, "cannot check Idempotency-Key")
+			return
+		}
+
+		if existing != nil {
+			switch {
+			case existing.RequestHash != requestHash:
+				models.SendErrorResponse(c, http.StatusConflict, "Idempotency-Key was already used for a different request")
+			case !existing.Completed:
+				models.SendErrorResponse(c, http.StatusConflict, "a request with this Idempotency-Key is still being processed")
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(existing.StatusCode, "application/json; charset=utf-8", existing.Body)
+				c.Abort()
+			}
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = recorder
+
+		// Server errors and panics are not remembered, so the request can be retried
+		completed := false
+		defer func() {
+			if !completed {
+				request.Abandon()
+			}
+		}()
+
+		c.Next()
+
+		if recorder.Status() < http.StatusInternalServerError {
+			request.Complete(recorder.Status(), recorder.body.Bytes())
+			completed = true
+		}
+	}
+}
@@ -36,6 +36,20 @@ func UpdateGroupValidator() gin.HandlerFunc {
 	}
 }
 
+func PinExchangeRateValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var pinExchangeRateRequest models.PinExchangeRateRequest
+		_ = c.ShouldBindBodyWith(&pinExchangeRateRequest, binding.JSON)
+
+		if err := pinExchangeRateRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
 func AddMemberToGroupValidator() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var addMemberRequest models.AddMemberToGroupRequest
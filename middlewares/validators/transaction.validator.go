@@ -0,0 +1,261 @@
+package validators
+
+import (
+	"net/http"
+
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+func CreateExpenseTransactionValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var createExpenseRequest models.CreateExpenseTransactionRequest
+		_ = c.ShouldBindBodyWith(&createExpenseRequest, binding.JSON)
+
+		if err := createExpenseRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func CreateSettlementTransactionValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var createSettlementRequest models.CreateSettlementTransactionRequest
+		_ = c.ShouldBindBodyWith(&createSettlementRequest, binding.JSON)
+
+		if err := createSettlementRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func CreateRefundTransactionValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var createRefundRequest models.CreateRefundTransactionRequest
+		_ = c.ShouldBindBodyWith(&createRefundRequest, binding.JSON)
+
+		if err := createRefundRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func CreateAdjustmentTransactionValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var createAdjustmentRequest models.CreateAdjustmentTransactionRequest
+		_ = c.ShouldBindBodyWith(&createAdjustmentRequest, binding.JSON)
+
+		if err := createAdjustmentRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func CreateFriendExpenseValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var createFriendExpenseRequest models.CreateFriendExpenseRequest
+		_ = c.ShouldBindBodyWith(&createFriendExpenseRequest, binding.JSON)
+
+		if err := createFriendExpenseRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func CreateFriendSettlementValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var createFriendSettlementRequest models.CreateFriendSettlementRequest
+		_ = c.ShouldBindBodyWith(&createFriendSettlementRequest, binding.JSON)
+
+		if err := createFriendSettlementRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func SettleUpWithFriendValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var settleUpRequest models.SettleUpWithFriendRequest
+		_ = c.ShouldBindBodyWith(&settleUpRequest, binding.JSON)
+
+		if err := settleUpRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func UpdateTransactionValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var updateTransactionRequest models.UpdateTransactionRequest
+		_ = c.ShouldBindBodyWith(&updateTransactionRequest, binding.JSON)
+
+		if err := updateTransactionRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func UpdateSettlementStatusValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var updateSettlementStatusRequest models.UpdateSettlementStatusRequest
+		_ = c.ShouldBindBodyWith(&updateSettlementStatusRequest, binding.JSON)
+
+		if err := updateSettlementStatusRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func RecordSettlementPaymentValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var recordSettlementPaymentRequest models.RecordSettlementPaymentRequest
+		_ = c.ShouldBindBodyWith(&recordSettlementPaymentRequest, binding.JSON)
+
+		if err := recordSettlementPaymentRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func BulkSettlementsValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var bulkSettlementsRequest models.BulkSettlementsTransactionRequest
+		_ = c.ShouldBindBodyWith(&bulkSettlementsRequest, binding.JSON)
+
+		if err := bulkSettlementsRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func CreateRecurringExpenseValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var createRecurringExpenseRequest models.CreateRecurringExpenseRequest
+		_ = c.ShouldBindBodyWith(&createRecurringExpenseRequest, binding.JSON)
+
+		if err := createRecurringExpenseRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func UpdateRecurringExpenseValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var updateRecurringExpenseRequest models.UpdateRecurringExpenseRequest
+		_ = c.ShouldBindBodyWith(&updateRecurringExpenseRequest, binding.JSON)
+
+		if err := updateRecurringExpenseRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func SkipRecurringOccurrenceValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var skipRecurringOccurrenceRequest models.SkipRecurringOccurrenceRequest
+		_ = c.ShouldBindBodyWith(&skipRecurringOccurrenceRequest, binding.JSON)
+
+		if err := skipRecurringOccurrenceRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func CreateRotationValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var createRotationRequest models.CreateRotationRequest
+		_ = c.ShouldBindBodyWith(&createRotationRequest, binding.JSON)
+
+		if err := createRotationRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func CreateCategoryValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var createCategoryRequest models.CreateCategoryRequest
+		_ = c.ShouldBindBodyWith(&createCategoryRequest, binding.JSON)
+
+		if err := createCategoryRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func UpdateCategoryValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var updateCategoryRequest models.UpdateCategoryRequest
+		_ = c.ShouldBindBodyWith(&updateCategoryRequest, binding.JSON)
+
+		if err := updateCategoryRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func CreateCategoryRuleValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var createCategoryRuleRequest models.CreateCategoryRuleRequest
+		_ = c.ShouldBindBodyWith(&createCategoryRuleRequest, binding.JSON)
+
+		if err := createCategoryRuleRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/services"
+)
+
+// runMigrateCommand applies pending migrations, or with "status" lists every migration
+// and whether it has been applied
+func runMigrateCommand(args []string) {
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		if err := services.RunMigrations(); err != nil {
+			log.Fatalf("Migrations failed: %s", err.Error())
+		}
+		log.Println("Migrations are up to date")
+	case "status":
+		states, err := services.GetMigrationStates()
+		if err != nil {
+			log.Fatalf("Cannot read migrations: %s", err.Error())
+		}
+		for _, state := range states {
+			applied := "pending"
+			if state.AppliedAt != nil {
+				applied = "applied " + state.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%3d  %-28s %s\n", state.Version, state.Name, applied)
+		}
+	default:
+		log.Fatalf("Unknown migrate command %q, expected up or status", command)
+	}
+}
@@ -24,6 +24,10 @@ type EnvConfig struct {
 	AWSAccessKeyID             string `mapstructure:"AWS_ACCESS_KEY_ID"`
 	AWSSecretAccessKey         string `mapstructure:"AWS_SECRET_ACCESS_KEY"`
 	AWSS3Endpoint              string `mapstructure:"AWS_S3_ENDPOINT"`
+	ExchangeRatesFile          string `mapstructure:"EXCHANGE_RATES_FILE"`
+	ReconcileIntervalMinutes   int    `mapstructure:"RECONCILE_INTERVAL_MINUTES"`
+	ReconcileAutoRepair        bool   `mapstructure:"RECONCILE_AUTO_REPAIR"`
+	MigrateOnStartup           bool   `mapstructure:"MIGRATE_ON_STARTUP"`
 }
 
 func (config *EnvConfig) Validate() error {
@@ -45,5 +49,7 @@ func (config *EnvConfig) Validate() error {
 		validation.Field(&config.VapidPublicKey, validation.Required),
 		validation.Field(&config.VapidPrivateKey, validation.Required),
 		validation.Field(&config.GoogleClientID, validation.Required),
+
+		validation.Field(&config.ReconcileIntervalMinutes, validation.Min(0)),
 	)
 }
@@ -7,7 +7,8 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// GroupBalance maintains running balance for each user in a group
+// GroupBalance maintains running balance for each user in a group. It is a projection of
+// the group's ledger entries and is only changed by applying them.
 type GroupBalance struct {
 	mgm.DefaultModel `bson:",inline"`
 
@@ -16,15 +17,15 @@ type GroupBalance struct {
 	UserName string             `json:"user_name" bson:"user_name"` // Denormalized for performance
 
 	// Balance tracking
-	Balance   float64 `json:"balance" bson:"balance"`       // Current net balance (positive = owed money, negative = owes money)
-	TotalPaid float64 `json:"total_paid" bson:"total_paid"` // Total amount this user has paid
-	TotalOwed float64 `json:"total_owed" bson:"total_owed"` // Total amount this user owes
+	Balance   Money `json:"balance" bson:"balance"`       // Current net balance (positive = owed money, negative = owes money)
+	TotalPaid Money `json:"total_paid" bson:"total_paid"` // Total amount this user has paid
+	TotalOwed Money `json:"total_owed" bson:"total_owed"` // Total amount this user owes
 
 	// Metadata
 	Currency          string             `json:"currency" bson:"currency"`
 	LastTransactionID primitive.ObjectID `json:"last_transaction_id" bson:"last_transaction_id"`
 	LastUpdated       time.Time          `json:"last_updated" bson:"last_updated"`
-	Version           int64              `json:"version" bson:"version"` // For optimistic locking
+	Version           int64              `json:"version" bson:"version"` // Bumped by every ledger entry, used to detect concurrent rebuilds
 }
 
 func NewGroupBalance(groupID, userID primitive.ObjectID, userName, currency string) *GroupBalance {
@@ -32,9 +33,9 @@ func NewGroupBalance(groupID, userID primitive.ObjectID, userName, currency stri
 		GroupID:     groupID,
 		UserID:      userID,
 		UserName:    userName,
-		Balance:     0.0,
-		TotalPaid:   0.0,
-		TotalOwed:   0.0,
+		Balance:     0,
+		TotalPaid:   0,
+		TotalOwed:   0,
 		Currency:    currency,
 		LastUpdated: time.Now(),
 		Version:     1,
@@ -44,13 +45,3 @@ func NewGroupBalance(groupID, userID primitive.ObjectID, userName, currency stri
 func (model *GroupBalance) CollectionName() string {
 	return "group_balances"
 }
-
-// UpdateBalance applies a transaction to the balance
-func (gb *GroupBalance) UpdateBalance(amountPaid, amountOwed float64, transactionID primitive.ObjectID) {
-	gb.TotalPaid += amountPaid
-	gb.TotalOwed += amountOwed
-	gb.Balance = gb.TotalPaid - gb.TotalOwed
-	gb.LastTransactionID = transactionID
-	gb.LastUpdated = time.Now()
-	gb.Version++
-}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"strings"
+
+	"github.com/kamva/mgm/v3"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DefaultCategoryID is the category of expenses no category or rule applies to
+const DefaultCategoryID = "general"
+
+// DefaultCategoryIcon is the icon of a group's own category when none is chosen
+const DefaultCategoryIcon = "tag"
+
+// CategoryInfo is a category expenses can be filed under. Built-in categories are
+// identified by a fixed key, a group's own by the hex of their ID.
+type CategoryInfo struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Icon    string `json:"icon"` // Key of the icon clients show
+	BuiltIn bool   `json:"built_in"`
+}
+
+// CategorySpending is a group's net spend in a category, in the group currency
+type CategorySpending struct {
+	CategoryInfo
+	Amount Money `json:"amount"`
+	Count  int   `json:"count"` // Expenses filed under it
+}
+
+// BuiltInCategories are the categories every group has
+var BuiltInCategories = []CategoryInfo{
+	{ID: DefaultCategoryID, Name: "General", Icon: "receipt", BuiltIn: true},
+	{ID: "food", Name: "Food & Drink", Icon: "utensils", BuiltIn: true},
+	{ID: "groceries", Name: "Groceries", Icon: "cart", BuiltIn: true},
+	{ID: "transport", Name: "Transport", Icon: "car", BuiltIn: true},
+	{ID: "housing", Name: "Housing", Icon: "home", BuiltIn: true},
+	{ID: "utilities", Name: "Utilities", Icon: "bolt", BuiltIn: true},
+	{ID: "entertainment", Name: "Entertainment", Icon: "film", BuiltIn: true},
+	{ID: "shopping", Name: "Shopping", Icon: "bag", BuiltIn: true},
+	{ID: "travel", Name: "Travel", Icon: "plane", BuiltIn: true},
+	{ID: "health", Name: "Health", Icon: "heart", BuiltIn: true},
+	{ID: "gifts", Name: "Gifts", Icon: "gift", BuiltIn: true},
+}
+
+// Category is a group's own expense category, next to the built-in ones
+type Category struct {
+	mgm.DefaultModel `bson:",inline"`
+	GroupID          primitive.ObjectID `json:"group_id" bson:"group_id"`
+	Name             string             `json:"name" bson:"name"`
+	NameKey          string             `json:"-" bson:"name_key"` // Unique per group
+	Icon             string             `json:"icon" bson:"icon"`
+	CreatedBy        primitive.ObjectID `json:"created_by" bson:"created_by"`
+}
+
+// CategoryRule files a user's expenses created without a category under CategoryID when
+// their description matches Pattern, a case-insensitive regular expression. Rules for a
+// group's own category only apply in that group.
+type CategoryRule struct {
+	mgm.DefaultModel `bson:",inline"`
+	UserID           primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Pattern          string             `json:"pattern" bson:"pattern"`
+	CategoryID       string             `json:"category_id" bson:"category_id"`
+	GroupID          primitive.ObjectID `json:"group_id,omitempty" bson:"group_id,omitempty"`
+	Priority         int                `json:"priority" bson:"priority"` // Lower runs first
+}
+
+func NewCategory(groupID primitive.ObjectID, name, icon string, createdBy primitive.ObjectID) *Category {
+	return &Category{
+		GroupID:   groupID,
+		Name:      strings.TrimSpace(name),
+		NameKey:   CategoryNameKey(name),
+		Icon:      icon,
+		CreatedBy: createdBy,
+	}
+}
+
+func NewCategoryRule(userID primitive.ObjectID, pattern, categoryID string, groupID primitive.ObjectID, priority int) *CategoryRule {
+	return &CategoryRule{
+		UserID:     userID,
+		Pattern:    pattern,
+		CategoryID: categoryID,
+		GroupID:    groupID,
+		Priority:   priority,
+	}
+}
+
+// Info describes the category the way built-in ones are
+func (model *Category) Info() CategoryInfo {
+	return CategoryInfo{ID: model.ID.Hex(), Name: model.Name, Icon: model.Icon}
+}
+
+// CategoryNameKey is how category names are compared, ignoring case and surrounding spaces
+func CategoryNameKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func (model *Category) CollectionName() string {
+	return "categories"
+}
+
+func (model *CategoryRule) CollectionName() string {
+	return "category_rules"
+}
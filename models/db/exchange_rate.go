@@ -0,0 +1,38 @@
+package db
+
+import (
+	"strings"
+	"time"
+
+	"github.com/kamva/mgm/v3"
+)
+
+// ExchangeRate is the rate between two currencies on a given day
+type ExchangeRate struct {
+	mgm.DefaultModel `bson:",inline"`
+	Base             string    `json:"base" bson:"base"`
+	Quote            string    `json:"quote" bson:"quote"`
+	Rate             float64   `json:"rate" bson:"rate"` // Units of Quote per unit of Base
+	Date             time.Time `json:"date" bson:"date"` // Midnight UTC of the day the rate applies to
+	Source           string    `json:"source" bson:"source"`
+}
+
+func NewExchangeRate(base, quote string, rate float64, date time.Time, source string) *ExchangeRate {
+	return &ExchangeRate{
+		Base:   strings.ToUpper(base),
+		Quote:  strings.ToUpper(quote),
+		Rate:   rate,
+		Date:   RateDay(date),
+		Source: source,
+	}
+}
+
+// RateDay truncates a time to the UTC day exchange rates are stored under
+func RateDay(date time.Time) time.Time {
+	year, month, day := date.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func (model *ExchangeRate) CollectionName() string {
+	return "exchange_rates"
+}
@@ -1,6 +1,8 @@
 package db
 
 import (
+	"strings"
+
 	"github.com/kamva/mgm/v3"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -12,7 +14,14 @@ type Group struct {
 	CreatedBy        primitive.ObjectID   `json:"created_by" bson:"created_by"`
 	Members          []primitive.ObjectID `json:"members" bson:"members"`
 	IsActive         bool                 `json:"is_active" bson:"is_active"`
-	Currency         string               `json:"currency" bson:"currency"` // USD, EUR, etc.
+	Currency         string               `json:"currency" bson:"currency"`                             // USD, EUR, etc.
+	Timezone         string               `json:"timezone,omitempty" bson:"timezone,omitempty"`         // IANA timezone days are counted in, UTC when unset
+	PinnedRates      map[string]float64   `json:"pinned_rates,omitempty" bson:"pinned_rates,omitempty"` // Group currency units per unit of the keyed currency
+
+	// Direct groups are managed by the server and hold the one-to-one expenses between two
+	// friends in one currency. They are hidden from the group endpoints.
+	Direct    bool   `json:"direct,omitempty" bson:"direct,omitempty"`
+	DirectKey string `json:"-" bson:"direct_key,omitempty"` // Identifies the pair and currency
 }
 
 func NewGroup(name, description string, createdBy primitive.ObjectID, currency string) *Group {
@@ -26,6 +35,25 @@ func NewGroup(name, description string, createdBy primitive.ObjectID, currency s
 	}
 }
 
+// NewDirectGroup creates the group holding direct expenses between two friends in a currency
+func NewDirectGroup(user, friend *User, currency string) *Group {
+	group := NewGroup(user.Name+" & "+friend.Name, "Direct expenses", user.ID, currency)
+	group.Members = append(group.Members, friend.ID)
+	group.Direct = true
+	group.DirectKey = DirectGroupKey(user.ID, friend.ID, currency)
+	return group
+}
+
+// DirectGroupKey identifies the direct group of two users in a currency, whichever of
+// them asks
+func DirectGroupKey(userID, friendID primitive.ObjectID, currency string) string {
+	first, second := userID.Hex(), friendID.Hex()
+	if second < first {
+		first, second = second, first
+	}
+	return first + ":" + second + ":" + strings.ToUpper(currency)
+}
+
 func (model *Group) CollectionName() string {
 	return "groups"
 }
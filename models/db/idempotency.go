@@ -0,0 +1,34 @@
+package db
+
+import (
+	"time"
+
+	"github.com/kamva/mgm/v3"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// IdempotencyRecord remembers a request made with an Idempotency-Key and, once it has
+// been handled, the response it got, so a retry gets the same response back
+type IdempotencyRecord struct {
+	mgm.DefaultModel `bson:",inline"`
+	UserID           primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Key              string             `json:"key" bson:"key"`
+	RequestHash      string             `json:"request_hash" bson:"request_hash"` // Method, path and body of the request
+	Completed        bool               `json:"completed" bson:"completed"`
+	StatusCode       int                `json:"status_code,omitempty" bson:"status_code,omitempty"`
+	Body             []byte             `json:"body,omitempty" bson:"body,omitempty"`
+	ExpiresAt        time.Time          `json:"expires_at" bson:"expires_at"`
+}
+
+func NewIdempotencyRecord(userID primitive.ObjectID, key, requestHash string, expiresAt time.Time) *IdempotencyRecord {
+	return &IdempotencyRecord{
+		UserID:      userID,
+		Key:         key,
+		RequestHash: requestHash,
+		ExpiresAt:   expiresAt,
+	}
+}
+
+func (model *IdempotencyRecord) CollectionName() string {
+	return "idempotency_keys"
+}
@@ -0,0 +1,44 @@
+package db
+
+import (
+	"github.com/kamva/mgm/v3"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LedgerOpeningBalance marks the entry that carries a balance over from before the ledger
+// existed. Opening entries have no transaction ID.
+const LedgerOpeningBalance TransactionType = "opening_balance"
+
+// LedgerEntry is an immutable record of what one transaction did to one member's balance
+// in a group, in the group currency. Entries are only ever inserted: editing or deleting a
+// transaction writes reversing entries. GroupBalance documents are a projection of them.
+type LedgerEntry struct {
+	mgm.DefaultModel `bson:",inline"`
+
+	GroupID         primitive.ObjectID `json:"group_id" bson:"group_id"`
+	UserID          primitive.ObjectID `json:"user_id" bson:"user_id"`
+	UserName        string             `json:"user_name" bson:"user_name"`
+	TransactionID   primitive.ObjectID `json:"transaction_id" bson:"transaction_id"`
+	TransactionType TransactionType    `json:"transaction_type" bson:"transaction_type"`
+
+	PaidDelta Money  `json:"paid_delta" bson:"paid_delta"`
+	OwedDelta Money  `json:"owed_delta" bson:"owed_delta"`
+	Currency  string `json:"currency" bson:"currency"`
+}
+
+func NewLedgerEntry(groupID, userID primitive.ObjectID, userName string, transactionID primitive.ObjectID, transactionType TransactionType, paidDelta, owedDelta Money, currency string) *LedgerEntry {
+	return &LedgerEntry{
+		GroupID:         groupID,
+		UserID:          userID,
+		UserName:        userName,
+		TransactionID:   transactionID,
+		TransactionType: transactionType,
+		PaidDelta:       paidDelta,
+		OwedDelta:       owedDelta,
+		Currency:        currency,
+	}
+}
+
+func (model *LedgerEntry) CollectionName() string {
+	return "ledger_entries"
+}
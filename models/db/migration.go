@@ -0,0 +1,29 @@
+package db
+
+import (
+	"time"
+
+	"github.com/kamva/mgm/v3"
+)
+
+// SchemaMigration records a migration that has been applied to the database
+type SchemaMigration struct {
+	mgm.DefaultModel `bson:",inline"`
+	Version          int       `json:"version" bson:"version"`
+	Name             string    `json:"name" bson:"name"`
+	AppliedAt        time.Time `json:"applied_at" bson:"applied_at"`
+	DurationMs       int64     `json:"duration_ms" bson:"duration_ms"`
+}
+
+func NewSchemaMigration(version int, name string, duration time.Duration) *SchemaMigration {
+	return &SchemaMigration{
+		Version:    version,
+		Name:       name,
+		AppliedAt:  time.Now(),
+		DurationMs: duration.Milliseconds(),
+	}
+}
+
+func (model *SchemaMigration) CollectionName() string {
+	return "schema_migrations"
+}
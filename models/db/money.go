@@ -0,0 +1,151 @@
+package db
+
+import (
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount expressed in the minor units of its currency (cents for
+// USD, yen for JPY, fils for KWD). Every ledger amount is stored as Money so
+// balances are exact integers and never drift the way float64 sums do.
+type Money int64
+
+// defaultCurrencyExponent is used for any currency not listed below
+const defaultCurrencyExponent = 2
+
+// currencyExponents lists ISO 4217 currencies whose minor unit is not 1/100
+var currencyExponents = map[string]int{
+	// Zero-decimal currencies
+	"BIF": 0,
+	"CLP": 0,
+	"DJF": 0,
+	"GNF": 0,
+	"ISK": 0,
+	"JPY": 0,
+	"KMF": 0,
+	"KRW": 0,
+	"PYG": 0,
+	"RWF": 0,
+	"UGX": 0,
+	"UYI": 0,
+	"VND": 0,
+	"VUV": 0,
+	"XAF": 0,
+	"XOF": 0,
+	"XPF": 0,
+
+	// Three-decimal currencies
+	"BHD": 3,
+	"IQD": 3,
+	"JOD": 3,
+	"KWD": 3,
+	"LYD": 3,
+	"OMR": 3,
+	"TND": 3,
+}
+
+// CurrencyExponent returns the number of decimal places of a currency's minor unit
+func CurrencyExponent(currency string) int {
+	if exponent, ok := currencyExponents[strings.ToUpper(currency)]; ok {
+		return exponent
+	}
+	return defaultCurrencyExponent
+}
+
+// MoneyFromFloat converts a decimal amount (e.g. 12.34) into minor units of the
+// given currency, rounding half away from zero. It is only meant for legacy
+// data and display input; ledger code should never go through float64.
+func MoneyFromFloat(amount float64, currency string) Money {
+	scale := math.Pow10(CurrencyExponent(currency))
+	return Money(math.Round(amount * scale))
+}
+
+// Float returns the amount as a decimal number in the given currency
+func (m Money) Float(currency string) float64 {
+	return float64(m) / math.Pow10(CurrencyExponent(currency))
+}
+
+// Format renders the amount as a fixed-point decimal string (e.g. "12.34", "1500", "1.250")
+func (m Money) Format(currency string) string {
+	exponent := CurrencyExponent(currency)
+	if exponent == 0 {
+		return strconv.FormatInt(int64(m), 10)
+	}
+
+	sign := ""
+	value := int64(m)
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+
+	digits := strconv.FormatInt(value, 10)
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+
+	split := len(digits) - exponent
+	return sign + digits[:split] + "." + digits[split:]
+}
+
+// Abs returns the absolute value of the amount
+func (m Money) Abs() Money {
+	if m < 0 {
+		return -m
+	}
+	return m
+}
+
+// Allocate divides the amount in proportion to weights using the largest
+// remainder method. Every part is first rounded down, then the leftover minor
+// units go one by one to the parts with the largest remainders, ties going to
+// the lower index. The parts always add up exactly to the original amount and
+// the result depends only on the inputs, so every client computes the same split.
+// Weights must not be negative. The shares are worked out with arbitrary precision, as
+// a large amount times a large weight does not fit in an int64.
+func (m Money) Allocate(weights []int64) []Money {
+	parts := make([]Money, len(weights))
+
+	totalWeight := new(big.Int)
+	for _, weight := range weights {
+		totalWeight.Add(totalWeight, big.NewInt(weight))
+	}
+	if totalWeight.Sign() <= 0 {
+		return parts
+	}
+
+	amount := int64(m.Abs())
+	remainders := make([]*big.Int, len(weights))
+	var allocated int64
+	for i, weight := range weights {
+		share := new(big.Int).Mul(big.NewInt(amount), big.NewInt(weight))
+		quotient, remainder := new(big.Int).QuoRem(share, totalWeight, new(big.Int))
+		parts[i] = Money(quotient.Int64())
+		remainders[i] = remainder
+		allocated += int64(parts[i])
+	}
+
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]].Cmp(remainders[order[b]]) > 0
+	})
+
+	for i := 0; allocated < amount; i++ {
+		parts[order[i%len(order)]]++
+		allocated++
+	}
+
+	if m < 0 {
+		for i := range parts {
+			parts[i] = -parts[i]
+		}
+	}
+
+	return parts
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestMoneyAllocate(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  Money
+		weights []int64
+		want    []Money
+	}{
+		{"even split", 90, []int64{1, 1, 1}, []Money{30, 30, 30}},
+		{"leftover goes to the lower index on a tie", 100, []int64{1, 1, 1}, []Money{34, 33, 33}},
+		{"two leftovers", 2, []int64{1, 1, 1}, []Money{1, 1, 0}},
+		{"leftover goes to the largest remainder", 1000, []int64{1, 2, 3}, []Money{167, 333, 500}},
+		{"largest remainder beats lower index", 10, []int64{1, 2}, []Money{3, 7}},
+		{"zero weight gets nothing", 10, []int64{0, 1, 1}, []Money{0, 5, 5}},
+		{"negative amount", -100, []int64{1, 1, 1}, []Money{-34, -33, -33}},
+		{"zero amount", 0, []int64{1, 2}, []Money{0, 0}},
+		{"no weights", 100, []int64{0, 0}, []Money{0, 0}},
+		{"percentage weights", 1001, []int64{3333, 3333, 3334}, []Money{334, 333, 334}},
+		{"amount times weight past int64", 1_000_000_000_000_000, []int64{3_000_000_000, 1_000_000_000}, []Money{750_000_000_000_000, 250_000_000_000_000}},
+		{"weights adding up past int64", math.MaxInt64, []int64{math.MaxInt64, math.MaxInt64}, []Money{4611686018427387904, 4611686018427387903}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.amount.Allocate(tt.weights)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Allocate(%v) of %d = %v, want %v", tt.weights, tt.amount, got, tt.want)
+			}
+
+		})
+	}
+}
+
+func TestMoneyFromFloat(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		currency string
+		want     Money
+	}{
+		{12.34, "USD", 1234},
+		{0.1 + 0.2, "EUR", 30},
+		{1500, "JPY", 1500},
+		{1.25, "KWD", 1250},
+		{-7.5, "usd", -750},
+	}
+
+	for _, tt := range tests {
+		if got := MoneyFromFloat(tt.amount, tt.currency); got != tt.want {
+			t.Errorf("MoneyFromFloat(%v, %s) = %d, want %d", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyFormat(t *testing.T) {
+	tests := []struct {
+		amount   Money
+		currency string
+		want     string
+	}{
+		{1234, "USD", "12.34"},
+		{5, "USD", "0.05"},
+		{-5, "USD", "-0.05"},
+		{0, "USD", "0.00"},
+		{1500, "JPY", "1500"},
+		{1250, "KWD", "1.250"},
+		{-1, "KWD", "-0.001"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.amount.Format(tt.currency); got != tt.want {
+			t.Errorf("Money(%d).Format(%s) = %q, want %q", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"time"
+
+	"github.com/kamva/mgm/v3"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LedgerReconciliation marks a ledger entry written by the reconciliation job to bring a
+// member's balance back in line with the group's transactions
+const LedgerReconciliation TransactionType = "reconciliation"
+
+// BalanceDiscrepancy is a member whose stored balance or ledger does not match what the
+// group's transactions add up to
+type BalanceDiscrepancy struct {
+	GroupID  primitive.ObjectID `json:"group_id" bson:"group_id"`
+	UserID   primitive.ObjectID `json:"user_id" bson:"user_id"`
+	UserName string             `json:"user_name" bson:"user_name"`
+
+	ExpectedPaid    Money `json:"expected_paid" bson:"expected_paid"`
+	ExpectedOwed    Money `json:"expected_owed" bson:"expected_owed"`
+	ExpectedBalance Money `json:"expected_balance" bson:"expected_balance"`
+	LedgerBalance   Money `json:"ledger_balance" bson:"ledger_balance"`
+	StoredPaid      Money `json:"stored_paid" bson:"stored_paid"`
+	StoredOwed      Money `json:"stored_owed" bson:"stored_owed"`
+	StoredBalance   Money `json:"stored_balance" bson:"stored_balance"`
+}
+
+// GroupImbalance is a group whose stored balances do not add up to zero
+type GroupImbalance struct {
+	GroupID primitive.ObjectID `json:"group_id" bson:"group_id"`
+	Sum     Money              `json:"sum" bson:"sum"`
+}
+
+// ReconciliationReport is the outcome of one run of the balance reconciliation job
+type ReconciliationReport struct {
+	mgm.DefaultModel `bson:",inline"`
+
+	StartedAt     time.Time            `json:"started_at" bson:"started_at"`
+	FinishedAt    time.Time            `json:"finished_at" bson:"finished_at"`
+	GroupsChecked int                  `json:"groups_checked" bson:"groups_checked"`
+	GroupsSkipped []primitive.ObjectID `json:"groups_skipped" bson:"groups_skipped"` // Busy groups, checked on the next run
+	Discrepancies []BalanceDiscrepancy `json:"discrepancies" bson:"discrepancies"`
+	Imbalances    []GroupImbalance     `json:"imbalances" bson:"imbalances"`
+
+	AutoRepair     bool                 `json:"auto_repair" bson:"auto_repair"`
+	RepairedGroups []primitive.ObjectID `json:"repaired_groups" bson:"repaired_groups"`
+	Errors         []string             `json:"errors" bson:"errors"`
+}
+
+func NewReconciliationReport(autoRepair bool) *ReconciliationReport {
+	return &ReconciliationReport{
+		StartedAt:      time.Now(),
+		GroupsSkipped:  []primitive.ObjectID{},
+		Discrepancies:  []BalanceDiscrepancy{},
+		Imbalances:     []GroupImbalance{},
+		AutoRepair:     autoRepair,
+		RepairedGroups: []primitive.ObjectID{},
+		Errors:         []string{},
+	}
+}
+
+func (model *ReconciliationReport) CollectionName() string {
+	return "reconciliation_reports"
+}
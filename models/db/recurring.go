@@ -0,0 +1,90 @@
+package db
+
+import (
+	"time"
+
+	"github.com/kamva/mgm/v3"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type RecurrenceFrequency string
+
+const (
+	// Recurrence Frequencies
+	RecurrenceDaily   RecurrenceFrequency = "daily"
+	RecurrenceWeekly  RecurrenceFrequency = "weekly"
+	RecurrenceMonthly RecurrenceFrequency = "monthly" // On DayOfMonth, or the last day of shorter months
+	RecurrenceYearly  RecurrenceFrequency = "yearly"
+)
+
+// RecurringPayer is who pays each occurrence of a recurring expense
+type RecurringPayer struct {
+	UserID primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Amount Money              `json:"amount" bson:"amount"`
+}
+
+// RecurringSplit is how each occurrence of a recurring expense is divided. Like split
+// requests, which field is used depends on the split type.
+type RecurringSplit struct {
+	UserID     primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Amount     Money              `json:"amount,omitempty" bson:"amount,omitempty"`
+	Percentage float64            `json:"percentage,omitempty" bson:"percentage,omitempty"`
+	Shares     int64              `json:"shares,omitempty" bson:"shares,omitempty"`
+}
+
+// RecurringExpense is an expense template that is turned into a new expense on every
+// occurrence of its schedule. Occurrence n falls Interval*n days, weeks, months or years
+// after StartDate.
+type RecurringExpense struct {
+	mgm.DefaultModel `bson:",inline"`
+	GroupID          primitive.ObjectID `json:"group_id" bson:"group_id"`
+	CreatedBy        primitive.ObjectID `json:"created_by" bson:"created_by"` // Occurrences are created on their behalf
+	UpdatedBy        primitive.ObjectID `json:"updated_by,omitempty" bson:"updated_by,omitempty"`
+
+	// Template
+	Description string            `json:"description" bson:"description"`
+	Amount      Money             `json:"amount" bson:"amount"`
+	Currency    string            `json:"currency" bson:"currency"`
+	SplitType   SplitType         `json:"split_type" bson:"split_type"`
+	Payers      []RecurringPayer  `json:"payers" bson:"payers"`
+	Splits      []RecurringSplit  `json:"splits,omitempty" bson:"splits,omitempty"`
+	Items       []TransactionItem `json:"items,omitempty" bson:"items,omitempty"`
+	Category    string            `json:"category" bson:"category"` // Category ID
+	Notes       string            `json:"notes,omitempty" bson:"notes,omitempty"`
+
+	// Schedule
+	Frequency      RecurrenceFrequency `json:"frequency" bson:"frequency"`
+	Interval       int                 `json:"interval" bson:"interval"`
+	DayOfMonth     int                 `json:"day_of_month,omitempty" bson:"day_of_month,omitempty"` // Monthly only
+	StartDate      time.Time           `json:"start_date" bson:"start_date"`                         // The first occurrence
+	EndDate        *time.Time          `json:"end_date,omitempty" bson:"end_date,omitempty"`
+	NextIndex      int                 `json:"next_index" bson:"next_index"`
+	NextOccurrence *time.Time          `json:"next_occurrence,omitempty" bson:"next_occurrence,omitempty"` // Unset once the schedule has ended
+	SkippedDates   []time.Time         `json:"skipped_dates,omitempty" bson:"skipped_dates,omitempty"`
+	Paused         bool                `json:"paused" bson:"paused"`
+	LastError      string              `json:"last_error,omitempty" bson:"last_error,omitempty"` // Why the scheduler paused it
+}
+
+// RecurringOccurrence is an upcoming occurrence of a recurring expense
+type RecurringOccurrence struct {
+	Date    time.Time `json:"date"`
+	Skipped bool      `json:"skipped"`
+}
+
+func NewRecurringExpense(groupID primitive.ObjectID, description string, amount Money, currency string, splitType SplitType, category string, createdBy primitive.ObjectID) *RecurringExpense {
+	return &RecurringExpense{
+		GroupID:     groupID,
+		CreatedBy:   createdBy,
+		Description: description,
+		Amount:      amount,
+		Currency:    currency,
+		SplitType:   splitType,
+		Category:    category,
+		Payers:      []RecurringPayer{},
+		Interval:    1,
+	}
+}
+
+func (model *RecurringExpense) CollectionName() string {
+	return "recurring_expenses"
+}
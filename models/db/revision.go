@@ -0,0 +1,57 @@
+package db
+
+import (
+	"github.com/kamva/mgm/v3"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type RevisionAction string
+
+const (
+	// Revision Actions
+	RevisionCreate   RevisionAction = "create"
+	RevisionEdit     RevisionAction = "edit"
+	RevisionStatus   RevisionAction = "status"   // A settlement moved to another state
+	RevisionPayment  RevisionAction = "payment"  // A payment was recorded or confirmed
+	RevisionComplete RevisionAction = "complete" // A settlement was confirmed or paid in full
+	RevisionDelete   RevisionAction = "delete"
+	RevisionRestore  RevisionAction = "restore"
+)
+
+// FieldChange is one field that differs between two versions of a transaction. Nested
+// fields are named by their path, e.g. "splits.1.amount".
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// TransactionRevision is an immutable record of one change to a transaction, with the
+// transaction as it was before and after. Creations have no before.
+type TransactionRevision struct {
+	mgm.DefaultModel `bson:",inline"`
+	TransactionID    primitive.ObjectID `json:"transaction_id" bson:"transaction_id"`
+	GroupID          primitive.ObjectID `json:"group_id" bson:"group_id"`
+	Action           RevisionAction     `json:"action" bson:"action"`
+	ActorID          primitive.ObjectID `json:"actor_id" bson:"actor_id"`
+	ActorName        string             `json:"actor_name" bson:"actor_name"`
+	Before           *Transaction       `json:"before,omitempty" bson:"before,omitempty"`
+	After            *Transaction       `json:"after" bson:"after"`
+	Changes          []FieldChange      `json:"changes,omitempty" bson:"-"` // Computed field
+}
+
+func NewTransactionRevision(action RevisionAction, before, after *Transaction, actorID primitive.ObjectID, actorName string) *TransactionRevision {
+	return &TransactionRevision{
+		TransactionID: after.ID,
+		GroupID:       after.GroupID,
+		Action:        action,
+		ActorID:       actorID,
+		ActorName:     actorName,
+		Before:        before,
+		After:         after,
+	}
+}
+
+func (model *TransactionRevision) CollectionName() string {
+	return "transaction_revisions"
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"time"
+
+	"github.com/kamva/mgm/v3"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type RotationStrategy string
+
+const (
+	// Rotation Strategies
+	RotationFixedOrder RotationStrategy = "fixed"   // Members take turns in order
+	RotationBalance    RotationStrategy = "balance" // Whoever has the lowest group balance pays next
+)
+
+// RotationTurn is an expense someone paid as their turn in a rotation
+type RotationTurn struct {
+	UserID        primitive.ObjectID `json:"user_id" bson:"user_id"`
+	TransactionID primitive.ObjectID `json:"transaction_id" bson:"transaction_id"`
+	PaidAt        time.Time          `json:"paid_at" bson:"paid_at"`
+}
+
+// Rotation takes turns paying for the expenses of one category in a group, such as a
+// shared subscription or coffee runs. Every expense filed under the category counts as
+// its payer's turn.
+type Rotation struct {
+	mgm.DefaultModel `bson:",inline"`
+	GroupID          primitive.ObjectID   `json:"group_id" bson:"group_id"`
+	Name             string               `json:"name" bson:"name"`
+	CategoryID       string               `json:"category_id" bson:"category_id"`
+	Category         string               `json:"category" bson:"category"` // Name of the category, kept in step when it is renamed
+	Strategy         RotationStrategy     `json:"strategy" bson:"strategy"`
+	Members          []primitive.ObjectID `json:"members" bson:"members"`                 // In turn order
+	NextIndex        int                  `json:"next_index" bson:"next_index"`           // Whose turn it is in a fixed order
+	Turns            []RotationTurn       `json:"turns,omitempty" bson:"turns,omitempty"` // The most recent turns, oldest first
+	CreatedBy        primitive.ObjectID   `json:"created_by" bson:"created_by"`
+}
+
+// RotationSuggestion is who should pay the next expense of a rotation
+type RotationSuggestion struct {
+	RotationID primitive.ObjectID `json:"rotation_id"`
+	Name       string             `json:"name"`
+	CategoryID string             `json:"category_id"`
+	Category   string             `json:"category"`
+	Strategy   RotationStrategy   `json:"strategy"`
+	UserID     primitive.ObjectID `json:"user_id"`
+	UserName   string             `json:"user_name"`
+	Balance    *Money             `json:"balance,omitempty"` // Their group balance, for the balance strategy
+}
+
+func NewRotation(groupID primitive.ObjectID, name string, category CategoryInfo, strategy RotationStrategy, members []primitive.ObjectID, createdBy primitive.ObjectID) *Rotation {
+	return &Rotation{
+		GroupID:    groupID,
+		Name:       name,
+		CategoryID: category.ID,
+		Category:   category.Name,
+		Strategy:   strategy,
+		Members:    members,
+		CreatedBy:  createdBy,
+	}
+}
+
+func (model *Rotation) CollectionName() string {
+	return "rotations"
+}
@@ -17,6 +17,17 @@ const (
 	TransactionTypeAdjustment TransactionType = "adjustment"
 )
 
+type SettlementStatus string
+
+const (
+	// Settlement States. Only a confirmed settlement counts towards balances.
+	SettlementProposed  SettlementStatus = "proposed"
+	SettlementPaid      SettlementStatus = "paid"      // The payer says they have paid
+	SettlementConfirmed SettlementStatus = "confirmed" // The payee has received the money
+	SettlementDisputed  SettlementStatus = "disputed"  // The payee has not received the money
+	SettlementCancelled SettlementStatus = "cancelled"
+)
+
 type SplitType string
 
 const (
@@ -24,31 +35,82 @@ const (
 	SplitTypeEqual      SplitType = "equal"
 	SplitTypeExact      SplitType = "exact"
 	SplitTypePercentage SplitType = "percentage"
+	SplitTypeShares     SplitType = "shares"
+	SplitTypeItemized   SplitType = "itemized"
+)
+
+type ItemType string
+
+const (
+	// Receipt line types for itemized expenses
+	ItemTypeItem          ItemType = "item"
+	ItemTypeTax           ItemType = "tax"
+	ItemTypeTip           ItemType = "tip"
+	ItemTypeServiceCharge ItemType = "service_charge"
 )
 
+// TransactionItem is a line of an itemized receipt. Items are shared equally by their
+// users; tax, tip and service charge lines are spread over everyone in proportion to
+// their item subtotal.
+type TransactionItem struct {
+	Name    string               `json:"name" bson:"name"`
+	Type    ItemType             `json:"type" bson:"type"`
+	Price   Money                `json:"price" bson:"price"`
+	UserIDs []primitive.ObjectID `json:"user_ids,omitempty" bson:"user_ids,omitempty"` // Who shares the item
+}
+
 // TransactionPayer represents who actually paid money
 type TransactionPayer struct {
-	UserID        primitive.ObjectID `json:"user_id" bson:"user_id"`
-	UserName      string             `json:"user_name" bson:"user_name"`
-	Amount        float64            `json:"amount" bson:"amount"` // Amount they paid
-	ProfilePicUrl string             `json:"profile_pic_url,omitempty" bson:"-"` // Computed field
+	UserID          primitive.ObjectID `json:"user_id" bson:"user_id"`
+	UserName        string             `json:"user_name" bson:"user_name"`
+	Amount          Money              `json:"amount" bson:"amount"`                     // Amount they paid
+	ConvertedAmount Money              `json:"converted_amount" bson:"converted_amount"` // Amount they paid in the group currency
+	ProfilePicUrl   string             `json:"profile_pic_url,omitempty" bson:"-"`       // Computed field
 }
 
 // TransactionSplit represents how the expense should be divided
 type TransactionSplit struct {
-	UserID        primitive.ObjectID `json:"user_id" bson:"user_id"`
-	UserName      string             `json:"user_name" bson:"user_name"`
-	Amount        float64            `json:"amount" bson:"amount"` // Amount they owe
-	ProfilePicUrl string             `json:"profile_pic_url,omitempty" bson:"-"` // Computed field
+	UserID          primitive.ObjectID `json:"user_id" bson:"user_id"`
+	UserName        string             `json:"user_name" bson:"user_name"`
+	Amount          Money              `json:"amount" bson:"amount"`                             // Amount they owe
+	ConvertedAmount Money              `json:"converted_amount" bson:"converted_amount"`         // Amount they owe in the group currency
+	Percentage      float64            `json:"percentage,omitempty" bson:"percentage,omitempty"` // Requested percentage (percentage splits)
+	Shares          int64              `json:"shares,omitempty" bson:"shares,omitempty"`         // Requested weight (shares splits)
+	ProfilePicUrl   string             `json:"profile_pic_url,omitempty" bson:"-"`               // Computed field
 }
 
-// TransactionParticipant represents a user's net involvement (for backward compatibility)
+// TransactionParticipant represents a user's net involvement. It is computed from the
+// payers and splits for API compatibility and never read to move balances.
 type TransactionParticipant struct {
-	UserID        primitive.ObjectID `json:"user_id" bson:"user_id"`
-	UserName      string             `json:"user_name" bson:"user_name"`
-	Amount        float64            `json:"amount" bson:"amount"`         // Net amount (paid - owed)
-	ShareType     string             `json:"share_type" bson:"share_type"` // "payer", "split", "both"
-	ProfilePicUrl string             `json:"profile_pic_url,omitempty" bson:"-"` // Computed field
+	UserID          primitive.ObjectID `json:"user_id" bson:"user_id"`
+	UserName        string             `json:"user_name" bson:"user_name"`
+	Amount          Money              `json:"amount" bson:"amount"`                     // Net amount (paid - owed)
+	ConvertedAmount Money              `json:"converted_amount" bson:"converted_amount"` // Net amount in the group currency
+	ShareType       string             `json:"share_type" bson:"share_type"`             // "payer", "split", "both"
+	ProfilePicUrl   string             `json:"profile_pic_url,omitempty" bson:"-"`       // Computed field
+}
+
+// SettlementStatusChange records who moved a settlement into a state and when
+type SettlementStatusChange struct {
+	Status    SettlementStatus   `json:"status" bson:"status"`
+	ChangedBy primitive.ObjectID `json:"changed_by" bson:"changed_by"`
+	ChangedAt time.Time          `json:"changed_at" bson:"changed_at"`
+	Notes     string             `json:"notes,omitempty" bson:"notes,omitempty"`
+}
+
+// SettlementPayment is an installment paid towards a settlement. It moves balances once
+// the payee has confirmed receiving it.
+type SettlementPayment struct {
+	ID              primitive.ObjectID `json:"id" bson:"_id"`
+	Amount          Money              `json:"amount" bson:"amount"`                     // In the settlement currency
+	ConvertedAmount Money              `json:"converted_amount" bson:"converted_amount"` // In the group currency, set on confirmation
+	Date            time.Time          `json:"date" bson:"date"`
+	Method          string             `json:"method,omitempty" bson:"method,omitempty"`
+	ProofOfPayment  string             `json:"proof_of_payment,omitempty" bson:"proof_of_payment,omitempty"`
+	Notes           string             `json:"notes,omitempty" bson:"notes,omitempty"`
+	RecordedBy      primitive.ObjectID `json:"recorded_by" bson:"recorded_by"`
+	RecordedAt      time.Time          `json:"recorded_at" bson:"recorded_at"`
+	ConfirmedAt     *time.Time         `json:"confirmed_at,omitempty" bson:"confirmed_at,omitempty"`
 }
 
 // Transaction replaces both Expense and Settlement models
@@ -59,26 +121,53 @@ type Transaction struct {
 	GroupID     primitive.ObjectID `json:"group_id" bson:"group_id"`
 	Type        TransactionType    `json:"type" bson:"type"`
 	Description string             `json:"description" bson:"description"`
-	Amount      float64            `json:"amount" bson:"amount"` // Total transaction amount
+	Amount      Money              `json:"amount" bson:"amount"` // Total transaction amount in minor units of Currency
 	Currency    string             `json:"currency" bson:"currency"`
 	Date        time.Time          `json:"date" bson:"date"`
+	Timezone    string             `json:"timezone,omitempty" bson:"timezone,omitempty"` // IANA timezone Date was entered in
+
+	// Conversion into the group currency. Amount and Currency above are what was
+	// entered; balances only ever move by the converted amounts in GroupCurrency.
+	GroupCurrency   string  `json:"group_currency" bson:"group_currency"`
+	ExchangeRate    float64 `json:"exchange_rate" bson:"exchange_rate"` // Units of GroupCurrency per unit of Currency
+	ConvertedAmount Money   `json:"converted_amount" bson:"converted_amount"`
 
-	// New structure for expenses
+	// Who paid and who owes, the one representation balances are worked out from. A
+	// settlement's payer is its only payer and its payee its only split; an adjustment
+	// credits its payers and debits its splits.
 	Payers []TransactionPayer `json:"payers,omitempty" bson:"payers,omitempty"` // Who paid money
 	Splits []TransactionSplit `json:"splits,omitempty" bson:"splits,omitempty"` // How expense is divided
+	Items  []TransactionItem  `json:"items,omitempty" bson:"items,omitempty"`   // Receipt lines of an itemized expense
 
-	// Legacy participants field (for backward compatibility and settlements)
+	// Net involvement per member, computed from Payers and Splits by ComputeParticipants
 	Participants []TransactionParticipant `json:"participants" bson:"participants"`
 
 	// Expense-specific fields (only for expense type)
-	Category  string    `json:"category,omitempty" bson:"category,omitempty"`
-	SplitType SplitType `json:"split_type,omitempty" bson:"split_type,omitempty"`
-	Receipt   string    `json:"receipt,omitempty" bson:"receipt,omitempty"`
+	CategoryID string    `json:"category_id,omitempty" bson:"category_id,omitempty"` // Built-in key or the hex ID of a group's own category
+	Category   string    `json:"category,omitempty" bson:"category,omitempty"`       // Name of the category when it was filed
+	SplitType  SplitType `json:"split_type,omitempty" bson:"split_type,omitempty"`
+	Receipt    string    `json:"receipt,omitempty" bson:"receipt,omitempty"`
+
+	// Set on expenses created from a recurring expense
+	RecurringExpenseID primitive.ObjectID `json:"recurring_expense_id,omitempty" bson:"recurring_expense_id,omitempty"`
 
 	// Settlement-specific fields (only for settlement type)
-	SettledAt        *time.Time `json:"settled_at,omitempty" bson:"settled_at,omitempty"`
-	SettlementMethod string     `json:"settlement_method,omitempty" bson:"settlement_method,omitempty"`
-	ProofOfPayment   string     `json:"proof_of_payment,omitempty" bson:"proof_of_payment,omitempty"`
+	SettledAt        *time.Time               `json:"settled_at,omitempty" bson:"settled_at,omitempty"`
+	SettlementMethod string                   `json:"settlement_method,omitempty" bson:"settlement_method,omitempty"`
+	ProofOfPayment   string                   `json:"proof_of_payment,omitempty" bson:"proof_of_payment,omitempty"`
+	BatchID          primitive.ObjectID       `json:"batch_id,omitempty" bson:"batch_id,omitempty"` // Links settlements created together
+	Status           SettlementStatus         `json:"status,omitempty" bson:"status,omitempty"`
+	StatusHistory    []SettlementStatusChange `json:"status_history,omitempty" bson:"status_history,omitempty"`
+	Payments         []SettlementPayment      `json:"payments,omitempty" bson:"payments,omitempty"`
+	AmountPaid       Money                    `json:"amount_paid,omitempty" bson:"amount_paid,omitempty"` // Confirmed so far, in the settlement currency
+	Outstanding      Money                    `json:"outstanding,omitempty" bson:"outstanding,omitempty"` // Left to pay, in the settlement currency
+
+	// Refund-specific fields (only for refund type). Payers are who got the money back,
+	// splits are how much each person's share of the expense goes down.
+	RefundOf primitive.ObjectID `json:"refund_of,omitempty" bson:"refund_of,omitempty"` // Expense being refunded
+
+	// Adjustment-specific fields (only for adjustment type)
+	Reason string `json:"reason,omitempty" bson:"reason,omitempty"` // Why balances were corrected
 
 	// Common fields
 	Notes                 string             `json:"notes" bson:"notes"`
@@ -89,9 +178,14 @@ type Transaction struct {
 	// Audit trail
 	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
 	UpdatedBy primitive.ObjectID `json:"updated_by,omitempty" bson:"updated_by,omitempty"`
+
+	// Trash. A deleted transaction no longer counts towards balances and can be restored
+	// until it is purged.
+	DeletedAt *time.Time         `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+	DeletedBy primitive.ObjectID `json:"deleted_by,omitempty" bson:"deleted_by,omitempty"`
 }
 
-func NewExpenseTransaction(groupID primitive.ObjectID, description string, amount float64, currency string, paidBy primitive.ObjectID, splitType SplitType, category string) *Transaction {
+func NewExpenseTransaction(groupID primitive.ObjectID, description string, amount Money, currency string, paidBy primitive.ObjectID, splitType SplitType, category string) *Transaction {
 	return &Transaction{
 		GroupID:      groupID,
 		Type:         TransactionTypeExpense,
@@ -99,6 +193,7 @@ func NewExpenseTransaction(groupID primitive.ObjectID, description string, amoun
 		Amount:       amount,
 		Currency:     currency,
 		Date:         time.Now(),
+		ExchangeRate: 1,
 		Category:     category,
 		SplitType:    splitType,
 		Participants: []TransactionParticipant{},
@@ -108,30 +203,121 @@ func NewExpenseTransaction(groupID primitive.ObjectID, description string, amoun
 	}
 }
 
-func NewSettlementTransaction(groupID, payerID, payeeID primitive.ObjectID, amount float64, currency string) *Transaction {
+func NewSettlementTransaction(groupID, payerID, payeeID primitive.ObjectID, amount Money, currency string) *Transaction {
+	transaction := &Transaction{
+		GroupID:      groupID,
+		Type:         TransactionTypeSettlement,
+		Description:  "Settlement",
+		Amount:       amount,
+		Currency:     currency,
+		Date:         time.Now(),
+		ExchangeRate: 1,
+		Payers:       []TransactionPayer{{UserID: payerID, Amount: amount}},
+		Splits:       []TransactionSplit{{UserID: payeeID, Amount: amount}},
+		Status:       SettlementProposed,
+		Outstanding:  amount,
+		IsCompleted:  false,
+		CreatedBy:    payerID,
+		UpdatedAt:    time.Now(),
+	}
+	transaction.ComputeParticipants()
+	return transaction
+}
+
+// NewRefundTransaction creates a refund of an expense, in the expense's currency and category
+func NewRefundTransaction(expense *Transaction, description string, amount Money, createdBy primitive.ObjectID) *Transaction {
 	return &Transaction{
-		GroupID:     groupID,
-		Type:        TransactionTypeSettlement,
-		Description: "Settlement",
-		Amount:      amount,
-		Currency:    currency,
-		Date:        time.Now(),
-		Participants: []TransactionParticipant{
-			{
-				UserID:    payerID,
-				Amount:    amount, // Positive = they're paying
-				ShareType: "payer",
-			},
-			{
-				UserID:    payeeID,
-				Amount:    -amount, // Negative = they're receiving
-				ShareType: "payee",
-			},
-		},
-		IsCompleted: false,
-		CreatedBy:   payerID,
-		UpdatedAt:   time.Now(),
+		GroupID:      expense.GroupID,
+		Type:         TransactionTypeRefund,
+		Description:  description,
+		Amount:       amount,
+		Currency:     expense.Currency,
+		Date:         time.Now(),
+		ExchangeRate: 1,
+		CategoryID:   expense.CategoryID,
+		Category:     expense.Category,
+		RefundOf:     expense.ID,
+		Participants: []TransactionParticipant{},
+		IsCompleted:  true,
+		CreatedBy:    createdBy,
+		UpdatedAt:    time.Now(),
+	}
+}
+
+// NewAdjustmentTransaction creates a manual balance correction in the group currency
+func NewAdjustmentTransaction(groupID primitive.ObjectID, currency, reason string, createdBy primitive.ObjectID) *Transaction {
+	return &Transaction{
+		GroupID:      groupID,
+		Type:         TransactionTypeAdjustment,
+		Description:  "Balance adjustment",
+		Currency:     currency,
+		Date:         time.Now(),
+		ExchangeRate: 1,
+		Reason:       reason,
+		Participants: []TransactionParticipant{},
+		IsCompleted:  true,
+		CreatedBy:    createdBy,
+		UpdatedAt:    time.Now(),
+	}
+}
+
+// ComputeParticipants sets each member's net involvement from the payers and splits:
+// what they paid less what they owe, or for a refund what their share went down less
+// what they got back. Participants are listed in the order they first appear.
+func (model *Transaction) ComputeParticipants() {
+	sign := Money(1)
+	if model.Type == TransactionTypeRefund {
+		sign = -1
+	}
+
+	var order []primitive.ObjectID
+	participants := make(map[primitive.ObjectID]*TransactionParticipant)
+	participant := func(userID primitive.ObjectID, userName string) *TransactionParticipant {
+		if participants[userID] == nil {
+			participants[userID] = &TransactionParticipant{UserID: userID, UserName: userName}
+			order = append(order, userID)
+		}
+		return participants[userID]
+	}
+
+	for _, payer := range model.Payers {
+		p := participant(payer.UserID, payer.UserName)
+		p.Amount += sign * payer.Amount
+		p.ConvertedAmount += sign * payer.ConvertedAmount
+		p.ShareType = participantShareType(model.Type, p.ShareType, "payer")
+	}
+	for _, split := range model.Splits {
+		p := participant(split.UserID, split.UserName)
+		p.Amount -= sign * split.Amount
+		p.ConvertedAmount -= sign * split.ConvertedAmount
+		p.ShareType = participantShareType(model.Type, p.ShareType, "split")
+	}
+
+	model.Participants = make([]TransactionParticipant, 0, len(order))
+	for _, userID := range order {
+		model.Participants = append(model.Participants, *participants[userID])
+	}
+}
+
+// participantShareType is how a participant who is already shareType is involved once
+// they are also a payer or split (role) of a transaction of type transactionType
+func participantShareType(transactionType TransactionType, shareType, role string) string {
+	switch transactionType {
+	case TransactionTypeSettlement:
+		if role == "split" {
+			return "payee"
+		}
+		return "payer"
+	case TransactionTypeRefund:
+		return "refund"
+	case TransactionTypeAdjustment:
+		return "adjustment"
+	}
+
+	if shareType != "" && shareType != role {
+		return "both"
 	}
+	return role
 }
 
 func (model *Transaction) CollectionName() string {
@@ -6,7 +6,8 @@ import (
 )
 
 const (
-	RoleUser = "user"
+	RoleUser  = "user"
+	RoleAdmin = "admin"
 )
 
 type User struct {
@@ -18,7 +19,8 @@ type User struct {
 	MailVerified     bool   `json:"mail_verified" bson:"mail_verified"`
 	ProfilePicS3Key  string `json:"-" bson:"profile_pic_s3_key"` // Store S3 key privately for uploaded images
 	ProfilePicUrl    string `json:"profile_pic_url,omitempty" bson:"profile_pic_url,omitempty"` // Store external URLs (Google, etc.) or computed S3 URLs
-	ProfilePicType   string `json:"-" bson:"profile_pic_type"` // "s3", "external", or empty
+	ProfilePicType   string `json:"-" bson:"profile_pic_type"`                                  // "s3", "external", or empty
+	Timezone         string `json:"timezone,omitempty" bson:"timezone,omitempty"`               // IANA timezone, e.g. Europe/Berlin
 }
 
 type UserClaims struct {
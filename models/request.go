@@ -1,10 +1,14 @@
 package models
 
 import (
+	"errors"
 	"regexp"
+	"time"
 
+	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
 	validation "github.com/go-ozzo/ozzo-validation"
 	"github.com/go-ozzo/ozzo-validation/is"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 var passwordRule = []validation.Rule{
@@ -13,6 +17,33 @@ var passwordRule = []validation.Rule{
 	validation.Match(regexp.MustCompile("^\\S+$")).Error("cannot contain whitespaces"),
 }
 
+// timezoneRule accepts IANA timezone names such as Europe/Berlin
+var timezoneRule = validation.By(func(value interface{}) error {
+	name, _ := value.(string)
+	if name == "" {
+		return nil
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return errors.New("must be an IANA timezone such as Europe/Berlin")
+	}
+	return nil
+})
+
+// dateRule accepts a day (YYYY-MM-DD) or an RFC 3339 timestamp
+var dateRule = validation.By(func(value interface{}) error {
+	date, _ := value.(string)
+	if date == "" {
+		return nil
+	}
+	if _, err := time.Parse("2006-01-02", date); err == nil {
+		return nil
+	}
+	if _, err := time.Parse(time.RFC3339, date); err != nil {
+		return errors.New("must be a day (YYYY-MM-DD) or an RFC 3339 timestamp")
+	}
+	return nil
+})
+
 type RegisterRequest struct {
 	Name     string `json:"name"`
 	Email    string `json:"email"`
@@ -70,6 +101,7 @@ type CreateGroupRequest struct {
 	Name        string   `json:"name"`
 	Description string   `json:"description"`
 	Currency    string   `json:"currency"`
+	Timezone    string   `json:"timezone,omitempty"` // IANA timezone the group's days are counted in
 	MemberIDs   []string `json:"member_ids,omitempty"`
 }
 
@@ -77,6 +109,7 @@ func (r CreateGroupRequest) Validate() error {
 	return validation.ValidateStruct(&r,
 		validation.Field(&r.Name, validation.Required, validation.Length(1, 100)),
 		validation.Field(&r.Currency, validation.Required, validation.Length(3, 3)),
+		validation.Field(&r.Timezone, timezoneRule),
 	)
 }
 
@@ -84,12 +117,26 @@ type UpdateGroupRequest struct {
 	Name        string `json:"name,omitempty"`
 	Description string `json:"description,omitempty"`
 	Currency    string `json:"currency,omitempty"`
+	Timezone    string `json:"timezone,omitempty"`
 }
 
 func (r UpdateGroupRequest) Validate() error {
 	return validation.ValidateStruct(&r,
 		validation.Field(&r.Name, validation.Length(1, 100)),
 		validation.Field(&r.Currency, validation.Length(3, 3)),
+		validation.Field(&r.Timezone, timezoneRule),
+	)
+}
+
+type PinExchangeRateRequest struct {
+	Currency string  `json:"currency"`
+	Rate     float64 `json:"rate"` // Group currency units per unit of Currency
+}
+
+func (r PinExchangeRateRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Currency, validation.Required, validation.Length(3, 3)),
+		validation.Field(&r.Rate, validation.Required, validation.Min(0.0).Exclusive()),
 	)
 }
 
@@ -123,67 +170,325 @@ func (r RespondFriendRequestRequest) Validate() error {
 }
 
 // Transaction related requests
+// All amounts are integers in the minor units of the transaction currency
+// (e.g. 1234 = 12.34 USD, 1234 = 1234 JPY).
 type TransactionPayerRequest struct {
-	UserID string  `json:"user_id"`
-	Amount float64 `json:"amount"`
+	UserID string   `json:"user_id"`
+	Amount db.Money `json:"amount"`
+}
+
+func (r TransactionPayerRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.UserID, validation.Required, is.MongoID),
+		validation.Field(&r.Amount, validation.Min(db.Money(0))),
+	)
 }
 
+// TransactionSplitRequest describes one participant of a split. Which field is
+// read depends on the split type: amount for "exact", percentage for
+// "percentage", shares for "shares"; "equal" only needs the user ID.
 type TransactionSplitRequest struct {
-	UserID string  `json:"user_id"`
-	Amount float64 `json:"amount"`
+	UserID     string   `json:"user_id"`
+	Amount     db.Money `json:"amount,omitempty"`
+	Percentage float64  `json:"percentage,omitempty"`
+	Shares     int64    `json:"shares,omitempty"`
+}
+
+func (r TransactionSplitRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.UserID, validation.Required, is.MongoID),
+		validation.Field(&r.Amount, validation.Min(db.Money(0))),
+		validation.Field(&r.Percentage, validation.Min(0.0), validation.Max(100.0)),
+		validation.Field(&r.Shares, validation.Min(int64(0)), validation.Max(int64(1000))),
+	)
+}
+
+var splitTypes = []interface{}{
+	string(db.SplitTypeEqual),
+	string(db.SplitTypeExact),
+	string(db.SplitTypePercentage),
+	string(db.SplitTypeShares),
+	string(db.SplitTypeItemized),
+}
+
+// TransactionItemRequest is a receipt line. Type defaults to "item"; tax, tip and
+// service charge lines have no users, they are spread by item subtotal.
+type TransactionItemRequest struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type,omitempty"`
+	Price   db.Money `json:"price"`
+	UserIDs []string `json:"user_ids,omitempty"`
+}
+
+func (r TransactionItemRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Name, validation.Required, validation.Length(1, 100)),
+		validation.Field(&r.Type, validation.In(itemTypes...)),
+		validation.Field(&r.Price, validation.Min(db.Money(0))),
+		validation.Field(&r.UserIDs, validation.Length(0, 50), validation.Each(is.MongoID)),
+	)
+}
+
+var itemTypes = []interface{}{
+	string(db.ItemTypeItem),
+	string(db.ItemTypeTax),
+	string(db.ItemTypeTip),
+	string(db.ItemTypeServiceCharge),
 }
 
 type CreateExpenseTransactionRequest struct {
+	GroupID      string                    `json:"group_id"`
+	Description  string                    `json:"description"`
+	Amount       db.Money                  `json:"amount"`
+	Currency     string                    `json:"currency"`
+	ExchangeRate float64                   `json:"exchange_rate,omitempty"` // Group currency units per unit of Currency, looked up when omitted
+	SplitType    string                    `json:"split_type"`
+	Payers       []TransactionPayerRequest `json:"payers"`             // Who paid money
+	Splits       []TransactionSplitRequest `json:"splits"`             // How it should be divided
+	Items        []TransactionItemRequest  `json:"items,omitempty"`    // Receipt lines, splits are derived from them
+	Category     string                    `json:"category,omitempty"` // Category ID or name, picked by the user's rules when omitted
+	Notes        string                    `json:"notes,omitempty"`
+	IsCompleted  bool                      `json:"is_completed,omitempty"`
+	Date         string                    `json:"date,omitempty"`     // A day (YYYY-MM-DD) or RFC 3339 timestamp, now when omitted
+	Timezone     string                    `json:"timezone,omitempty"` // IANA timezone of Date, the user's or group's when omitted
+
+	// Set by the scheduler for occurrences of a recurring expense, never read from JSON
+	RecurringExpenseID primitive.ObjectID `json:"-"`
+	OccurrenceDate     *time.Time         `json:"-"`
+}
+
+func (r CreateExpenseTransactionRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.GroupID, validation.Required),
+		validation.Field(&r.Description, validation.Required, validation.Length(1, 200)),
+		validation.Field(&r.Amount, validation.Required, validation.Min(db.Money(1))),
+		validation.Field(&r.Currency, validation.Required, validation.Length(3, 3)),
+		validation.Field(&r.ExchangeRate, validation.Min(0.0)),
+		validation.Field(&r.SplitType, validation.Required, validation.In(splitTypes...)),
+		validation.Field(&r.Category, validation.Length(0, 50)),
+		validation.Field(&r.Payers, validation.Required, validation.Length(1, 50)),
+		validation.Field(&r.Splits, splitsRules(r.Items)...),
+		validation.Field(&r.Items, validation.Length(0, 200)),
+		validation.Field(&r.Date, dateRule),
+		validation.Field(&r.Timezone, timezoneRule),
+	)
+}
+
+// splitsRules requires splits unless they are derived from receipt items
+func splitsRules(items []TransactionItemRequest) []validation.Rule {
+	if len(items) > 0 {
+		return []validation.Rule{validation.Length(0, 0).Error("splits are derived from items and must not be provided")}
+	}
+	return []validation.Rule{validation.Required, validation.Length(1, 50)}
+}
+
+type UpdateTransactionRequest struct {
+	Description  string                    `json:"description,omitempty"`
+	Amount       db.Money                  `json:"amount,omitempty"`
+	ExchangeRate float64                   `json:"exchange_rate,omitempty"`
+	SplitType    string                    `json:"split_type,omitempty"`
+	Payers       []TransactionPayerRequest `json:"payers,omitempty"`
+	Splits       []TransactionSplitRequest `json:"splits,omitempty"`
+	Items        []TransactionItemRequest  `json:"items,omitempty"`
+	Category     string                    `json:"category,omitempty"`
+	Notes        string                    `json:"notes,omitempty"`
+	Date         string                    `json:"date,omitempty"`
+	Timezone     string                    `json:"timezone,omitempty"` // Of Date, the transaction's own when omitted
+}
+
+func (r UpdateTransactionRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Amount, validation.Min(db.Money(0))),
+		validation.Field(&r.ExchangeRate, validation.Min(0.0)),
+		validation.Field(&r.Payers, validation.Length(0, 50)),
+		validation.Field(&r.Splits, validation.Length(0, 50)),
+		validation.Field(&r.Items, validation.Length(0, 200)),
+		validation.Field(&r.SplitType, validation.In(splitTypes...)),
+		validation.Field(&r.Date, dateRule),
+		validation.Field(&r.Timezone, timezoneRule),
+	)
+}
+
+var recurrenceFrequencies = []interface{}{
+	string(db.RecurrenceDaily),
+	string(db.RecurrenceWeekly),
+	string(db.RecurrenceMonthly),
+	string(db.RecurrenceYearly),
+}
+
+// CreateRecurringExpenseRequest is an expense template and the schedule it repeats on.
+// Each occurrence is created like an expense, at the exchange rate of its day.
+type CreateRecurringExpenseRequest struct {
 	GroupID     string                    `json:"group_id"`
 	Description string                    `json:"description"`
-	Amount      float64                   `json:"amount"`
+	Amount      db.Money                  `json:"amount"`
 	Currency    string                    `json:"currency"`
 	SplitType   string                    `json:"split_type"`
-	Payers      []TransactionPayerRequest `json:"payers"` // Who paid money
-	Splits      []TransactionSplitRequest `json:"splits"` // How it should be divided
-	Category    string                    `json:"category"`
+	Payers      []TransactionPayerRequest `json:"payers"`
+	Splits      []TransactionSplitRequest `json:"splits"`
+	Items       []TransactionItemRequest  `json:"items,omitempty"`
+	Category    string                    `json:"category,omitempty"` // Category ID or name, picked by the user's rules when omitted
 	Notes       string                    `json:"notes,omitempty"`
-	IsCompleted bool                      `json:"is_completed,omitempty"`
+	Frequency   string                    `json:"frequency"`
+	Interval    int                       `json:"interval,omitempty"`     // Every how many days, weeks, months or years, 1 when omitted
+	DayOfMonth  int                       `json:"day_of_month,omitempty"` // Monthly only, the start date's day when omitted
+	StartDate   time.Time                 `json:"start_date"`
+	EndDate     *time.Time                `json:"end_date,omitempty"`
 }
 
-func (r CreateExpenseTransactionRequest) Validate() error {
+func (r CreateRecurringExpenseRequest) Validate() error {
 	return validation.ValidateStruct(&r,
-		validation.Field(&r.GroupID, validation.Required),
+		validation.Field(&r.GroupID, validation.Required, is.MongoID),
 		validation.Field(&r.Description, validation.Required, validation.Length(1, 200)),
-		validation.Field(&r.Amount, validation.Required, validation.Min(0.01)),
+		validation.Field(&r.Amount, validation.Required, validation.Min(db.Money(1))),
 		validation.Field(&r.Currency, validation.Required, validation.Length(3, 3)),
-		validation.Field(&r.SplitType, validation.Required, validation.In("equal", "exact", "percentage")),
-		validation.Field(&r.Category, validation.Required),
+		validation.Field(&r.SplitType, validation.Required, validation.In(splitTypes...)),
+		validation.Field(&r.Category, validation.Length(0, 50)),
 		validation.Field(&r.Payers, validation.Required, validation.Length(1, 50)),
-		validation.Field(&r.Splits, validation.Required, validation.Length(1, 50)),
+		validation.Field(&r.Splits, splitsRules(r.Items)...),
+		validation.Field(&r.Items, validation.Length(0, 200)),
+		validation.Field(&r.Frequency, validation.Required, validation.In(recurrenceFrequencies...)),
+		validation.Field(&r.Interval, validation.Min(0), validation.Max(366)),
+		validation.Field(&r.DayOfMonth, validation.Min(0), validation.Max(31)),
+		validation.Field(&r.StartDate, validation.Required),
 	)
 }
 
-type UpdateTransactionRequest struct {
+// UpdateRecurringExpenseRequest changes a recurring expense. Template changes apply to
+// occurrences not created yet; changing the schedule restarts it from StartDate, or from
+// the next occurrence when StartDate is omitted.
+type UpdateRecurringExpenseRequest struct {
 	Description string                    `json:"description,omitempty"`
-	Amount      float64                   `json:"amount,omitempty"`
+	Amount      db.Money                  `json:"amount,omitempty"`
 	SplitType   string                    `json:"split_type,omitempty"`
 	Payers      []TransactionPayerRequest `json:"payers,omitempty"`
 	Splits      []TransactionSplitRequest `json:"splits,omitempty"`
+	Items       []TransactionItemRequest  `json:"items,omitempty"`
 	Category    string                    `json:"category,omitempty"`
 	Notes       string                    `json:"notes,omitempty"`
+	Frequency   string                    `json:"frequency,omitempty"`
+	Interval    int                       `json:"interval,omitempty"`
+	DayOfMonth  int                       `json:"day_of_month,omitempty"`
+	StartDate   *time.Time                `json:"start_date,omitempty"`
+	EndDate     *time.Time                `json:"end_date,omitempty"`
+	NoEndDate   bool                      `json:"no_end_date,omitempty"` // Removes the end date
 }
 
-func (r UpdateTransactionRequest) Validate() error {
+func (r UpdateRecurringExpenseRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Description, validation.Length(0, 200)),
+		validation.Field(&r.Amount, validation.Min(db.Money(0))),
+		validation.Field(&r.SplitType, validation.In(splitTypes...)),
+		validation.Field(&r.Payers, validation.Length(0, 50)),
+		validation.Field(&r.Splits, validation.Length(0, 50)),
+		validation.Field(&r.Items, validation.Length(0, 200)),
+		validation.Field(&r.Frequency, validation.In(recurrenceFrequencies...)),
+		validation.Field(&r.Interval, validation.Min(0), validation.Max(366)),
+		validation.Field(&r.DayOfMonth, validation.Min(0), validation.Max(31)),
+	)
+}
+
+var rotationStrategies = []interface{}{
+	string(db.RotationFixedOrder),
+	string(db.RotationBalance),
+}
+
+// CreateRotationRequest takes turns paying for a category of expenses, given by ID or
+// name. MemberIDs is the turn order, everyone in the group when omitted.
+type CreateRotationRequest struct {
+	Name      string   `json:"name,omitempty"`
+	Category  string   `json:"category"`
+	Strategy  string   `json:"strategy,omitempty"` // fixed (default) or balance
+	MemberIDs []string `json:"member_ids,omitempty"`
+}
+
+func (r CreateRotationRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Name, validation.Length(0, 100)),
+		validation.Field(&r.Category, validation.Required, validation.Length(1, 50)),
+		validation.Field(&r.Strategy, validation.In(rotationStrategies...)),
+		validation.Field(&r.MemberIDs, validation.Length(0, 50), validation.Each(is.MongoID)),
+	)
+}
+
+// categoryIconRule accepts icon keys such as shopping-cart
+var categoryIconRule = validation.Match(regexp.MustCompile("^[a-z0-9-]+$")).Error("must be lowercase letters, digits and dashes")
+
+// CreateCategoryRequest adds a category of the group's own. Icon is the key of the icon
+// clients show, a generic one when omitted.
+type CreateCategoryRequest struct {
+	Name string `json:"name"`
+	Icon string `json:"icon,omitempty"`
+}
+
+func (r CreateCategoryRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Name, validation.Required, validation.Length(1, 50)),
+		validation.Field(&r.Icon, validation.Length(0, 50), categoryIconRule),
+	)
+}
+
+type UpdateCategoryRequest struct {
+	Name string `json:"name,omitempty"`
+	Icon string `json:"icon,omitempty"`
+}
+
+func (r UpdateCategoryRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Name, validation.Length(0, 50)),
+		validation.Field(&r.Icon, validation.Length(0, 50), categoryIconRule),
+	)
+}
+
+// categoryPatternRule accepts regular expressions such as uber|lyft
+var categoryPatternRule = validation.By(func(value interface{}) error {
+	pattern, _ := value.(string)
+	if _, err := regexp.Compile(pattern); err != nil {
+		return errors.New("must be a valid regular expression")
+	}
+	return nil
+})
+
+// CreateCategoryRuleRequest files the user's expenses whose description matches Pattern
+// under a category when they are created without one. GroupID limits the rule to one
+// group, and is required for a group's own category.
+type CreateCategoryRuleRequest struct {
+	Pattern    string `json:"pattern"`
+	CategoryID string `json:"category_id"`
+	GroupID    string `json:"group_id,omitempty"`
+	Priority   int    `json:"priority,omitempty"` // Lower runs first, rules with the same priority run oldest first
+}
+
+func (r CreateCategoryRuleRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Pattern, validation.Required, validation.Length(1, 200), categoryPatternRule),
+		validation.Field(&r.CategoryID, validation.Required, validation.Length(1, 50)),
+		validation.Field(&r.GroupID, is.MongoID),
+		validation.Field(&r.Priority, validation.Min(0), validation.Max(1000)),
+	)
+}
+
+type SkipRecurringOccurrenceRequest struct {
+	Date time.Time `json:"date"` // Any time on the day of the occurrence
+}
+
+func (r SkipRecurringOccurrenceRequest) Validate() error {
 	return validation.ValidateStruct(&r,
-		validation.Field(&r.Amount, validation.Min(0.0)),
-		validation.Field(&r.SplitType, validation.In("", "equal", "exact", "percentage")),
+		validation.Field(&r.Date, validation.Required),
 	)
 }
 
 type CreateSettlementTransactionRequest struct {
-	GroupID     string  `json:"group_id"`
-	PayerID     string  `json:"payer_id"`
-	PayeeID     string  `json:"payee_id"`
-	Amount      float64 `json:"amount"`
-	Currency    string  `json:"currency"`
-	Notes       string  `json:"notes,omitempty"`
-	IsCompleted bool    `json:"is_completed,omitempty"`
+	GroupID      string   `json:"group_id"`
+	PayerID      string   `json:"payer_id"`
+	PayeeID      string   `json:"payee_id"`
+	Amount       db.Money `json:"amount"`
+	Currency     string   `json:"currency"`
+	ExchangeRate float64  `json:"exchange_rate,omitempty"` // Group currency units per unit of Currency, looked up when omitted
+	Notes        string   `json:"notes,omitempty"`
+	IsCompleted  bool     `json:"is_completed,omitempty"`
+	Date         string   `json:"date,omitempty"`     // A day (YYYY-MM-DD) or RFC 3339 timestamp, now when omitted
+	Timezone     string   `json:"timezone,omitempty"` // IANA timezone of Date, the user's or group's when omitted
 }
 
 func (r CreateSettlementTransactionRequest) Validate() error {
@@ -191,8 +496,128 @@ func (r CreateSettlementTransactionRequest) Validate() error {
 		validation.Field(&r.GroupID, validation.Required),
 		validation.Field(&r.PayerID, validation.Required),
 		validation.Field(&r.PayeeID, validation.Required),
-		validation.Field(&r.Amount, validation.Required, validation.Min(0.01)),
+		validation.Field(&r.Amount, validation.Required, validation.Min(db.Money(1))),
+		validation.Field(&r.Currency, validation.Required, validation.Length(3, 3)),
+		validation.Field(&r.ExchangeRate, validation.Min(0.0)),
+		validation.Field(&r.Date, dateRule),
+		validation.Field(&r.Timezone, timezoneRule),
+	)
+}
+
+// CreateRefundTransactionRequest refunds part or all of an expense. Without payers the
+// refund goes back to the expense's payers, and without splits everyone's share goes
+// down in proportion to what is left of it.
+type CreateRefundTransactionRequest struct {
+	ExpenseID   string                    `json:"expense_id"`
+	Description string                    `json:"description,omitempty"`
+	Amount      db.Money                  `json:"amount"`           // In the expense currency
+	Payers      []TransactionPayerRequest `json:"payers,omitempty"` // Who got the money back
+	SplitType   string                    `json:"split_type,omitempty"`
+	Splits      []TransactionSplitRequest `json:"splits,omitempty"` // Whose share goes down
+	Notes       string                    `json:"notes,omitempty"`
+}
+
+func (r CreateRefundTransactionRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.ExpenseID, validation.Required, is.MongoID),
+		validation.Field(&r.Description, validation.Length(0, 200)),
+		validation.Field(&r.Amount, validation.Required, validation.Min(db.Money(1))),
+		validation.Field(&r.Payers, validation.Length(0, 50)),
+		validation.Field(&r.SplitType, validation.In(string(db.SplitTypeEqual), string(db.SplitTypeExact), string(db.SplitTypePercentage), string(db.SplitTypeShares))),
+		validation.Field(&r.Splits, validation.Length(0, 50)),
+	)
+}
+
+type AdjustmentEntryRequest struct {
+	UserID string   `json:"user_id"`
+	Amount db.Money `json:"amount"` // Positive raises the user's balance, negative lowers it
+}
+
+func (r AdjustmentEntryRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.UserID, validation.Required, is.MongoID),
+		validation.Field(&r.Amount, validation.Required),
+	)
+}
+
+// CreateAdjustmentTransactionRequest corrects balances by hand. Amounts are in the group
+// currency and must add up to zero so the group stays balanced.
+type CreateAdjustmentTransactionRequest struct {
+	GroupID string                   `json:"group_id"`
+	Reason  string                   `json:"reason"`
+	Entries []AdjustmentEntryRequest `json:"entries"`
+	Notes   string                   `json:"notes,omitempty"`
+}
+
+func (r CreateAdjustmentTransactionRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.GroupID, validation.Required, is.MongoID),
+		validation.Field(&r.Reason, validation.Required, validation.Length(1, 500)),
+		validation.Field(&r.Entries, validation.Required, validation.Length(2, 50)),
+	)
+}
+
+// CreateFriendExpenseRequest records an expense between the user and a friend outside
+// any group. Payers and splits can only be the two of them.
+type CreateFriendExpenseRequest struct {
+	Description string                    `json:"description"`
+	Amount      db.Money                  `json:"amount"`
+	Currency    string                    `json:"currency"`
+	SplitType   string                    `json:"split_type"`
+	Payers      []TransactionPayerRequest `json:"payers"`
+	Splits      []TransactionSplitRequest `json:"splits"`
+	Category    string                    `json:"category,omitempty"`
+	Notes       string                    `json:"notes,omitempty"`
+	Date        string                    `json:"date,omitempty"`
+	Timezone    string                    `json:"timezone,omitempty"`
+}
+
+func (r CreateFriendExpenseRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Description, validation.Required, validation.Length(1, 200)),
+		validation.Field(&r.Amount, validation.Required, validation.Min(db.Money(1))),
+		validation.Field(&r.Currency, validation.Required, validation.Length(3, 3)),
+		validation.Field(&r.SplitType, validation.Required, validation.In(string(db.SplitTypeEqual), string(db.SplitTypeExact), string(db.SplitTypePercentage), string(db.SplitTypeShares))),
+		validation.Field(&r.Category, validation.Length(0, 50)),
+		validation.Field(&r.Payers, validation.Required, validation.Length(1, 2)),
+		validation.Field(&r.Splits, validation.Required, validation.Length(1, 2)),
+		validation.Field(&r.Date, dateRule),
+		validation.Field(&r.Timezone, timezoneRule),
+	)
+}
+
+// CreateFriendSettlementRequest records a payment between the user and a friend outside
+// any group. The payee is whichever of the two is not the payer.
+type CreateFriendSettlementRequest struct {
+	PayerID     string   `json:"payer_id"`
+	Amount      db.Money `json:"amount"`
+	Currency    string   `json:"currency"`
+	Notes       string   `json:"notes,omitempty"`
+	IsCompleted bool     `json:"is_completed,omitempty"`
+	Date        string   `json:"date,omitempty"`
+	Timezone    string   `json:"timezone,omitempty"`
+}
+
+func (r CreateFriendSettlementRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.PayerID, validation.Required, is.MongoID),
+		validation.Field(&r.Amount, validation.Required, validation.Min(db.Money(1))),
 		validation.Field(&r.Currency, validation.Required, validation.Length(3, 3)),
+		validation.Field(&r.Date, dateRule),
+		validation.Field(&r.Timezone, timezoneRule),
+	)
+}
+
+// SettleUpWithFriendRequest settles everything the user and a friend owe each other
+// across their shared groups, optionally only in groups of one currency
+type SettleUpWithFriendRequest struct {
+	Currency string `json:"currency,omitempty"`
+	Notes    string `json:"notes,omitempty"`
+}
+
+func (r SettleUpWithFriendRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Currency, validation.Length(3, 3)),
 	)
 }
 
@@ -216,6 +641,36 @@ func (r CompleteTransactionRequest) Validate() error {
 	return validation.ValidateStruct(&r)
 }
 
+type UpdateSettlementStatusRequest struct {
+	Status           string `json:"status"`
+	Notes            string `json:"notes,omitempty"`
+	SettlementMethod string `json:"settlement_method,omitempty"`
+	ProofOfPayment   string `json:"proof_of_payment,omitempty"`
+}
+
+func (r UpdateSettlementStatusRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Status, validation.Required, validation.In("paid", "confirmed", "disputed", "cancelled")),
+		validation.Field(&r.Notes, validation.Length(0, 500)),
+	)
+}
+
+// RecordSettlementPaymentRequest is an installment paid towards a settlement, in the
+// settlement currency. Date defaults to now.
+type RecordSettlementPaymentRequest struct {
+	Amount         db.Money   `json:"amount"`
+	Date           *time.Time `json:"date,omitempty"`
+	Method         string     `json:"method,omitempty"`
+	ProofOfPayment string     `json:"proof_of_payment,omitempty"`
+	Notes          string     `json:"notes,omitempty"`
+}
+
+func (r RecordSettlementPaymentRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Amount, validation.Required, validation.Min(db.Money(1))),
+		validation.Field(&r.Notes, validation.Length(0, 500)),
+	)
+}
 
 type PresignedURLRequest struct {
 	FileName string `json:"file_name"`
@@ -238,12 +693,14 @@ func (r ConfirmUploadRequest) Validate() error {
 }
 
 type UpdateProfileRequest struct {
-	Name string `json:"name"`
+	Name     string `json:"name"`
+	Timezone string `json:"timezone,omitempty"` // IANA timezone, e.g. Europe/Berlin
 }
 
 func (r UpdateProfileRequest) Validate() error {
 	return validation.ValidateStruct(&r,
 		validation.Field(&r.Name, validation.Required, validation.Length(3, 64)),
+		validation.Field(&r.Timezone, timezoneRule),
 	)
 }
 
@@ -272,3 +729,28 @@ func (r PushSubscriptionRequest) Validate() error {
 		validation.Field(&r.Keys.Auth, validation.Required),
 	)
 }
+
+// PageQuery is the page of a list to return. A cursor from the previous page gives
+// stable pages while transactions are added; Page skips whole pages and is kept for
+// older clients.
+type PageQuery struct {
+	Cursor string
+	Page   int
+	Limit  int
+}
+
+// TransactionQuery filters and pages a list of transactions. Zero values do not filter.
+type TransactionQuery struct {
+	PageQuery
+	Type          string
+	From          string // Day (YYYY-MM-DD) or RFC 3339 timestamp
+	To            string // Inclusive, a day runs to its end
+	Timezone      string // IANA timezone days are read in, the user's or group's when empty
+	Category      string // Category ID
+	PayerID       primitive.ObjectID
+	ParticipantID primitive.ObjectID
+	MinAmount     *db.Money // In the group currency
+	MaxAmount     *db.Money
+	Completed     *bool
+	Search        string // Words in the description or notes
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"time"
 
+	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -55,11 +56,29 @@ type SettlementSuggestion struct {
 	PayerName string             `json:"payer_name"`
 	PayeeID   primitive.ObjectID `json:"payee_id"`
 	PayeeName string             `json:"payee_name"`
-	Amount    float64            `json:"amount"`
+	Amount    db.Money           `json:"amount"`
 	Currency  string             `json:"currency"`
 	Status    string             `json:"status"`
 }
 
+// FriendGroupBalance is what a friend owes the user in one shared group, in the group
+// currency. A negative amount means the user owes the friend.
+type FriendGroupBalance struct {
+	GroupID   primitive.ObjectID `json:"group_id"`
+	GroupName string             `json:"group_name"`
+	Currency  string             `json:"currency"`
+	Amount    db.Money           `json:"amount"`
+	Direct    bool               `json:"direct,omitempty"` // Direct expenses outside any group
+}
+
+// FriendBalance nets what the user and a friend owe each other across all shared groups
+type FriendBalance struct {
+	UserID        primitive.ObjectID   `json:"user_id"`
+	UserName      string               `json:"user_name"`
+	Groups        []FriendGroupBalance `json:"groups"`
+	NetByCurrency map[string]db.Money  `json:"net_by_currency"` // Positive: the friend owes the user
+}
+
 // FriendRequestResponse represents a friend request with requester details
 type FriendRequestResponse struct {
 	ID             primitive.ObjectID `json:"id"`
@@ -79,3 +98,10 @@ type SentFriendRequestResponse struct {
 	Status         string             `json:"status"`
 	RequestedAt    time.Time          `json:"requested_at"`
 }
+
+// TransactionPage is one page of a transaction list. NextCursor fetches the page after it.
+type TransactionPage struct {
+	Transactions []*db.Transaction `json:"transactions"`
+	NextCursor   string            `json:"next_cursor,omitempty"`
+	HasMore      bool              `json:"has_more"`
+}
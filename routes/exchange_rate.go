@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/controllers"
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/middlewares"
+	"github.com/gin-gonic/gin"
+)
+
+func ExchangeRateRoute(router *gin.RouterGroup, handlers ...gin.HandlerFunc) {
+	exchangeRates := router.Group("/exchange-rates", handlers...)
+	{
+		exchangeRates.GET("", controllers.GetExchangeRate)
+	}
+
+	admin := router.Group("/admin", append(handlers, middlewares.AdminMiddleware())...)
+	{
+		admin.POST("/exchange-rates/import", controllers.ImportExchangeRates)
+	}
+}
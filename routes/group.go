@@ -2,6 +2,7 @@ package routes
 
 import (
 	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/controllers"
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/middlewares"
 	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/middlewares/validators"
 	"github.com/gin-gonic/gin"
 )
@@ -11,6 +12,7 @@ func GroupRoute(router *gin.RouterGroup, handlers ...gin.HandlerFunc) {
 	{
 		groups.POST(
 			"",
+			middlewares.IdempotencyMiddleware(),
 			validators.CreateGroupValidator(),
 			controllers.CreateGroup,
 		)
@@ -59,6 +61,20 @@ func GroupRoute(router *gin.RouterGroup, handlers ...gin.HandlerFunc) {
 			controllers.RemoveMemberFromGroup,
 		)
 
+		// Exchange rates pinned for the group
+		groups.PUT(
+			"/:id/exchange-rates",
+			validators.PathIdValidator(),
+			validators.PinExchangeRateValidator(),
+			controllers.PinGroupExchangeRate,
+		)
+
+		groups.DELETE(
+			"/:id/exchange-rates/:currency",
+			validators.PathIdValidator(),
+			controllers.UnpinGroupExchangeRate,
+		)
+
 		// Note: Group expenses now available via /v1/groups/:id/transactions/expenses
 		// Note: Balances and settlement routes moved to transaction routes
 		// for the new unified transaction-based architecture
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/controllers"
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/middlewares"
+	"github.com/gin-gonic/gin"
+)
+
+func ReconciliationRoute(router *gin.RouterGroup, handlers ...gin.HandlerFunc) {
+	reconciliation := router.Group("/admin/reconciliation", append(handlers, middlewares.AdminMiddleware())...)
+	{
+		reconciliation.GET("", controllers.GetReconciliationReport)
+		reconciliation.POST("", controllers.RunReconciliation)
+	}
+}
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/controllers"
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/middlewares"
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/middlewares/validators"
+	"github.com/gin-gonic/gin"
+)
+
+func RecurringExpenseRoute(router *gin.RouterGroup, handlers ...gin.HandlerFunc) {
+	recurring := router.Group("/recurring-expenses", handlers...)
+	{
+		recurring.POST("", middlewares.IdempotencyMiddleware(), validators.CreateRecurringExpenseValidator(), controllers.CreateRecurringExpense)
+		recurring.GET("/:id", controllers.GetRecurringExpense)
+		recurring.PUT("/:id", validators.UpdateRecurringExpenseValidator(), controllers.UpdateRecurringExpense)
+		recurring.DELETE("/:id", controllers.DeleteRecurringExpense)
+
+		// Schedule
+		recurring.POST("/:id/pause", controllers.PauseRecurringExpense)
+		recurring.POST("/:id/resume", controllers.ResumeRecurringExpense)
+		recurring.POST("/:id/skip", validators.SkipRecurringOccurrenceValidator(), controllers.SkipRecurringOccurrence)
+		recurring.GET("/:id/upcoming", controllers.GetUpcomingOccurrences)
+	}
+}
@@ -32,6 +32,9 @@ func New() *gin.Engine {
 		FriendshipRoute(v1, middlewares.JWTMiddleware())
 		// Using unified transaction-based system
 		TransactionRoutes(v1)
+		RecurringExpenseRoute(v1, middlewares.JWTMiddleware())
+		ExchangeRateRoute(v1, middlewares.JWTMiddleware())
+		ReconciliationRoute(v1, middlewares.JWTMiddleware())
 		
 		// Media upload functionality
 		MediaRoute(v1, middlewares.JWTMiddleware())
@@ -3,6 +3,7 @@ package routes
 import (
 	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/controllers"
 	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/middlewares"
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/middlewares/validators"
 	"github.com/gin-gonic/gin"
 )
 
@@ -13,20 +14,27 @@ func TransactionRoutes(router *gin.RouterGroup) {
 	transactionGroup.Use(middlewares.JWTMiddleware())
 	{
 		// Create transactions
-		transactionGroup.POST("/expense", controllers.CreateExpenseTransaction)
-		transactionGroup.POST("/settlement", controllers.CreateSettlementTransaction)
+		transactionGroup.POST("/expense", middlewares.IdempotencyMiddleware(), validators.CreateExpenseTransactionValidator(), controllers.CreateExpenseTransaction)
+		transactionGroup.POST("/settlement", middlewares.IdempotencyMiddleware(), validators.CreateSettlementTransactionValidator(), controllers.CreateSettlementTransaction)
+		transactionGroup.POST("/refund", middlewares.IdempotencyMiddleware(), validators.CreateRefundTransactionValidator(), controllers.CreateRefundTransaction)
+		transactionGroup.POST("/adjustment", middlewares.IdempotencyMiddleware(), validators.CreateAdjustmentTransactionValidator(), controllers.CreateAdjustmentTransaction)
 
 		// Complete transactions
-		transactionGroup.POST("/:id/complete", controllers.MarkTransactionComplete)
+		transactionGroup.POST("/:id/complete", middlewares.IdempotencyMiddleware(), controllers.MarkTransactionComplete)
+		transactionGroup.POST("/:id/status", middlewares.IdempotencyMiddleware(), validators.UpdateSettlementStatusValidator(), controllers.UpdateSettlementStatus)
+		transactionGroup.POST("/:id/payments", middlewares.IdempotencyMiddleware(), validators.RecordSettlementPaymentValidator(), controllers.RecordSettlementPayment)
+		transactionGroup.POST("/:id/payments/:paymentId/confirm", middlewares.IdempotencyMiddleware(), controllers.ConfirmSettlementPayment)
 
 		// Get single transaction
 		transactionGroup.GET("/:id", controllers.GetTransactionById)
+		transactionGroup.GET("/:id/history", controllers.GetTransactionHistory)
 
 		// Update transaction (for editing expenses)
-		transactionGroup.PUT("/:id", controllers.UpdateTransaction)
+		transactionGroup.PUT("/:id", validators.UpdateTransactionValidator(), controllers.UpdateTransaction)
 
 		// Delete transaction
 		transactionGroup.DELETE("/:id", controllers.DeleteTransaction)
+		transactionGroup.POST("/:id/restore", middlewares.IdempotencyMiddleware(), controllers.RestoreTransaction)
 	}
 
 	// Enhanced group routes with new balance/transaction endpoints
@@ -41,14 +49,31 @@ func TransactionRoutes(router *gin.RouterGroup) {
 		groupGroup.GET("/:id/transactions", controllers.GetGroupTransactions)
 		groupGroup.GET("/:id/transactions/expenses", controllers.GetGroupExpenseTransactions)
 		groupGroup.GET("/:id/transactions/settlements", controllers.GetGroupSettlementTransactions)
+		groupGroup.GET("/:id/trash", controllers.GetGroupTrash)
+		groupGroup.GET("/:id/recurring-expenses", controllers.GetGroupRecurringExpenses)
+
+		// Taking turns paying
+		groupGroup.POST("/:id/rotations", validators.CreateRotationValidator(), controllers.CreateRotation)
+		groupGroup.GET("/:id/rotations", controllers.GetGroupRotations)
+		groupGroup.GET("/:id/rotations/next", controllers.SuggestNextPayer)
+		groupGroup.POST("/:id/rotations/:rotationId/remind", controllers.RemindRotation)
+		groupGroup.DELETE("/:id/rotations/:rotationId", controllers.DeleteRotation)
+
+		// Categories: the built-in ones and the group's own
+		groupGroup.GET("/:id/categories", controllers.GetGroupCategories)
+		groupGroup.POST("/:id/categories", validators.CreateCategoryValidator(), controllers.CreateCategory)
+		groupGroup.PUT("/:id/categories/:categoryId", validators.UpdateCategoryValidator(), controllers.UpdateCategory)
+		groupGroup.DELETE("/:id/categories/:categoryId", controllers.DeleteCategory)
 
 		// Balance history and analytics
 		groupGroup.GET("/:id/balance-history", controllers.GetGroupBalanceHistory)
 		groupGroup.GET("/:id/analytics", controllers.GetGroupAnalytics)
 
 		// Bulk operations
-		groupGroup.POST("/:id/bulk-settlements", controllers.CreateBulkSettlements)
-		groupGroup.POST("/:id/recalculate-balances", controllers.RecalculateGroupBalances)
+		groupGroup.POST("/:id/bulk-settlements", middlewares.IdempotencyMiddleware(), validators.BulkSettlementsValidator(), controllers.CreateBulkSettlements)
+		groupGroup.GET("/:id/settlement-batches/:batchId", controllers.GetSettlementBatch)
+		groupGroup.DELETE("/:id/settlement-batches/:batchId", controllers.UndoSettlementBatch)
+		groupGroup.POST("/:id/recalculate-balances", middlewares.AdminMiddleware(), controllers.RecalculateGroupBalances)
 	}
 
 	// User transaction routes
@@ -59,5 +84,19 @@ func TransactionRoutes(router *gin.RouterGroup) {
 		userGroup.GET("/me/transactions", controllers.GetUserTransactions)
 		userGroup.GET("/me/balances", controllers.GetUserBalances)
 		userGroup.GET("/me/analytics", controllers.GetUserAnalytics)
+
+		// Rules that pick the category of expenses created without one
+		userGroup.GET("/me/category-rules", controllers.GetCategoryRules)
+		userGroup.POST("/me/category-rules", validators.CreateCategoryRuleValidator(), controllers.CreateCategoryRule)
+		userGroup.DELETE("/me/category-rules/:ruleId", controllers.DeleteCategoryRule)
+
+		// Debts with each person across shared groups
+		userGroup.GET("/me/friends/balances", controllers.GetFriendBalances)
+		userGroup.POST("/me/friends/:friendId/settle-up", middlewares.IdempotencyMiddleware(), validators.SettleUpWithFriendValidator(), controllers.SettleUpWithFriend)
+
+		// Direct expenses and settlements with a friend, outside any group
+		userGroup.GET("/me/friends/:friendId/transactions", controllers.GetFriendTransactions)
+		userGroup.POST("/me/friends/:friendId/expenses", middlewares.IdempotencyMiddleware(), validators.CreateFriendExpenseValidator(), controllers.CreateFriendExpense)
+		userGroup.POST("/me/friends/:friendId/settlements", middlewares.IdempotencyMiddleware(), validators.CreateFriendSettlementValidator(), controllers.CreateFriendSettlement)
 	}
 }
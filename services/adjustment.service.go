@@ -0,0 +1,90 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models"
+	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CreateAdjustmentTransaction corrects group balances by hand, e.g. to fix a mistake that
+// cannot be traced back to a single expense. Only the group creator or an admin can do
+// it, a reason is required, and the entries must add up to zero so the group's balances
+// keep summing to zero.
+func (ts *TransactionService) CreateAdjustmentTransaction(userID primitive.ObjectID, req models.CreateAdjustmentTransactionRequest) (*db.Transaction, error) {
+	groupID, err := primitive.ObjectIDFromHex(req.GroupID)
+	if err != nil {
+		return nil, errors.New("invalid group ID")
+	}
+
+	group, err := GetGroupById(groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if group.CreatedBy != userID {
+		user, err := FindUserById(userID)
+		if err != nil || user.Role != db.RoleAdmin {
+			return nil, errors.New("only the group creator or an admin can adjust balances")
+		}
+	}
+
+	members := make(map[primitive.ObjectID]bool)
+	for _, memberID := range group.Members {
+		members[memberID] = true
+	}
+
+	transaction := db.NewAdjustmentTransaction(groupID, group.Currency, req.Reason, userID)
+	transaction.Notes = req.Notes
+	transaction.GroupCurrency = group.Currency
+
+	seen := make(map[primitive.ObjectID]bool)
+	var total db.Money
+	for _, entry := range req.Entries {
+		entryUserID, err := primitive.ObjectIDFromHex(entry.UserID)
+		if err != nil {
+			return nil, errors.New("invalid adjustment user ID")
+		}
+		if seen[entryUserID] {
+			return nil, errors.New("each user can only appear once in an adjustment")
+		}
+		seen[entryUserID] = true
+
+		if !members[entryUserID] {
+			return nil, errors.New("adjustments can only be made for group members")
+		}
+
+		user, err := FindUserById(entryUserID)
+		if err != nil {
+			return nil, errors.New("adjustment user not found")
+		}
+
+		// Positive amounts are credited like a payment, negative ones debited like a share
+		if entry.Amount > 0 {
+			transaction.Payers = append(transaction.Payers, db.TransactionPayer{
+				UserID:          entryUserID,
+				UserName:        user.Name,
+				Amount:          entry.Amount,
+				ConvertedAmount: entry.Amount,
+			})
+			transaction.Amount += entry.Amount
+		} else if entry.Amount < 0 {
+			transaction.Splits = append(transaction.Splits, db.TransactionSplit{
+				UserID:          entryUserID,
+				UserName:        user.Name,
+				Amount:          -entry.Amount,
+				ConvertedAmount: -entry.Amount,
+			})
+		}
+		total += entry.Amount
+	}
+
+	if total != 0 {
+		return nil, errors.New("adjustment amounts must add up to zero")
+	}
+	transaction.ConvertedAmount = transaction.Amount
+	transaction.ComputeParticipants()
+
+	return ts.executeTransactionWithBalanceUpdate(transaction, group)
+}
@@ -0,0 +1,453 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models"
+	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
+	"github.com/kamva/mgm/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxCategoryRules is how many auto-categorization rules a user can have
+const maxCategoryRules = 100
+
+var errUnknownCategory = errors.New("unknown category, use a built-in category or one of the group's own")
+
+// GetGroupCategories returns the categories a group's expenses can be filed under: the
+// built-in ones, then the group's own by name
+func (ts *TransactionService) GetGroupCategories(groupID, userID primitive.ObjectID) ([]db.CategoryInfo, error) {
+	if _, err := GetGroupById(groupID, userID); err != nil {
+		return nil, err
+	}
+
+	categories := []*db.Category{}
+	err := mgm.Coll(&db.Category{}).SimpleFind(&categories, bson.M{"group_id": groupID},
+		options.Find().SetSort(bson.D{{Key: "name_key", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	infos := append([]db.CategoryInfo{}, db.BuiltInCategories...)
+	for _, category := range categories {
+		infos = append(infos, category.Info())
+	}
+	return infos, nil
+}
+
+// CreateCategory adds a category of the group's own. Any member can add one.
+func (ts *TransactionService) CreateCategory(groupID, userID primitive.ObjectID, req models.CreateCategoryRequest) (*db.Category, error) {
+	group, err := GetGroupById(groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if group.Direct {
+		return nil, errDirectGroup
+	}
+
+	if db.CategoryNameKey(req.Name) == "" {
+		return nil, errors.New("name cannot be blank")
+	}
+	if _, ok := builtInCategory(req.Name); ok {
+		return nil, errors.New("there is already a built-in category with that name")
+	}
+
+	icon := req.Icon
+	if icon == "" {
+		icon = db.DefaultCategoryIcon
+	}
+
+	category := db.NewCategory(groupID, req.Name, icon, userID)
+	if err := mgm.Coll(category).Create(category); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errors.New("this group already has a category with that name")
+		}
+		return nil, err
+	}
+
+	return category, nil
+}
+
+// UpdateCategory renames a group's own category or changes its icon. Expenses filed under
+// it take the new name.
+func (ts *TransactionService) UpdateCategory(groupID, categoryID, userID primitive.ObjectID, req models.UpdateCategoryRequest) (*db.Category, error) {
+	category, err := findCategory(groupID, categoryID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(req.Name)
+	renamed := name != "" && name != category.Name
+	if renamed {
+		if _, ok := builtInCategory(name); ok {
+			return nil, errors.New("there is already a built-in category with that name")
+		}
+		category.Name = name
+		category.NameKey = db.CategoryNameKey(name)
+	}
+	if req.Icon != "" {
+		category.Icon = req.Icon
+	}
+
+	err = runInTransaction(func(sc mongo.SessionContext) error {
+		if err := mgm.Coll(category).UpdateWithCtx(sc, category); err != nil {
+			return err
+		}
+		if !renamed {
+			return nil
+		}
+
+		_, err := mgm.Coll(&db.Rotation{}).UpdateMany(sc,
+			bson.M{"group_id": groupID, "category_id": category.ID.Hex()},
+			bson.M{"$set": bson.M{"category": category.Name}},
+		)
+		if err != nil {
+			return err
+		}
+		return recategorizeTransactions(sc, groupID, category.ID.Hex(), category.Info(), userID)
+	})
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errors.New("this group already has a category with that name")
+		}
+		return nil, err
+	}
+
+	return category, nil
+}
+
+// DeleteCategory removes a group's own category. Its expenses and recurring expenses move
+// to General, and the rules and rotations for it are removed.
+func (ts *TransactionService) DeleteCategory(groupID, categoryID, userID primitive.ObjectID) error {
+	category, err := findCategory(groupID, categoryID, userID)
+	if err != nil {
+		return err
+	}
+
+	general, _ := builtInCategory(db.DefaultCategoryID)
+	return runInTransaction(func(sc mongo.SessionContext) error {
+		if err := recategorizeTransactions(sc, groupID, category.ID.Hex(), general, userID); err != nil {
+			return err
+		}
+
+		_, err := mgm.Coll(&db.RecurringExpense{}).UpdateMany(sc,
+			bson.M{"group_id": groupID, "category": category.ID.Hex()},
+			bson.M{"$set": bson.M{"category": general.ID, "updated_at": time.Now(), "updated_by": userID}},
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = mgm.Coll(&db.CategoryRule{}).DeleteMany(sc, bson.M{"category_id": category.ID.Hex()})
+		if err != nil {
+			return err
+		}
+
+		_, err = mgm.Coll(&db.Rotation{}).DeleteMany(sc, bson.M{"group_id": groupID, "category_id": category.ID.Hex()})
+		if err != nil {
+			return err
+		}
+
+		return mgm.Coll(category).DeleteWithCtx(sc, category)
+	})
+}
+
+// recategorizeTransactions files a group's transactions in one category under another
+// category, or under the new name of the same one. Each is changed like any other edit:
+// its updated_at moves on and the change is recorded in its history.
+func recategorizeTransactions(sc mongo.SessionContext, groupID primitive.ObjectID, categoryID string, category db.CategoryInfo, userID primitive.ObjectID) error {
+	var transactions []*db.Transaction
+	err := mgm.Coll(&db.Transaction{}).SimpleFindWithCtx(sc, &transactions, bson.M{"group_id": groupID, "category_id": categoryID})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, transaction := range transactions {
+		before := snapshotTransaction(transaction)
+		transaction.CategoryID = category.ID
+		transaction.Category = category.Name
+		transaction.UpdatedAt = now
+		transaction.UpdatedBy = userID
+
+		_, err := mgm.Coll(transaction).UpdateOne(sc, bson.M{"_id": transaction.ID}, bson.M{"$set": bson.M{
+			"category_id": transaction.CategoryID,
+			"category":    transaction.Category,
+			"updated_at":  transaction.UpdatedAt,
+			"updated_by":  transaction.UpdatedBy,
+		}})
+		if err != nil {
+			return err
+		}
+		if err := recordRevision(sc, db.RevisionEdit, before, transaction, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findCategory loads a group's own category for a user who can change it: whoever added
+// it, the group creator or an admin
+func findCategory(groupID, categoryID, userID primitive.ObjectID) (*db.Category, error) {
+	group, err := GetGroupById(groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	category := &db.Category{}
+	err = mgm.Coll(category).First(bson.M{"_id": categoryID, "group_id": groupID}, category)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("category not found")
+		}
+		return nil, err
+	}
+
+	if category.CreatedBy != userID && group.CreatedBy != userID {
+		user, err := FindUserById(userID)
+		if err != nil || user.Role != db.RoleAdmin {
+			return nil, errors.New("only the creator of the category, the group creator or an admin can change it")
+		}
+	}
+
+	return category, nil
+}
+
+// GetCategoryRules returns the user's auto-categorization rules in the order they run
+func (ts *TransactionService) GetCategoryRules(userID primitive.ObjectID) ([]*db.CategoryRule, error) {
+	rules := []*db.CategoryRule{}
+	err := mgm.Coll(&db.CategoryRule{}).SimpleFind(&rules, bson.M{"user_id": userID}, categoryRuleOrder())
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// CreateCategoryRule adds an auto-categorization rule. A rule for a group's own category
+// is limited to that group.
+func (ts *TransactionService) CreateCategoryRule(userID primitive.ObjectID, req models.CreateCategoryRuleRequest) (*db.CategoryRule, error) {
+	var groupID primitive.ObjectID
+	if req.GroupID != "" {
+		var err error
+		if groupID, err = primitive.ObjectIDFromHex(req.GroupID); err != nil {
+			return nil, errors.New("invalid group ID")
+		}
+		if _, err := GetGroupById(groupID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	categoryID := ""
+	if builtIn, ok := builtInCategory(req.CategoryID); ok {
+		categoryID = builtIn.ID
+	} else {
+		id, err := primitive.ObjectIDFromHex(req.CategoryID)
+		if err != nil {
+			return nil, errUnknownCategory
+		}
+		category := &db.Category{}
+		if err := mgm.Coll(category).FindByID(id, category); err != nil {
+			return nil, errUnknownCategory
+		}
+		if groupID.IsZero() {
+			if _, err := GetGroupById(category.GroupID, userID); err != nil {
+				return nil, errUnknownCategory
+			}
+			groupID = category.GroupID
+		} else if category.GroupID != groupID {
+			return nil, errors.New("the category belongs to another group")
+		}
+		categoryID = category.ID.Hex()
+	}
+
+	count, err := mgm.Coll(&db.CategoryRule{}).CountDocuments(mgm.Ctx(), bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxCategoryRules {
+		return nil, errors.New("you have too many category rules, delete some first")
+	}
+
+	rule := db.NewCategoryRule(userID, req.Pattern, categoryID, groupID, req.Priority)
+	if err := mgm.Coll(rule).Create(rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// DeleteCategoryRule removes one of the user's auto-categorization rules
+func (ts *TransactionService) DeleteCategoryRule(ruleID, userID primitive.ObjectID) error {
+	result, err := mgm.Coll(&db.CategoryRule{}).DeleteOne(mgm.Ctx(), bson.M{"_id": ruleID, "user_id": userID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.New("category rule not found")
+	}
+	return nil
+}
+
+// setTransactionCategory files an expense under the category a client sent, by ID or
+// name. Without one, the first of the user's rules its description matches picks it, and
+// General when none does.
+func setTransactionCategory(transaction *db.Transaction, category string, userID primitive.ObjectID) error {
+	var info db.CategoryInfo
+	var err error
+	if category != "" {
+		if info, err = resolveCategory(transaction.GroupID, category); err != nil {
+			return err
+		}
+	} else {
+		info = matchCategoryRules(transaction.GroupID, userID, transaction.Description)
+	}
+
+	transaction.CategoryID = info.ID
+	transaction.Category = info.Name
+	return nil
+}
+
+// resolveCategory finds one of a group's categories by ID, or by name ignoring case
+func resolveCategory(groupID primitive.ObjectID, value string) (db.CategoryInfo, error) {
+	if builtIn, ok := builtInCategory(value); ok {
+		return builtIn, nil
+	}
+
+	filter := bson.M{"group_id": groupID, "name_key": db.CategoryNameKey(value)}
+	if id, err := primitive.ObjectIDFromHex(value); err == nil {
+		filter = bson.M{"group_id": groupID, "_id": id}
+	}
+
+	category := &db.Category{}
+	if err := mgm.Coll(category).First(filter, category); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return db.CategoryInfo{}, errUnknownCategory
+		}
+		return db.CategoryInfo{}, err
+	}
+	return category.Info(), nil
+}
+
+// builtInCategory finds a built-in category by ID, or by name ignoring case
+func builtInCategory(value string) (db.CategoryInfo, bool) {
+	key := db.CategoryNameKey(value)
+	for _, category := range db.BuiltInCategories {
+		if category.ID == key || db.CategoryNameKey(category.Name) == key {
+			return category, true
+		}
+	}
+	return db.CategoryInfo{}, false
+}
+
+// matchCategoryRules returns the category of the first of the user's rules for the group
+// that matches description, or General. Rules never block an expense, so failures are
+// only logged.
+func matchCategoryRules(groupID, userID primitive.ObjectID, description string) db.CategoryInfo {
+	general, _ := builtInCategory(db.DefaultCategoryID)
+
+	rules := []*db.CategoryRule{}
+	err := mgm.Coll(&db.CategoryRule{}).SimpleFind(&rules, bson.M{
+		"user_id": userID,
+		"$or": []bson.M{
+			{"group_id": groupID},
+			{"group_id": bson.M{"$exists": false}},
+		},
+	}, categoryRuleOrder())
+	if err != nil {
+		log.Printf("Error finding category rules for user %s: %v\n", userID.Hex(), err)
+		return general
+	}
+
+	for _, rule := range rules {
+		pattern, err := regexp.Compile("(?i)" + rule.Pattern)
+		if err != nil || !pattern.MatchString(description) {
+			continue
+		}
+		if info, err := resolveCategory(groupID, rule.CategoryID); err == nil {
+			return info
+		}
+	}
+	return general
+}
+
+func categoryRuleOrder() *options.FindOptions {
+	return options.Find().SetSort(bson.D{{Key: "priority", Value: 1}, {Key: "_id", Value: 1}})
+}
+
+// categorySpending totals a group's net spend per category, largest first. Refunds count
+// against the category of the expense they refund.
+func categorySpending(groupID primitive.ObjectID, transactions []*db.Transaction) ([]*db.CategorySpending, error) {
+	categories := []*db.Category{}
+	if err := mgm.Coll(&db.Category{}).SimpleFind(&categories, bson.M{"group_id": groupID}); err != nil {
+		return nil, err
+	}
+	infos := make(map[string]db.CategoryInfo, len(db.BuiltInCategories)+len(categories))
+	for _, category := range db.BuiltInCategories {
+		infos[category.ID] = category
+	}
+	for _, category := range categories {
+		infos[category.ID.Hex()] = category.Info()
+	}
+
+	byCategory := make(map[string]*db.CategorySpending)
+	spending := []*db.CategorySpending{}
+	for _, transaction := range transactions {
+		var amount db.Money
+		switch transaction.Type {
+		case db.TransactionTypeExpense:
+			amount = transaction.ConvertedAmount
+		case db.TransactionTypeRefund:
+			amount = -transaction.ConvertedAmount
+		default:
+			continue
+		}
+
+		categoryID := transaction.CategoryID
+		if categoryID == "" {
+			categoryID = db.DefaultCategoryID
+		}
+		entry, ok := byCategory[categoryID]
+		if !ok {
+			info, known := infos[categoryID]
+			if !known {
+				info = db.CategoryInfo{ID: categoryID, Name: transaction.Category, Icon: db.DefaultCategoryIcon}
+			}
+			entry = &db.CategorySpending{CategoryInfo: info}
+			byCategory[categoryID] = entry
+			spending = append(spending, entry)
+		}
+		entry.Amount += amount
+		if transaction.Type == db.TransactionTypeExpense {
+			entry.Count++
+		}
+	}
+
+	sort.SliceStable(spending, func(i, j int) bool {
+		return spending[i].Amount > spending[j].Amount
+	})
+	return spending, nil
+}
+
+// EnsureCategoryIndexes creates the unique index that keeps a group's category names
+// apart, and the index a user's rules are read through
+func EnsureCategoryIndexes() error {
+	_, err := mgm.Coll(&db.Category{}).Indexes().CreateOne(mgm.Ctx(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "group_id", Value: 1}, {Key: "name_key", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = mgm.Coll(&db.CategoryRule{}).Indexes().CreateOne(mgm.Ctx(), mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "priority", Value: 1}},
+	})
+	return err
+}
@@ -12,6 +12,8 @@ func LoadConfig() {
 	v.AutomaticEnv()
 	v.SetDefault("SERVER_PORT", "8080")
 	v.SetDefault("MODE", "debug")
+	v.SetDefault("MIGRATE_ON_STARTUP", true)
+	v.SetDefault("RECONCILE_INTERVAL_MINUTES", 60)
 	v.SetDefault("FIREBASE_CREDENTIALS_JSON", "")
 	v.SetConfigType("dotenv")
 	v.SetConfigName(".env")
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
+)
+
+// RateProvider looks up exchange rates. Rate returns how many units of `to` one
+// unit of `from` was worth on the given date.
+type RateProvider interface {
+	Rate(from, to string, date time.Time) (float64, error)
+}
+
+// Rates is the provider used to convert foreign-currency amounts, e.g. transactions into
+// their group currency. When nothing is found, foreign-currency transactions need an
+// explicit exchange rate.
+var Rates RateProvider
+
+// InitRateProvider sets up the exchange rate provider. Rates imported into the database
+// are used first, with the rates file from the configuration as a fallback.
+func InitRateProvider() {
+	providers := rateProviders{&DBRateProvider{}}
+
+	if Config.ExchangeRatesFile != "" {
+		provider, err := NewFileRateProvider(Config.ExchangeRatesFile)
+		if err != nil {
+			log.Printf("Warning: Failed to load exchange rates file: %s", err.Error())
+		} else {
+			providers = append(providers, provider)
+		}
+	}
+
+	Rates = providers
+}
+
+// FileRateProvider serves rates from a static JSON file, so conversions work offline:
+//
+//	{"base": "EUR", "rates": {"USD": 1.08, "GBP": 0.85, "JPY": 162.3}}
+//
+// Each rate is the number of units of that currency per unit of base. Rates between two
+// non-base currencies are derived through the base. The date is ignored.
+type FileRateProvider struct {
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+// NewFileRateProvider loads rates from the JSON file at path
+func NewFileRateProvider(path string) (*FileRateProvider, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := &FileRateProvider{}
+	if err := json.Unmarshal(content, provider); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if provider.Base == "" {
+		return nil, errors.New("exchange rates file has no base currency")
+	}
+
+	provider.Base = strings.ToUpper(provider.Base)
+	rates := make(map[string]float64, len(provider.Rates)+1)
+	for currency, rate := range provider.Rates {
+		if rate <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate for %s", currency)
+		}
+		rates[strings.ToUpper(currency)] = rate
+	}
+	rates[provider.Base] = 1
+	provider.Rates = rates
+
+	return provider, nil
+}
+
+// Rate returns the rate from one currency to another via the base currency
+func (p *FileRateProvider) Rate(from, to string, date time.Time) (float64, error) {
+	fromRate, ok := p.Rates[strings.ToUpper(from)]
+	if !ok {
+		return 0, fmt.Errorf("no exchange rate for %s", from)
+	}
+	toRate, ok := p.Rates[strings.ToUpper(to)]
+	if !ok {
+		return 0, fmt.Errorf("no exchange rate for %s", to)
+	}
+	return toRate / fromRate, nil
+}
+
+// ConvertMoney converts an amount in minor units of one currency into minor units of
+// another at the given rate, rounding half away from zero. Currencies with a different
+// number of decimals (e.g. JPY into USD) are accounted for.
+func ConvertMoney(amount db.Money, from, to string, rate float64) db.Money {
+	scale := math.Pow10(db.CurrencyExponent(to) - db.CurrencyExponent(from))
+	return db.Money(math.Round(float64(amount) * rate * scale))
+}
+
+// ConvertAt converts an amount between currencies at the rate Rates gives for the date
+func ConvertAt(amount db.Money, from, to string, date time.Time) (db.Money, error) {
+	if strings.EqualFold(from, to) {
+		return amount, nil
+	}
+	if Rates == nil {
+		return 0, fmt.Errorf("no exchange rate available from %s to %s", from, to)
+	}
+
+	rate, err := Rates.Rate(from, to, date)
+	if err != nil {
+		return 0, err
+	}
+	return ConvertMoney(amount, from, to, rate), nil
+}
+
+// resolveExchangeRate returns the rate to use for converting a transaction into the group
+// currency. A rate given by the client wins, then a rate pinned on the group; otherwise
+// it is looked up from Rates.
+func resolveExchangeRate(from string, group *db.Group, date time.Time, requested float64) (float64, error) {
+	to := group.Currency
+	if strings.EqualFold(from, to) {
+		return 1, nil
+	}
+	if requested > 0 {
+		return requested, nil
+	}
+	if rate, ok := group.PinnedRates[strings.ToUpper(from)]; ok && rate > 0 {
+		return rate, nil
+	}
+	if Rates == nil {
+		return 0, fmt.Errorf("no exchange rate available from %s to %s, please provide one", from, to)
+	}
+
+	rate, err := Rates.Rate(from, to, date)
+	if err != nil {
+		return 0, err
+	}
+	if rate <= 0 {
+		return 0, fmt.Errorf("invalid exchange rate from %s to %s", from, to)
+	}
+	return rate, nil
+}
+
+// setGroupCurrencyConversion records the group currency, exchange rate and converted
+// total on a transaction. Payers, splits and participants are converted separately
+// once they are built, by allocating the converted total.
+func setGroupCurrencyConversion(transaction *db.Transaction, group *db.Group, requestedRate float64) error {
+	if transaction.Currency == "" {
+		transaction.Currency = group.Currency
+	}
+
+	rate, err := resolveExchangeRate(transaction.Currency, group, transaction.Date, requestedRate)
+	if err != nil {
+		return err
+	}
+
+	transaction.GroupCurrency = group.Currency
+	transaction.ExchangeRate = rate
+	transaction.ConvertedAmount = ConvertMoney(transaction.Amount, transaction.Currency, group.Currency, rate)
+	if transaction.ConvertedAmount <= 0 {
+		return errors.New("amount is too small to convert into the group currency")
+	}
+
+	return nil
+}
+
+// allocateConverted splits a converted total over parts in proportion to their original
+// amounts, so the converted parts always add up exactly to the converted total
+func allocateConverted(total db.Money, amounts []db.Money) []db.Money {
+	weights := make([]int64, len(amounts))
+	for i, amount := range amounts {
+		weights[i] = int64(amount)
+	}
+	return total.Allocate(weights)
+}
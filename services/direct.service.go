@@ -0,0 +1,157 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models"
+	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
+	"github.com/kamva/mgm/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var errDirectGroup = errors.New("direct expenses between friends cannot be managed as a group")
+
+// CreateFriendExpense records an expense between the user and a friend without a group.
+// It is stored in the pair's direct group for the currency, so it has its own balance and
+// shows up in the feed and analytics like any other expense.
+func (ts *TransactionService) CreateFriendExpense(userID, friendID primitive.ObjectID, req models.CreateFriendExpenseRequest) (*db.Transaction, error) {
+	for _, payer := range req.Payers {
+		if !isPair(payer.UserID, userID, friendID) {
+			return nil, errors.New("only you and your friend can pay a direct expense")
+		}
+	}
+	for _, split := range req.Splits {
+		if !isPair(split.UserID, userID, friendID) {
+			return nil, errors.New("a direct expense can only be split between you and your friend")
+		}
+	}
+
+	group, err := directGroup(userID, friendID, req.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	return ts.CreateExpenseTransaction(userID, models.CreateExpenseTransactionRequest{
+		GroupID:     group.ID.Hex(),
+		Description: req.Description,
+		Amount:      req.Amount,
+		Currency:    group.Currency,
+		SplitType:   req.SplitType,
+		Payers:      req.Payers,
+		Splits:      req.Splits,
+		Category:    req.Category,
+		Notes:       req.Notes,
+		Date:        req.Date,
+		Timezone:    req.Timezone,
+	})
+}
+
+// CreateFriendSettlement records a payment between the user and a friend without a group
+func (ts *TransactionService) CreateFriendSettlement(userID, friendID primitive.ObjectID, req models.CreateFriendSettlementRequest) (*db.Transaction, error) {
+	if !isPair(req.PayerID, userID, friendID) {
+		return nil, errors.New("the payer must be you or your friend")
+	}
+
+	payerID, payeeID := userID, friendID
+	if req.PayerID == friendID.Hex() {
+		payerID, payeeID = friendID, userID
+	}
+
+	group, err := directGroup(userID, friendID, req.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	return ts.CreateSettlementTransaction(group.ID, payerID, payeeID, req.Amount, group.Currency, 0, req.Notes, req.IsCompleted, userID, req.Date, req.Timezone)
+}
+
+// GetFriendTransactions returns the direct expenses and settlements between the user and a friend
+func (ts *TransactionService) GetFriendTransactions(userID, friendID primitive.ObjectID, query models.TransactionQuery) (*models.TransactionPage, error) {
+	var groups []*db.Group
+	err := mgm.Coll(&db.Group{}).SimpleFind(&groups, bson.M{
+		"direct":  true,
+		"members": bson.M{"$all": []primitive.ObjectID{userID, friendID}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(groups) == 0 {
+		return &models.TransactionPage{Transactions: []*db.Transaction{}}, nil
+	}
+
+	groupIDs := make([]primitive.ObjectID, len(groups))
+	for i, group := range groups {
+		groupIDs[i] = group.ID
+	}
+
+	loc, _, err := viewerLocation(query.Timezone, userID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return findTransactions(bson.M{
+		"group_id":   bson.M{"$in": groupIDs},
+		"deleted_at": bson.M{"$exists": false},
+	}, query, loc)
+}
+
+// directGroup returns the direct group of two friends in a currency, creating it the
+// first time they share an expense in that currency
+func directGroup(userID, friendID primitive.ObjectID, currency string) (*db.Group, error) {
+	if userID == friendID {
+		return nil, errors.New("cannot share a direct expense with yourself")
+	}
+
+	friends, err := AreFriends(userID, friendID)
+	if err != nil {
+		return nil, err
+	}
+	if !friends {
+		return nil, errors.New("direct expenses can only be shared with friends")
+	}
+
+	user, err := FindUserById(userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	friend, err := FindUserById(friendID)
+	if err != nil {
+		return nil, errors.New("friend not found")
+	}
+
+	newGroup := db.NewDirectGroup(user, friend, strings.ToUpper(currency))
+	now := time.Now().UTC()
+
+	// Upserting on the pair's key keeps two concurrent first expenses on one group
+	group := &db.Group{}
+	err = mgm.Coll(group).FindOneAndUpdate(mgm.Ctx(), bson.M{
+		"direct_key": newGroup.DirectKey,
+		"is_active":  true,
+	}, bson.M{
+		"$setOnInsert": bson.M{
+			"name":        newGroup.Name,
+			"description": newGroup.Description,
+			"created_by":  newGroup.CreatedBy,
+			"members":     newGroup.Members,
+			"currency":    newGroup.Currency,
+			"direct":      newGroup.Direct,
+			"created_at":  now,
+			"updated_at":  now,
+		},
+	}, options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(group)
+	if err != nil {
+		return nil, err
+	}
+
+	return group, nil
+}
+
+// isPair tells whether a hex user ID is one of the two users
+func isPair(id string, userID, friendID primitive.ObjectID) bool {
+	return id == userID.Hex() || id == friendID.Hex()
+}
@@ -0,0 +1,327 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
+	"github.com/kamva/mgm/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	RateFormatCSV = "csv"
+	RateFormatXML = "xml"
+
+	// ecbBaseCurrency is the base of every rate published by the European Central Bank
+	ecbBaseCurrency = "EUR"
+
+	// rateImportBatchSize caps the number of upserts sent in one bulk write
+	rateImportBatchSize = 1000
+)
+
+var errRateNotFound = errors.New("exchange rate not found")
+
+// DBRateProvider serves daily rates stored in the exchange_rates collection. A lookup
+// uses the most recent rate on or before the requested day, so weekends and holidays
+// fall back to the last published rate. Pairs that are not stored directly are
+// derived from their inverse or through a common base currency.
+type DBRateProvider struct{}
+
+// Rate returns how many units of `to` one unit of `from` was worth on the given date
+func (p *DBRateProvider) Rate(from, to string, date time.Time) (float64, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return 1, nil
+	}
+
+	rate, err := p.pairRate(from, to, date)
+	if err != errRateNotFound {
+		return rate, err
+	}
+
+	// Derive a cross rate through a base both currencies are quoted against
+	bases, err := mgm.Coll(&db.ExchangeRate{}).Distinct(mgm.Ctx(), "base", bson.M{})
+	if err != nil {
+		return 0, err
+	}
+	for _, value := range bases {
+		base, ok := value.(string)
+		if !ok || base == from || base == to {
+			continue
+		}
+
+		fromRate, err := p.pairRate(base, from, date)
+		if err == errRateNotFound {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+
+		toRate, err := p.pairRate(base, to, date)
+		if err == errRateNotFound {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+
+		return toRate / fromRate, nil
+	}
+
+	return 0, fmt.Errorf("no exchange rate from %s to %s on or before %s", from, to, date.Format("2006-01-02"))
+}
+
+// pairRate looks up a stored rate for the pair, or the inverse of the reversed pair
+func (p *DBRateProvider) pairRate(from, to string, date time.Time) (float64, error) {
+	rate, err := latestExchangeRate(from, to, date)
+	if err == nil {
+		return rate.Rate, nil
+	}
+	if err != errRateNotFound {
+		return 0, err
+	}
+
+	rate, err = latestExchangeRate(to, from, date)
+	if err != nil {
+		return 0, err
+	}
+	return 1 / rate.Rate, nil
+}
+
+// latestExchangeRate finds the most recent stored rate for base/quote on or before date
+func latestExchangeRate(base, quote string, date time.Time) (*db.ExchangeRate, error) {
+	rate := &db.ExchangeRate{}
+	err := mgm.Coll(rate).FindOne(mgm.Ctx(), bson.M{
+		"base":  base,
+		"quote": quote,
+		"date":  bson.M{"$lte": db.RateDay(date)},
+	}, options.FindOne().SetSort(bson.D{{Key: "date", Value: -1}})).Decode(rate)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errRateNotFound
+		}
+		return nil, err
+	}
+	return rate, nil
+}
+
+// rateProviders asks each provider in turn and returns the first rate found
+type rateProviders []RateProvider
+
+func (providers rateProviders) Rate(from, to string, date time.Time) (float64, error) {
+	err := fmt.Errorf("no exchange rate from %s to %s", from, to)
+	for _, provider := range providers {
+		var rate float64
+		if rate, err = provider.Rate(from, to, date); err == nil {
+			return rate, nil
+		}
+	}
+	return 0, err
+}
+
+// ImportExchangeRates loads daily rates from a CSV file or an ECB-style XML file and
+// stores them, replacing any rate already stored for the same pair and day. It returns
+// the number of rates imported.
+//
+// CSV files either have a `date,base,quote,rate` header with one rate per row, or use the
+// ECB history layout: a `Date` column followed by one column per currency against EUR.
+func ImportExchangeRates(reader io.Reader, format string) (int, error) {
+	var rates []*db.ExchangeRate
+	var err error
+
+	switch strings.ToLower(format) {
+	case RateFormatCSV:
+		rates, err = parseRatesCSV(reader)
+	case RateFormatXML:
+		rates, err = parseRatesXML(reader)
+	default:
+		return 0, errors.New("unsupported exchange rate format, use csv or xml")
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(rates) == 0 {
+		return 0, errors.New("no exchange rates found in file")
+	}
+
+	coll := mgm.Coll(&db.ExchangeRate{})
+	now := time.Now().UTC()
+
+	for start := 0; start < len(rates); start += rateImportBatchSize {
+		end := start + rateImportBatchSize
+		if end > len(rates) {
+			end = len(rates)
+		}
+
+		var writes []mongo.WriteModel
+		for _, rate := range rates[start:end] {
+			writes = append(writes, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"base": rate.Base, "quote": rate.Quote, "date": rate.Date}).
+				SetUpdate(bson.M{
+					"$set": bson.M{
+						"rate":       rate.Rate,
+						"source":     rate.Source,
+						"updated_at": now,
+					},
+					"$setOnInsert": bson.M{"created_at": now},
+				}).
+				SetUpsert(true))
+		}
+
+		if _, err := coll.BulkWrite(mgm.Ctx(), writes, options.BulkWrite().SetOrdered(false)); err != nil {
+			return start, err
+		}
+	}
+
+	return len(rates), nil
+}
+
+func parseRatesCSV(reader io.Reader) ([]*db.ExchangeRate, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+	csvReader.FieldsPerRecord = -1
+
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("reading csv: %w", err)
+	}
+	if len(records) < 2 {
+		return nil, errors.New("csv file has no rates")
+	}
+
+	header := make([]string, len(records[0]))
+	for i, column := range records[0] {
+		header[i] = strings.ToLower(strings.TrimSpace(column))
+	}
+
+	if len(header) == 4 && header[0] == "date" && header[1] == "base" && header[2] == "quote" && header[3] == "rate" {
+		return parseLongRatesCSV(records[1:])
+	}
+	if header[0] == "date" {
+		return parseECBRatesCSV(records[0], records[1:])
+	}
+
+	return nil, errors.New("csv header must be date,base,quote,rate or Date followed by currency codes")
+}
+
+func parseLongRatesCSV(rows [][]string) ([]*db.ExchangeRate, error) {
+	var rates []*db.ExchangeRate
+	for i, row := range rows {
+		if len(row) != 4 {
+			return nil, fmt.Errorf("csv line %d: expected 4 columns", i+2)
+		}
+
+		date, err := parseRateDate(row[0])
+		if err != nil {
+			return nil, fmt.Errorf("csv line %d: %w", i+2, err)
+		}
+		rate, err := parseRate(row[3])
+		if err != nil {
+			return nil, fmt.Errorf("csv line %d: %w", i+2, err)
+		}
+		base, quote := strings.TrimSpace(row[1]), strings.TrimSpace(row[2])
+		if len(base) != 3 || len(quote) != 3 {
+			return nil, fmt.Errorf("csv line %d: invalid currency code", i+2)
+		}
+
+		rates = append(rates, db.NewExchangeRate(base, quote, rate, date, RateFormatCSV))
+	}
+	return rates, nil
+}
+
+func parseECBRatesCSV(header []string, rows [][]string) ([]*db.ExchangeRate, error) {
+	var rates []*db.ExchangeRate
+	for i, row := range rows {
+		date, err := parseRateDate(row[0])
+		if err != nil {
+			return nil, fmt.Errorf("csv line %d: %w", i+2, err)
+		}
+
+		for column := 1; column < len(row) && column < len(header); column++ {
+			currency := strings.TrimSpace(header[column])
+			value := strings.TrimSpace(row[column])
+			// The ECB history marks days without a rate as N/A, and lines end with a trailing comma
+			if len(currency) != 3 || value == "" || value == "N/A" {
+				continue
+			}
+
+			rate, err := parseRate(value)
+			if err != nil {
+				return nil, fmt.Errorf("csv line %d: %w", i+2, err)
+			}
+			rates = append(rates, db.NewExchangeRate(ecbBaseCurrency, currency, rate, date, "ecb"))
+		}
+	}
+	return rates, nil
+}
+
+// ecbEnvelope mirrors the ECB euro foreign exchange reference rate feed:
+//
+//	<Cube><Cube time="2024-01-02"><Cube currency="USD" rate="1.0956"/>...</Cube></Cube>
+type ecbEnvelope struct {
+	Days []struct {
+		Time  string `xml:"time,attr"`
+		Rates []struct {
+			Currency string `xml:"currency,attr"`
+			Rate     string `xml:"rate,attr"`
+		} `xml:"Cube"`
+	} `xml:"Cube>Cube"`
+}
+
+func parseRatesXML(reader io.Reader) ([]*db.ExchangeRate, error) {
+	var envelope ecbEnvelope
+	if err := xml.NewDecoder(reader).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("reading xml: %w", err)
+	}
+
+	var rates []*db.ExchangeRate
+	for _, day := range envelope.Days {
+		date, err := parseRateDate(day.Time)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range day.Rates {
+			rate, err := parseRate(entry.Rate)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", day.Time, entry.Currency, err)
+			}
+			rates = append(rates, db.NewExchangeRate(ecbBaseCurrency, entry.Currency, rate, date, "ecb"))
+		}
+	}
+	return rates, nil
+}
+
+func parseRateDate(value string) (time.Time, error) {
+	date, err := time.Parse("2006-01-02", strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", value)
+	}
+	return date, nil
+}
+
+func parseRate(value string) (float64, error) {
+	rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || rate <= 0 {
+		return 0, fmt.Errorf("invalid rate %q", value)
+	}
+	return rate, nil
+}
+
+// EnsureExchangeRateIndexes creates the unique index that keeps one rate per currency
+// pair and day, which rate lookups and imports rely on
+func EnsureExchangeRateIndexes() error {
+	_, err := mgm.Coll(&db.ExchangeRate{}).Indexes().CreateOne(mgm.Ctx(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "base", Value: 1}, {Key: "quote", Value: 1}, {Key: "date", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
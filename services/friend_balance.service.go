@@ -0,0 +1,218 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models"
+	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
+	"github.com/kamva/mgm/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// friendGroupDebt is what another member owes the user in one shared group
+type friendGroupDebt struct {
+	Group  *db.Group
+	Amount db.Money // Positive: the friend owes the user
+}
+
+// GetFriendBalances returns, for everyone the user shares a group with, what they owe each
+// other in each group and the net across groups per currency
+func (ts *TransactionService) GetFriendBalances(userID primitive.ObjectID) ([]*models.FriendBalance, error) {
+	debts, err := ts.friendGroupDebts(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	balances := []*models.FriendBalance{}
+	for friendID, groupDebts := range debts {
+		friend, err := FindUserById(friendID)
+		if err != nil {
+			continue
+		}
+
+		balance := &models.FriendBalance{
+			UserID:        friendID,
+			UserName:      friend.Name,
+			NetByCurrency: make(map[string]db.Money),
+		}
+		for _, debt := range groupDebts {
+			balance.Groups = append(balance.Groups, models.FriendGroupBalance{
+				GroupID:   debt.Group.ID,
+				GroupName: debt.Group.Name,
+				Currency:  debt.Group.Currency,
+				Amount:    debt.Amount,
+				Direct:    debt.Group.Direct,
+			})
+			balance.NetByCurrency[debt.Group.Currency] += debt.Amount
+		}
+		balances = append(balances, balance)
+	}
+
+	sort.Slice(balances, func(i, j int) bool {
+		return balances[i].UserName < balances[j].UserName
+	})
+
+	return balances, nil
+}
+
+// SettleUpWithFriend settles everything the user and a friend owe each other across
+// their shared groups. One settlement is created in each group with a debt, all linked
+// by a batch ID, and they are stored together in one MongoDB transaction. Settlements
+// between the two that are still waiting for confirmation are not in the balances yet,
+// so what they will settle is left out rather than settled twice.
+func (ts *TransactionService) SettleUpWithFriend(userID, friendID primitive.ObjectID, req models.SettleUpWithFriendRequest) ([]*db.Transaction, error) {
+	if userID == friendID {
+		return nil, errors.New("cannot settle up with yourself")
+	}
+
+	debts, err := ts.friendGroupDebts(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := FindUserById(userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	friend, err := FindUserById(friendID)
+	if err != nil {
+		return nil, errors.New("friend not found")
+	}
+
+	pending, err := openSettlementAmounts(userID, friendID)
+	if err != nil {
+		return nil, err
+	}
+
+	batchID := primitive.NewObjectID()
+	var transactions []*db.Transaction
+	var groups []*db.Group
+	awaitingConfirmation := false
+
+	for _, debt := range debts[friendID] {
+		if req.Currency != "" && !strings.EqualFold(debt.Group.Currency, req.Currency) {
+			continue
+		}
+
+		amount := debt.Amount - pending[debt.Group.ID]
+		if amount == 0 {
+			awaitingConfirmation = true
+			continue
+		}
+
+		payer, payee := friend, user
+		if amount < 0 {
+			payer, payee, amount = user, friend, -amount
+		}
+
+		transaction, err := buildSettlementTransaction(debt.Group, payer, payee, amount, debt.Group.Currency, 0, req.Notes, true, userID, "", "")
+		if err != nil {
+			return nil, err
+		}
+		transaction.Description = "Settle up: " + payer.Name + " paid " + payee.Name
+		transaction.BatchID = batchID
+
+		transactions = append(transactions, transaction)
+		groups = append(groups, debt.Group)
+	}
+
+	if len(transactions) == 0 {
+		if awaitingConfirmation {
+			return nil, errors.New("everything with this person is already settled, waiting for confirmation")
+		}
+		return nil, errors.New("nothing to settle with this person")
+	}
+
+	err = runInTransaction(func(sc mongo.SessionContext) error {
+		for i, transaction := range transactions {
+			if err := ts.insertTransaction(sc, transaction, groups[i].Currency); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, transaction := range transactions {
+		go ts.sendTransactionNotifications(transaction, groups[i])
+	}
+
+	return transactions, nil
+}
+
+// openSettlementAmounts returns, per group, how much of the settlements between the user
+// and a friend that are not confirmed or cancelled is still to be settled, in the group
+// currency. Like debts, it is positive when the friend is paying the user.
+func openSettlementAmounts(userID, friendID primitive.ObjectID) (map[primitive.ObjectID]db.Money, error) {
+	var settlements []*db.Transaction
+	err := mgm.Coll(&db.Transaction{}).SimpleFind(&settlements, bson.M{
+		"type":   db.TransactionTypeSettlement,
+		"status": bson.M{"$in": []db.SettlementStatus{db.SettlementProposed, db.SettlementPaid, db.SettlementDisputed}},
+		"$or": []bson.M{
+			{"payers.user_id": userID, "splits.user_id": friendID},
+			{"payers.user_id": friendID, "splits.user_id": userID},
+		},
+		"deleted_at": bson.M{"$exists": false},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make(map[primitive.ObjectID]db.Money)
+	for _, settlement := range settlements {
+		unsettled := settlement.ConvertedAmount - settledAmount(settlement)
+		switch payerID, payeeID := settlementParties(settlement); {
+		case payerID == friendID && payeeID == userID:
+			pending[settlement.GroupID] += unsettled
+		case payerID == userID && payeeID == friendID:
+			pending[settlement.GroupID] -= unsettled
+		}
+	}
+	return pending, nil
+}
+
+// friendGroupDebts works out, per other member, what they owe the user in each active group
+// the user belongs to. Groups where the two are square are left out.
+func (ts *TransactionService) friendGroupDebts(userID primitive.ObjectID) (map[primitive.ObjectID][]friendGroupDebt, error) {
+	var groups []*db.Group
+	if err := mgm.Coll(&db.Group{}).SimpleFind(&groups, bson.M{
+		"members":   userID,
+		"is_active": true,
+	}); err != nil {
+		return nil, err
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+
+	debts := make(map[primitive.ObjectID][]friendGroupDebt)
+	for _, group := range groups {
+		pairs, err := pairwiseDebts(group.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		for pair, amount := range pairs {
+			// A positive amount means A owes B
+			var friendID primitive.ObjectID
+			switch userID {
+			case pair.A:
+				friendID, amount = pair.B, -amount
+			case pair.B:
+				friendID = pair.A
+			default:
+				continue
+			}
+			if amount == 0 {
+				continue
+			}
+			debts[friendID] = append(debts[friendID], friendGroupDebt{Group: group, Amount: amount})
+		}
+	}
+
+	return debts, nil
+}
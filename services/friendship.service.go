@@ -11,6 +11,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func SendFriendRequest(requesterID primitive.ObjectID, email string) error {
@@ -49,16 +50,24 @@ func SendFriendRequest(requesterID primitive.ObjectID, email string) error {
 			return errors.New("friend request already sent")
 		case db.FriendshipBlocked:
 			return errors.New("cannot send friend request")
+		case db.FriendshipRejected:
+			// A pair only has one friendship, so a rejected request is sent again in place
+			if err := resendFriendRequest(existing, requesterID, addressee.ID); err != nil {
+				return err
+			}
 		}
 	} else if err != mongo.ErrNoDocuments {
 		return err
-	}
-
-	// Create new friend request
-	friendship := db.NewFriendship(requesterID, addressee.ID)
-	err = mgm.Coll(friendship).Create(friendship)
-	if err != nil {
-		return err
+	} else {
+		// Create new friend request
+		friendship := db.NewFriendship(requesterID, addressee.ID)
+		err = mgm.Coll(friendship).Create(friendship)
+		if mongo.IsDuplicateKeyError(err) {
+			return errors.New("friend request already sent")
+		}
+		if err != nil {
+			return err
+		}
 	}
 
 	requester, err := FindUserById(requesterID)
@@ -90,6 +99,32 @@ func SendFriendRequest(requesterID primitive.ObjectID, email string) error {
 	return nil
 }
 
+// resendFriendRequest turns a rejected friendship back into a pending request from
+// requesterID, who may be the one that rejected it
+func resendFriendRequest(friendship *db.Friendship, requesterID, addresseeID primitive.ObjectID) error {
+	now := time.Now()
+	result, err := mgm.Coll(friendship).UpdateOne(mgm.Ctx(), bson.M{
+		"_id":    friendship.ID,
+		"status": db.FriendshipRejected,
+	}, bson.M{
+		"$set": bson.M{
+			"requester_id": requesterID,
+			"addressee_id": addresseeID,
+			"status":       db.FriendshipPending,
+			"requested_at": now,
+			"updated_at":   now,
+		},
+		"$unset": bson.M{"accepted_at": ""},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("friend request already sent")
+	}
+	return nil
+}
+
 func RespondToFriendRequest(friendshipID primitive.ObjectID, addresseeID primitive.ObjectID, accept bool) error {
 	friendship := &db.Friendship{}
 
@@ -232,6 +267,24 @@ func GetSentFriendRequests(userID primitive.ObjectID) ([]*models.SentFriendReque
 	return requests, nil
 }
 
+// AreFriends tells whether two users have an accepted friendship
+func AreFriends(userID, friendID primitive.ObjectID) (bool, error) {
+	count, err := mgm.Coll(&db.Friendship{}).CountDocuments(mgm.Ctx(), bson.M{
+		"$or": []bson.M{
+			{
+				"requester_id": userID,
+				"addressee_id": friendID,
+			},
+			{
+				"requester_id": friendID,
+				"addressee_id": userID,
+			},
+		},
+		"status": db.FriendshipAccepted,
+	})
+	return count > 0, err
+}
+
 func RemoveFriend(userID, friendID primitive.ObjectID) error {
 	// Find the friendship
 	friendship := &db.Friendship{}
@@ -295,3 +348,17 @@ func BlockUser(userID, targetUserID primitive.ObjectID) error {
 
 	return err
 }
+
+// EnsureFriendshipIndexes creates the friendship indexes. Requests are looked up from
+// both sides, and a pair can only have one friendship in each direction. Duplicates
+// must be removed first, see MigrateFriendshipIndexes.
+func EnsureFriendshipIndexes() error {
+	_, err := mgm.Coll(&db.Friendship{}).Indexes().CreateMany(mgm.Ctx(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "requester_id", Value: 1}, {Key: "addressee_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "addressee_id", Value: 1}, {Key: "status", Value: 1}}},
+	})
+	return err
+}
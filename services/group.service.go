@@ -2,6 +2,7 @@ package services
 
 import (
 	"errors"
+	"strings"
 
 	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
 	"github.com/kamva/mgm/v3"
@@ -11,8 +12,9 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func CreateGroup(name, description, currency string, createdBy primitive.ObjectID, memberIDs []string) (*db.Group, error) {
+func CreateGroup(name, description, currency, timezone string, createdBy primitive.ObjectID, memberIDs []string) (*db.Group, error) {
 	group := db.NewGroup(name, description, createdBy, currency)
+	group.Timezone = timezone
 
 	// Add additional members if provided
 	for _, memberIDStr := range memberIDs {
@@ -50,6 +52,7 @@ func GetUserGroups(userID primitive.ObjectID, page, limit int) ([]*db.Group, err
 	err := mgm.Coll(&db.Group{}).SimpleFind(&groups, bson.M{
 		"members":   userID,
 		"is_active": true,
+		"direct":    bson.M{"$ne": true}, // Direct expenses with friends are listed separately
 	}, findOptions)
 
 	return groups, err
@@ -81,6 +84,10 @@ func AddMemberToGroup(groupID, userID, newMemberID primitive.ObjectID) error {
 		return err
 	}
 
+	if group.Direct {
+		return errDirectGroup
+	}
+
 	// Check if new member exists
 	if _, err := FindUserById(newMemberID); err != nil {
 		return errors.New("user not found")
@@ -108,6 +115,10 @@ func RemoveMemberFromGroup(groupID, userID, memberToRemoveID primitive.ObjectID)
 		return err
 	}
 
+	if group.Direct {
+		return errDirectGroup
+	}
+
 	// Only creator can remove members
 	if group.CreatedBy != userID {
 		return errors.New("only group creator can remove members")
@@ -133,6 +144,10 @@ func DeleteGroup(groupID, userID primitive.ObjectID) error {
 		return err
 	}
 
+	if group.Direct {
+		return errDirectGroup
+	}
+
 	if group.CreatedBy != userID {
 		return errors.New("only group creator can delete the group")
 	}
@@ -160,12 +175,16 @@ func GetGroupMembers(groupID, userID primitive.ObjectID) ([]*db.User, error) {
 	return users, err
 }
 
-func UpdateGroup(groupID, userID primitive.ObjectID, name, description, currency string) (*db.Group, error) {
+func UpdateGroup(groupID, userID primitive.ObjectID, name, description, currency, timezone string) (*db.Group, error) {
 	group, err := GetGroupById(groupID, userID)
 	if err != nil {
 		return nil, err
 	}
 
+	if group.Direct {
+		return nil, errDirectGroup
+	}
+
 	if group.CreatedBy != userID {
 		return nil, errors.New("only group creator can update group details")
 	}
@@ -178,9 +197,15 @@ func UpdateGroup(groupID, userID primitive.ObjectID, name, description, currency
 	if description != "" {
 		updateDoc["description"] = description
 	}
-	if currency != "" {
+	if currency != "" && currency != group.Currency {
+		if err := checkCurrencyChangeable(groupID); err != nil {
+			return nil, err
+		}
 		updateDoc["currency"] = currency
 	}
+	if timezone != "" {
+		updateDoc["timezone"] = timezone
+	}
 
 	if len(updateDoc) == 0 {
 		return group, nil
@@ -195,3 +220,109 @@ func UpdateGroup(groupID, userID primitive.ObjectID, name, description, currency
 
 	return GetGroupById(groupID, userID)
 }
+
+// checkCurrencyChangeable refuses to change the currency of a group that already has
+// amounts in it. Transactions, the ledger and balances are all kept in the group
+// currency, so changing it would mix two currencies. Deleted transactions count too,
+// as they can be restored.
+func checkCurrencyChangeable(groupID primitive.ObjectID) error {
+	transactions, err := mgm.Coll(&db.Transaction{}).CountDocuments(mgm.Ctx(), bson.M{"group_id": groupID})
+	if err != nil {
+		return err
+	}
+	if transactions > 0 {
+		return errors.New("the currency of a group with transactions cannot be changed")
+	}
+
+	balances, err := mgm.Coll(&db.GroupBalance{}).CountDocuments(mgm.Ctx(), bson.M{
+		"group_id": groupID,
+		"balance":  bson.M{"$ne": 0},
+	})
+	if err != nil {
+		return err
+	}
+	if balances > 0 {
+		return errors.New("the currency of a group with outstanding balances cannot be changed")
+	}
+	return nil
+}
+
+// PinGroupExchangeRate pins the rate used to convert a currency into the group currency,
+// e.g. the rate actually obtained for a trip. It takes precedence over looked up rates.
+func PinGroupExchangeRate(groupID, userID primitive.ObjectID, currency string, rate float64) (*db.Group, error) {
+	group, err := GetGroupById(groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if group.CreatedBy != userID {
+		return nil, errors.New("only group creator can pin exchange rates")
+	}
+
+	currency = strings.ToUpper(currency)
+	if currency == strings.ToUpper(group.Currency) {
+		return nil, errors.New("cannot pin a rate for the group currency")
+	}
+	if rate <= 0 {
+		return nil, errors.New("exchange rate must be greater than 0")
+	}
+
+	_, err = mgm.Coll(group).UpdateOne(mgm.Ctx(), bson.M{"_id": groupID}, bson.M{
+		"$set": bson.M{"pinned_rates." + currency: rate},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return GetGroupById(groupID, userID)
+}
+
+// UnpinGroupExchangeRate removes a pinned rate so the currency is converted at looked up rates again
+func UnpinGroupExchangeRate(groupID, userID primitive.ObjectID, currency string) (*db.Group, error) {
+	group, err := GetGroupById(groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if group.CreatedBy != userID {
+		return nil, errors.New("only group creator can unpin exchange rates")
+	}
+
+	currency = strings.ToUpper(currency)
+	if _, ok := group.PinnedRates[currency]; !ok {
+		return nil, errors.New("no exchange rate pinned for this currency")
+	}
+
+	_, err = mgm.Coll(group).UpdateOne(mgm.Ctx(), bson.M{"_id": groupID}, bson.M{
+		"$unset": bson.M{"pinned_rates." + currency: ""},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return GetGroupById(groupID, userID)
+}
+
+// EnsureGroupIndexes creates the group indexes: groups by member, and a unique key so two
+// friends only ever have one active direct group per currency
+func EnsureGroupIndexes() error {
+	_, err := mgm.Coll(&db.Group{}).Indexes().CreateMany(mgm.Ctx(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "members", Value: 1}, {Key: "is_active", Value: 1}}},
+		{
+			Keys: bson.D{{Key: "direct_key", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+				"direct_key": bson.M{"$exists": true},
+				"is_active":  true,
+			}),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// A user's balances across their groups
+	_, err = mgm.Coll(&db.GroupBalance{}).Indexes().CreateOne(mgm.Ctx(), mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}},
+	})
+	return err
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
+	"github.com/go-redis/redis/v8"
+	"github.com/kamva/mgm/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// idempotencyTTL is how long a key and its response are remembered
+	idempotencyTTL = 24 * time.Hour
+	// idempotencyLockTimeout is how long a request can hold its key without finishing
+	// before it is taken to have died, so a retry is not locked out for a whole day
+	idempotencyLockTimeout = 2 * time.Minute
+)
+
+// idempotencyStore keeps idempotency records. Claiming a key must be atomic, so two
+// concurrent requests with the same key can never both run.
+type idempotencyStore interface {
+	// claim stores a new record, or returns the existing one if the key is taken
+	claim(record *db.IdempotencyRecord) (*db.IdempotencyRecord, error)
+	save(record *db.IdempotencyRecord) error
+	release(record *db.IdempotencyRecord) error
+}
+
+// IdempotentRequest is a request being handled under an Idempotency-Key
+type IdempotentRequest struct {
+	Record *db.IdempotencyRecord
+	store  idempotencyStore
+}
+
+// BeginIdempotentRequest claims an Idempotency-Key for a request. If the key has been
+// used before, the record it was used with is returned instead and the request must not
+// run. Keys are kept in Redis when it is enabled, and in MongoDB when it is not or when
+// Redis cannot be reached.
+func BeginIdempotentRequest(userID primitive.ObjectID, key, requestHash string) (*IdempotentRequest, *db.IdempotencyRecord, error) {
+	stores := []idempotencyStore{mongoIdempotencyStore{}}
+	if Config.UseRedis {
+		stores = []idempotencyStore{redisIdempotencyStore{}, mongoIdempotencyStore{}}
+	}
+
+	var err error
+	for _, store := range stores {
+		var existing *db.IdempotencyRecord
+		now := time.Now()
+		record := db.NewIdempotencyRecord(userID, key, requestHash, now.Add(idempotencyTTL))
+		record.CreatedAt = now
+
+		existing, err = store.claim(record)
+		if err == nil && existing != nil && !existing.Completed && now.Sub(existing.CreatedAt) > idempotencyLockTimeout {
+			// The request holding the key never finished; let this one have it. Only the
+			// stale record is freed, so of two retries taking over at once only one can
+			// claim the key.
+			if err = store.release(existing); err == nil {
+				existing, err = store.claim(record)
+			}
+		}
+		if err != nil {
+			log.Printf("Idempotency store unavailable, trying the next one: %s", err.Error())
+			continue
+		}
+
+		if existing != nil {
+			return nil, existing, nil
+		}
+		return &IdempotentRequest{Record: record, store: store}, nil, nil
+	}
+
+	return nil, nil, err
+}
+
+// Complete stores the response of the request, to be replayed for retries
+func (r *IdempotentRequest) Complete(statusCode int, body []byte) {
+	r.Record.Completed = true
+	r.Record.StatusCode = statusCode
+	r.Record.Body = body
+
+	if err := r.store.save(r.Record); err != nil {
+		log.Printf("Error saving idempotent response for key %s: %s", r.Record.Key, err.Error())
+	}
+}
+
+// Abandon frees the key, so the request can be retried with it
+func (r *IdempotentRequest) Abandon() {
+	if err := r.store.release(r.Record); err != nil {
+		log.Printf("Error releasing idempotency key %s: %s", r.Record.Key, err.Error())
+	}
+}
+
+// EnsureIdempotencyIndexes creates the indexes the MongoDB idempotency store relies on:
+// a unique index so a key can only be claimed once, and a TTL index to expire old keys
+func EnsureIdempotencyIndexes() error {
+	_, err := mgm.Coll(&db.IdempotencyRecord{}).Indexes().CreateMany(mgm.Ctx(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "key", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	return err
+}
+
+// redisIdempotencyStore keeps records as JSON under a key per user. Each record gets its
+// own ID when claimed, so a request only ever replaces or frees the record it claimed and
+// never one that took over its key.
+type redisIdempotencyStore struct{}
+
+// redisReleaseIfOwned deletes KEYS[1] only if it still holds the record with ID ARGV[1]
+var redisReleaseIfOwned = redis.NewScript(`
+local value = redis.call("GET", KEYS[1])
+if value and cjson.decode(value).id == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// redisSaveIfOwned sets KEYS[1] to ARGV[2] for ARGV[3] milliseconds only if it still holds
+// the record with ID ARGV[1]
+var redisSaveIfOwned = redis.NewScript(`
+local value = redis.call("GET", KEYS[1])
+if value and cjson.decode(value).id == ARGV[1] then
+	return redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+end
+return false
+`)
+
+func redisIdempotencyKey(record *db.IdempotencyRecord) string {
+	return "idempotency:" + record.UserID.Hex() + ":" + record.Key
+}
+
+func (redisIdempotencyStore) claim(record *db.IdempotencyRecord) (*db.IdempotencyRecord, error) {
+	if record.ID.IsZero() {
+		record.ID = primitive.NewObjectID()
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	client := GetRedisDefaultClient()
+	claimed, err := client.SetNX(context.Background(), redisIdempotencyKey(record), data, idempotencyTTL).Result()
+	if err != nil || claimed {
+		return nil, err
+	}
+
+	data, err = client.Get(context.Background(), redisIdempotencyKey(record)).Bytes()
+	if err == redis.Nil {
+		// Expired between the two calls
+		return redisIdempotencyStore{}.claim(record)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	existing := &db.IdempotencyRecord{}
+	if err := json.Unmarshal(data, existing); err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+func (redisIdempotencyStore) save(record *db.IdempotencyRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	err = redisSaveIfOwned.Run(context.Background(), GetRedisDefaultClient(), []string{redisIdempotencyKey(record)},
+		record.ID.Hex(), data, time.Until(record.ExpiresAt).Milliseconds()).Err()
+	if err == redis.Nil {
+		// Another request took the key over, its record stays
+		return nil
+	}
+	return err
+}
+
+func (redisIdempotencyStore) release(record *db.IdempotencyRecord) error {
+	return redisReleaseIfOwned.Run(context.Background(), GetRedisDefaultClient(), []string{redisIdempotencyKey(record)}, record.ID.Hex()).Err()
+}
+
+// mongoIdempotencyStore keeps records in the idempotency_keys collection
+type mongoIdempotencyStore struct{}
+
+func (mongoIdempotencyStore) claim(record *db.IdempotencyRecord) (*db.IdempotencyRecord, error) {
+	err := mgm.Coll(record).Create(record)
+	if err == nil || !mongo.IsDuplicateKeyError(err) {
+		return nil, err
+	}
+
+	existing := &db.IdempotencyRecord{}
+	err = mgm.Coll(existing).First(bson.M{"user_id": record.UserID, "key": record.Key}, existing)
+	if err == mongo.ErrNoDocuments {
+		// Released between the two calls
+		return mongoIdempotencyStore{}.claim(record)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Expired records linger until MongoDB's TTL monitor removes them
+	if existing.ExpiresAt.Before(time.Now()) {
+		if err := (mongoIdempotencyStore{}).release(existing); err != nil {
+			return nil, err
+		}
+		return mongoIdempotencyStore{}.claim(record)
+	}
+	return existing, nil
+}
+
+func (mongoIdempotencyStore) save(record *db.IdempotencyRecord) error {
+	_, err := mgm.Coll(record).UpdateOne(mgm.Ctx(), bson.M{"_id": record.ID}, bson.M{
+		"$set": bson.M{
+			"completed":   record.Completed,
+			"status_code": record.StatusCode,
+			"body":        record.Body,
+			"updated_at":  time.Now(),
+		},
+	})
+	return err
+}
+
+func (mongoIdempotencyStore) release(record *db.IdempotencyRecord) error {
+	_, err := mgm.Coll(record).DeleteOne(mgm.Ctx(), bson.M{"_id": record.ID})
+	return err
+}
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
+	"github.com/kamva/mgm/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// rebuildAttempts caps how often a rebuild starts over when balances change under it
+const rebuildAttempts = 3
+
+var errBalancesChanged = errors.New("balances changed while they were being rebuilt, please try again")
+
+// ledgerTotals is the sum of a member's ledger entries in a group
+type ledgerTotals struct {
+	UserID            primitive.ObjectID `bson:"_id"`
+	UserName          string             `bson:"user_name"`
+	Currency          string             `bson:"currency"`
+	TotalPaid         db.Money           `bson:"total_paid"`
+	TotalOwed         db.Money           `bson:"total_owed"`
+	LastTransactionID primitive.ObjectID `bson:"last_transaction_id"`
+}
+
+// recordLedgerEntry appends an entry to the ledger and folds it into the member's group
+// balance
+func recordLedgerEntry(sc mongo.SessionContext, entry *db.LedgerEntry) error {
+	if err := mgm.Coll(entry).CreateWithCtx(sc, entry); err != nil {
+		return err
+	}
+	return projectLedgerEntry(sc, entry)
+}
+
+// projectLedgerEntry adds a ledger entry to a group balance with a single atomic update,
+// creating the balance on the member's first entry. Nothing is read first, so concurrent
+// transactions in the same group cannot overwrite each other's changes.
+func projectLedgerEntry(ctx context.Context, entry *db.LedgerEntry) error {
+	now := time.Now().UTC()
+
+	_, err := mgm.Coll(&db.GroupBalance{}).UpdateOne(ctx, bson.M{
+		"group_id": entry.GroupID,
+		"user_id":  entry.UserID,
+	}, bson.M{
+		"$inc": bson.M{
+			"total_paid": entry.PaidDelta,
+			"total_owed": entry.OwedDelta,
+			"balance":    entry.PaidDelta - entry.OwedDelta,
+			"version":    1,
+		},
+		"$set": bson.M{
+			"user_name":           entry.UserName,
+			"last_transaction_id": entry.TransactionID,
+			"last_updated":        now,
+			"updated_at":          now,
+		},
+		"$setOnInsert": bson.M{
+			"currency":   entry.Currency,
+			"created_at": now,
+		},
+	}, options.Update().SetUpsert(true))
+	return err
+}
+
+// groupLedgerTotals sums a group's ledger entries per member
+func groupLedgerTotals(ctx context.Context, groupID primitive.ObjectID) (map[primitive.ObjectID]*ledgerTotals, error) {
+	cursor, err := mgm.Coll(&db.LedgerEntry{}).Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"group_id": groupID}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":                 "$user_id",
+			"user_name":           bson.M{"$last": "$user_name"},
+			"currency":            bson.M{"$last": "$currency"},
+			"total_paid":          bson.M{"$sum": "$paid_delta"},
+			"total_owed":          bson.M{"$sum": "$owed_delta"},
+			"last_transaction_id": bson.M{"$last": "$transaction_id"},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*ledgerTotals
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	totals := make(map[primitive.ObjectID]*ledgerTotals, len(results))
+	for _, result := range results {
+		totals[result.UserID] = result
+	}
+	return totals, nil
+}
+
+// RebuildGroupBalances recomputes a group's balances from its ledger, e.g. after a
+// projection update was lost. Every write is conditional on the balance's version, so a
+// transaction that lands during the rebuild makes it start over instead of being lost.
+func RebuildGroupBalances(groupID primitive.ObjectID) error {
+	for attempt := 0; attempt < rebuildAttempts; attempt++ {
+		err := rebuildGroupBalances(mgm.Ctx(), groupID)
+		if err != errBalancesChanged {
+			return err
+		}
+	}
+	return errBalancesChanged
+}
+
+// rebuildGroupBalances makes one attempt at a rebuild. Passing a session context runs it
+// as part of that session's transaction.
+func rebuildGroupBalances(ctx context.Context, groupID primitive.ObjectID) error {
+	coll := mgm.Coll(&db.GroupBalance{})
+
+	// Balances are read before the ledger, so an entry missing from the totals below
+	// has not been projected yet either and will bump the version we compare against
+	var balances []*db.GroupBalance
+	if err := coll.SimpleFindWithCtx(ctx, &balances, bson.M{"group_id": groupID}); err != nil {
+		return err
+	}
+
+	totals, err := groupLedgerTotals(ctx, groupID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, balance := range balances {
+		filter := bson.M{"_id": balance.ID, "version": balance.Version}
+
+		total, ok := totals[balance.UserID]
+		if !ok {
+			result, err := coll.DeleteOne(ctx, filter)
+			if err != nil {
+				return err
+			}
+			if result.DeletedCount == 0 {
+				return errBalancesChanged
+			}
+			continue
+		}
+		delete(totals, balance.UserID)
+
+		if balance.TotalPaid == total.TotalPaid && balance.TotalOwed == total.TotalOwed && balance.Balance == total.TotalPaid-total.TotalOwed {
+			continue
+		}
+
+		result, err := coll.UpdateOne(ctx, filter, bson.M{
+			"$set": bson.M{
+				"total_paid":   total.TotalPaid,
+				"total_owed":   total.TotalOwed,
+				"balance":      total.TotalPaid - total.TotalOwed,
+				"last_updated": now,
+				"updated_at":   now,
+			},
+			"$inc": bson.M{"version": 1},
+		})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return errBalancesChanged
+		}
+	}
+
+	// Members with ledger entries but no balance document
+	for _, total := range totals {
+		balance := db.NewGroupBalance(groupID, total.UserID, total.UserName, total.Currency)
+		balance.TotalPaid = total.TotalPaid
+		balance.TotalOwed = total.TotalOwed
+		balance.Balance = total.TotalPaid - total.TotalOwed
+		balance.LastTransactionID = total.LastTransactionID
+
+		if err := coll.CreateWithCtx(ctx, balance); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return errBalancesChanged
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// EnsureLedgerIndexes creates the indexes the ledger and its projection rely on. The
+// unique (group, user) index keeps concurrent first writes for a member from creating two
+// balance documents. Duplicates must be merged first, see MigrateLedgerIndexes.
+func EnsureLedgerIndexes() error {
+	_, err := mgm.Coll(&db.GroupBalance{}).Indexes().CreateOne(mgm.Ctx(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "group_id", Value: 1}, {Key: "user_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = mgm.Coll(&db.LedgerEntry{}).Indexes().CreateMany(mgm.Ctx(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "group_id", Value: 1}, {Key: "user_id", Value: 1}}},
+		{Keys: bson.D{{Key: "transaction_id", Value: 1}}},
+	})
+	return err
+}
@@ -0,0 +1,803 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
+	"github.com/kamva/mgm/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// legacyAmount mirrors an embedded payer/split/participant whose amount may still be a
+// float64 written before minor units
+type legacyAmount struct {
+	Amount bson.RawValue `bson:"amount"`
+}
+
+// legacyTransactionAmounts holds the amount fields of a transaction written before minor
+// units. They are kept raw, as some may already have been converted.
+type legacyTransactionAmounts struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Currency     string             `bson:"currency"`
+	Amount       bson.RawValue      `bson:"amount"`
+	Payers       []legacyAmount     `bson:"payers"`
+	Splits       []legacyAmount     `bson:"splits"`
+	Participants []legacyAmount     `bson:"participants"`
+}
+
+// legacyBalanceAmounts holds the amount fields of a group balance written before minor units
+type legacyBalanceAmounts struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	Currency  string             `bson:"currency"`
+	Balance   bson.RawValue      `bson:"balance"`
+	TotalPaid bson.RawValue      `bson:"total_paid"`
+	TotalOwed bson.RawValue      `bson:"total_owed"`
+}
+
+// legacyMinorUnits reads a stored amount in minor units, converting it if it is still a
+// decimal double, and reports whether it was converted. Amounts already in minor units
+// are returned as they are, so they are never scaled twice.
+func legacyMinorUnits(value bson.RawValue, currency string) (db.Money, bool) {
+	if amount, ok := value.DoubleOK(); ok {
+		return db.MoneyFromFloat(amount, currency), true
+	}
+	if amount, ok := value.Int64OK(); ok {
+		return db.Money(amount), false
+	}
+	if amount, ok := value.Int32OK(); ok {
+		return db.Money(amount), false
+	}
+	return 0, false
+}
+
+// MigrateMoneyToMinorUnits converts transactions and group balances that still store
+// amounts as decimal doubles into integer minor units of their currency.
+// Documents are selected by the BSON type of their amount fields, and only fields that
+// are still doubles are converted, so a partly converted document is finished without
+// scaling anything twice and running the migration again is a no-op.
+func MigrateMoneyToMinorUnits() error {
+	transactions, err := migrateTransactionAmounts()
+	if err != nil {
+		return fmt.Errorf("migrating transaction amounts: %w", err)
+	}
+
+	balances, err := migrateBalanceAmounts()
+	if err != nil {
+		return fmt.Errorf("migrating group balance amounts: %w", err)
+	}
+
+	if transactions > 0 || balances > 0 {
+		log.Printf("Converted %d transactions and %d group balances to minor units\n", transactions, balances)
+	}
+
+	return nil
+}
+
+func migrateTransactionAmounts() (int, error) {
+	coll := mgm.Coll(&db.Transaction{})
+	isDouble := bson.M{"$type": "double"}
+
+	cursor, err := coll.Find(mgm.Ctx(), bson.M{
+		"$or": []bson.M{
+			{"amount": isDouble},
+			{"payers.amount": isDouble},
+			{"splits.amount": isDouble},
+			{"participants.amount": isDouble},
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(mgm.Ctx())
+
+	migrated := 0
+	for cursor.Next(mgm.Ctx()) {
+		var legacy legacyTransactionAmounts
+		if err := cursor.Decode(&legacy); err != nil {
+			return migrated, err
+		}
+
+		updateDoc := bson.M{}
+		if amount, ok := legacyMinorUnits(legacy.Amount, legacy.Currency); ok {
+			updateDoc["amount"] = amount
+		}
+		for i, payer := range legacy.Payers {
+			if amount, ok := legacyMinorUnits(payer.Amount, legacy.Currency); ok {
+				updateDoc[fmt.Sprintf("payers.%d.amount", i)] = amount
+			}
+		}
+		for i, split := range legacy.Splits {
+			if amount, ok := legacyMinorUnits(split.Amount, legacy.Currency); ok {
+				updateDoc[fmt.Sprintf("splits.%d.amount", i)] = amount
+			}
+		}
+		for i, participant := range legacy.Participants {
+			if amount, ok := legacyMinorUnits(participant.Amount, legacy.Currency); ok {
+				updateDoc[fmt.Sprintf("participants.%d.amount", i)] = amount
+			}
+		}
+		if len(updateDoc) == 0 {
+			continue
+		}
+
+		if _, err := coll.UpdateOne(mgm.Ctx(), bson.M{"_id": legacy.ID}, bson.M{"$set": updateDoc}); err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+
+	return migrated, cursor.Err()
+}
+
+func migrateBalanceAmounts() (int, error) {
+	coll := mgm.Coll(&db.GroupBalance{})
+	isDouble := bson.M{"$type": "double"}
+
+	cursor, err := coll.Find(mgm.Ctx(), bson.M{
+		"$or": []bson.M{
+			{"balance": isDouble},
+			{"total_paid": isDouble},
+			{"total_owed": isDouble},
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(mgm.Ctx())
+
+	migrated := 0
+	for cursor.Next(mgm.Ctx()) {
+		var legacy legacyBalanceAmounts
+		if err := cursor.Decode(&legacy); err != nil {
+			return migrated, err
+		}
+
+		totalPaid, paidConverted := legacyMinorUnits(legacy.TotalPaid, legacy.Currency)
+		totalOwed, owedConverted := legacyMinorUnits(legacy.TotalOwed, legacy.Currency)
+		_, balanceConverted := legacyMinorUnits(legacy.Balance, legacy.Currency)
+
+		// Balance is recomputed from the converted totals so it stays exactly paid - owed
+		updateDoc := bson.M{"balance": totalPaid - totalOwed}
+		if paidConverted {
+			updateDoc["total_paid"] = totalPaid
+		}
+		if owedConverted {
+			updateDoc["total_owed"] = totalOwed
+		}
+		if !paidConverted && !owedConverted && !balanceConverted {
+			continue
+		}
+
+		_, err := coll.UpdateOne(mgm.Ctx(), bson.M{"_id": legacy.ID}, bson.M{"$set": updateDoc})
+		if err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+
+	return migrated, cursor.Err()
+}
+
+// legacyConversionAmounts holds the fields of a transaction written before multi-currency support
+type legacyConversionAmounts struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	GroupID      primitive.ObjectID `bson:"group_id"`
+	Currency     string             `bson:"currency"`
+	Amount       db.Money           `bson:"amount"`
+	Payers       []legacyMoney      `bson:"payers"`
+	Splits       []legacyMoney      `bson:"splits"`
+	Participants []legacyMoney      `bson:"participants"`
+}
+
+// legacyMoney mirrors an embedded payer/split/participant amount in minor units
+type legacyMoney struct {
+	Amount db.Money `bson:"amount"`
+}
+
+// MigrateGroupCurrencyAmounts fills in the group currency fields of transactions created
+// before multi-currency support. Those transactions were applied to balances one to one,
+// so their converted amounts are their original amounts at a rate of 1; this keeps
+// existing balances unchanged. Only transactions without a group currency are touched.
+func MigrateGroupCurrencyAmounts() error {
+	coll := mgm.Coll(&db.Transaction{})
+
+	cursor, err := coll.Find(mgm.Ctx(), bson.M{"group_currency": bson.M{"$exists": false}})
+	if err != nil {
+		return fmt.Errorf("finding transactions without group currency: %w", err)
+	}
+	defer cursor.Close(mgm.Ctx())
+
+	groupCurrencies := make(map[primitive.ObjectID]string)
+	migrated := 0
+	for cursor.Next(mgm.Ctx()) {
+		var legacy legacyConversionAmounts
+		if err := cursor.Decode(&legacy); err != nil {
+			return err
+		}
+
+		groupCurrency, ok := groupCurrencies[legacy.GroupID]
+		if !ok {
+			group := &db.Group{}
+			if err := mgm.Coll(group).FindByID(legacy.GroupID, group); err == nil {
+				groupCurrency = group.Currency
+			} else {
+				groupCurrency = legacy.Currency
+			}
+			groupCurrencies[legacy.GroupID] = groupCurrency
+		}
+
+		updateDoc := bson.M{
+			"group_currency":   groupCurrency,
+			"exchange_rate":    1.0,
+			"converted_amount": legacy.Amount,
+		}
+		for i, payer := range legacy.Payers {
+			updateDoc[fmt.Sprintf("payers.%d.converted_amount", i)] = payer.Amount
+		}
+		for i, split := range legacy.Splits {
+			updateDoc[fmt.Sprintf("splits.%d.converted_amount", i)] = split.Amount
+		}
+		for i, participant := range legacy.Participants {
+			updateDoc[fmt.Sprintf("participants.%d.converted_amount", i)] = participant.Amount
+		}
+
+		if _, err := coll.UpdateOne(mgm.Ctx(), bson.M{"_id": legacy.ID}, bson.M{"$set": updateDoc}); err != nil {
+			return err
+		}
+		migrated++
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	if migrated > 0 {
+		log.Printf("Filled in group currency amounts for %d transactions\n", migrated)
+	}
+
+	return nil
+}
+
+// MigrateBalancesToLedger seeds the ledger for groups whose balances predate it. Each
+// existing balance becomes one opening entry, so the ledger adds up to exactly what the
+// balances say today and nothing changes for the members. A group's opening entries are
+// written together in one transaction, so a group either has all of them or none, and
+// groups that already have ledger entries are skipped; running it again is a no-op.
+// Duplicate balances of a member are merged first, so each member gets one entry.
+func MigrateBalancesToLedger() error {
+	if err := mergeDuplicateBalances(); err != nil {
+		return err
+	}
+
+	ledgerGroups, err := mgm.Coll(&db.LedgerEntry{}).Distinct(mgm.Ctx(), "group_id", bson.M{})
+	if err != nil {
+		return fmt.Errorf("finding groups with ledger entries: %w", err)
+	}
+	if ledgerGroups == nil {
+		ledgerGroups = []interface{}{}
+	}
+
+	var balances []*db.GroupBalance
+	if err := mgm.Coll(&db.GroupBalance{}).SimpleFind(&balances, bson.M{
+		"group_id": bson.M{"$nin": ledgerGroups},
+	}); err != nil {
+		return fmt.Errorf("finding balances without ledger entries: %w", err)
+	}
+
+	var groupIDs []primitive.ObjectID
+	groupBalances := make(map[primitive.ObjectID][]*db.GroupBalance)
+	for _, balance := range balances {
+		if _, ok := groupBalances[balance.GroupID]; !ok {
+			groupIDs = append(groupIDs, balance.GroupID)
+		}
+		groupBalances[balance.GroupID] = append(groupBalances[balance.GroupID], balance)
+	}
+
+	for _, groupID := range groupIDs {
+		err := runInTransaction(func(sc mongo.SessionContext) error {
+			for _, balance := range groupBalances[groupID] {
+				entry := db.NewLedgerEntry(balance.GroupID, balance.UserID, balance.UserName, primitive.NilObjectID, db.LedgerOpeningBalance, balance.TotalPaid, balance.TotalOwed, balance.Currency)
+				if err := mgm.Coll(entry).CreateWithCtx(sc, entry); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("creating opening ledger entries for group %s: %w", groupID.Hex(), err)
+		}
+	}
+
+	if len(balances) > 0 {
+		log.Printf("Seeded the ledger with %d opening balances\n", len(balances))
+	}
+
+	return nil
+}
+
+// MigrateLedgerIndexes merges duplicate balances of a member and then creates the ledger
+// indexes, whose unique (group, user) index they would break
+func MigrateLedgerIndexes() error {
+	if err := mergeDuplicateBalances(); err != nil {
+		return err
+	}
+	return EnsureLedgerIndexes()
+}
+
+// mergeDuplicateBalances folds every member's duplicate group balances into one. Balances
+// used to be upserted without a unique index, so two first writes for a member could
+// each create a balance starting from zero, and later updates only ever went to one of
+// them; the member's real totals are the sum of all of them. The most recently updated
+// balance is kept, with the summed totals, and the rest are deleted in the same
+// transaction.
+func mergeDuplicateBalances() error {
+	cursor, err := mgm.Coll(&db.GroupBalance{}).Aggregate(mgm.Ctx(), mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"group_id": "$group_id", "user_id": "$user_id"},
+			"ids":   bson.M{"$push": "$_id"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	})
+	if err != nil {
+		return fmt.Errorf("finding duplicate balances: %w", err)
+	}
+
+	var duplicates []struct {
+		IDs []primitive.ObjectID `bson:"ids"`
+	}
+	if err := cursor.All(mgm.Ctx(), &duplicates); err != nil {
+		return fmt.Errorf("finding duplicate balances: %w", err)
+	}
+
+	for _, duplicate := range duplicates {
+		var balances []*db.GroupBalance
+		err := mgm.Coll(&db.GroupBalance{}).SimpleFind(&balances, bson.M{"_id": bson.M{"$in": duplicate.IDs}},
+			options.Find().SetSort(bson.D{{Key: "last_updated", Value: -1}, {Key: "_id", Value: -1}}))
+		if err != nil {
+			return fmt.Errorf("finding duplicate balances: %w", err)
+		}
+		if len(balances) < 2 {
+			continue
+		}
+
+		kept := balances[0]
+		var totalPaid, totalOwed db.Money
+		var version int64
+		var removed []primitive.ObjectID
+		for _, balance := range balances {
+			totalPaid += balance.TotalPaid
+			totalOwed += balance.TotalOwed
+			if balance.Version > version {
+				version = balance.Version
+			}
+			if balance.ID != kept.ID {
+				removed = append(removed, balance.ID)
+			}
+		}
+
+		err = runInTransaction(func(sc mongo.SessionContext) error {
+			_, err := mgm.Coll(kept).UpdateOne(sc, bson.M{"_id": kept.ID}, bson.M{"$set": bson.M{
+				"total_paid": totalPaid,
+				"total_owed": totalOwed,
+				"balance":    totalPaid - totalOwed,
+				"version":    version,
+			}})
+			if err != nil {
+				return err
+			}
+			_, err = mgm.Coll(kept).DeleteMany(sc, bson.M{"_id": bson.M{"$in": removed}})
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("merging the balances of user %s in group %s: %w", kept.UserID.Hex(), kept.GroupID.Hex(), err)
+		}
+	}
+
+	if len(duplicates) > 0 {
+		log.Printf("Merged the duplicate balances of %d members\n", len(duplicates))
+	}
+
+	return nil
+}
+
+// MigrateSettlementStatuses gives settlements from before the confirmation workflow a
+// status. They were applied to balances when they were created, so they are confirmed;
+// anything else would take them back out of the balances. Only settlements without a
+// status are touched.
+func MigrateSettlementStatuses() error {
+	result, err := mgm.Coll(&db.Transaction{}).UpdateMany(mgm.Ctx(), bson.M{
+		"type":   db.TransactionTypeSettlement,
+		"status": bson.M{"$exists": false},
+	}, bson.M{
+		"$set": bson.M{"status": db.SettlementConfirmed},
+	})
+	if err != nil {
+		return fmt.Errorf("setting settlement statuses: %w", err)
+	}
+
+	if result.ModifiedCount > 0 {
+		log.Printf("Marked %d existing settlements as confirmed\n", result.ModifiedCount)
+	}
+
+	return nil
+}
+
+// MigrateParticipantsToPayersAndSplits gives transactions from before payers and splits
+// the payers and splits balances are now worked out from. Their participants only hold
+// net amounts, so for expenses the payers and splits are rebuilt from those nets and the
+// total; a settlement's payer and payee become its payer and split; an adjustment's
+// credits and debits become its payers and splits. Every member keeps the same net
+// effect on their balance. Only transactions without payers or splits are touched.
+func MigrateParticipantsToPayersAndSplits() error {
+	coll := mgm.Coll(&db.Transaction{})
+
+	cursor, err := coll.Find(mgm.Ctx(), bson.M{
+		"type":           bson.M{"$in": []db.TransactionType{db.TransactionTypeExpense, db.TransactionTypeSettlement, db.TransactionTypeAdjustment}},
+		"payers.0":       bson.M{"$exists": false},
+		"splits.0":       bson.M{"$exists": false},
+		"participants.0": bson.M{"$exists": true},
+	})
+	if err != nil {
+		return fmt.Errorf("finding transactions without payers and splits: %w", err)
+	}
+	defer cursor.Close(mgm.Ctx())
+
+	migrated, skipped := 0, 0
+	for cursor.Next(mgm.Ctx()) {
+		transaction := &db.Transaction{}
+		if err := cursor.Decode(transaction); err != nil {
+			return err
+		}
+
+		if !legacyPayersAndSplits(transaction) {
+			log.Printf("Cannot work out payers and splits of transaction %s, leaving it as it is\n", transaction.ID.Hex())
+			skipped++
+			continue
+		}
+		transaction.ComputeParticipants()
+
+		updateDoc := bson.M{
+			"payers":       transaction.Payers,
+			"splits":       transaction.Splits,
+			"participants": transaction.Participants,
+		}
+		if transaction.Type == db.TransactionTypeExpense && transaction.SplitType == "" {
+			updateDoc["split_type"] = db.SplitTypeExact
+		}
+
+		if _, err := coll.UpdateOne(mgm.Ctx(), bson.M{"_id": transaction.ID}, bson.M{"$set": updateDoc}); err != nil {
+			return err
+		}
+		migrated++
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	if migrated > 0 || skipped > 0 {
+		log.Printf("Gave %d transactions payers and splits, %d could not be worked out\n", migrated, skipped)
+	}
+
+	return nil
+}
+
+// legacyPayersAndSplits sets the payers and splits of a transaction from its participants
+func legacyPayersAndSplits(transaction *db.Transaction) bool {
+	participants := transaction.Participants
+
+	if transaction.Type != db.TransactionTypeExpense {
+		// Settlement payers and adjustment credits are positive, payees and debits negative
+		for _, participant := range participants {
+			if participant.Amount > 0 {
+				transaction.Payers = append(transaction.Payers, db.TransactionPayer{
+					UserID:          participant.UserID,
+					UserName:        participant.UserName,
+					Amount:          participant.Amount,
+					ConvertedAmount: participant.ConvertedAmount.Abs(),
+				})
+			} else if participant.Amount < 0 {
+				transaction.Splits = append(transaction.Splits, db.TransactionSplit{
+					UserID:          participant.UserID,
+					UserName:        participant.UserName,
+					Amount:          -participant.Amount,
+					ConvertedAmount: participant.ConvertedAmount.Abs(),
+				})
+			}
+		}
+		if transaction.Type == db.TransactionTypeSettlement {
+			return len(transaction.Payers) == 1 && len(transaction.Splits) == 1
+		}
+		return len(transaction.Payers) > 0
+	}
+
+	shareTypes := make([]string, len(participants))
+	nets := make([]db.Money, len(participants))
+	convertedNets := make([]db.Money, len(participants))
+	for i, participant := range participants {
+		shareTypes[i] = participant.ShareType
+		nets[i] = participant.Amount
+		convertedNets[i] = participant.ConvertedAmount
+	}
+
+	paid, owed, ok := legacyExpenseShares(shareTypes, nets, transaction.Amount)
+	if !ok {
+		return false
+	}
+	convertedPaid, convertedOwed, ok := legacyExpenseShares(shareTypes, convertedNets, transaction.ConvertedAmount)
+	if !ok {
+		return false
+	}
+
+	for i, participant := range participants {
+		if paid[i] > 0 || convertedPaid[i] > 0 {
+			transaction.Payers = append(transaction.Payers, db.TransactionPayer{
+				UserID:          participant.UserID,
+				UserName:        participant.UserName,
+				Amount:          paid[i],
+				ConvertedAmount: convertedPaid[i],
+			})
+		}
+		if owed[i] > 0 || convertedOwed[i] > 0 {
+			transaction.Splits = append(transaction.Splits, db.TransactionSplit{
+				UserID:          participant.UserID,
+				UserName:        participant.UserName,
+				Amount:          owed[i],
+				ConvertedAmount: convertedOwed[i],
+			})
+		}
+	}
+	return len(transaction.Payers) > 0 && len(transaction.Splits) > 0
+}
+
+// legacyExpenseShares works out what each participant of a legacy expense paid and owed
+// from their net amounts (paid less owed) and the expense total. Payers paid their net
+// amount and splits owe theirs. Whoever both paid and had a share owes an equal part of
+// what the splits do not, and paid that plus their net amount. It fails when that would
+// leave anyone paying or owing less than nothing.
+func legacyExpenseShares(shareTypes []string, nets []db.Money, total db.Money) (paid, owed []db.Money, ok bool) {
+	paid = make([]db.Money, len(nets))
+	owed = make([]db.Money, len(nets))
+
+	var both []int
+	remaining := total
+	for i, net := range nets {
+		shareType := shareTypes[i]
+		if shareType != "payer" && shareType != "split" && shareType != "both" {
+			// Unmarked participants are taken by the sign of their net amount
+			shareType = "split"
+			if net > 0 {
+				shareType = "payer"
+			}
+		}
+
+		switch shareType {
+		case "payer":
+			paid[i] = net
+		case "split":
+			owed[i] = -net
+			remaining -= owed[i]
+		case "both":
+			both = append(both, i)
+		}
+	}
+
+	if len(both) > 0 {
+		weights := make([]int64, len(both))
+		for j := range weights {
+			weights[j] = 1
+		}
+		for j, share := range remaining.Allocate(weights) {
+			owed[both[j]] = share
+			paid[both[j]] = share + nets[both[j]]
+		}
+	}
+
+	for i := range nets {
+		if paid[i] < 0 || owed[i] < 0 {
+			return nil, nil, false
+		}
+	}
+	return paid, owed, true
+}
+
+// MigrateCategoryIDs files expenses and refunds from before the category catalog under a
+// category. Names matching a built-in category get it; any other name becomes a category
+// of the group's own, so no grouping is lost. Expenses without a name go to General.
+// Recurring expenses get the ID of their category in the same way.
+func MigrateCategoryIDs() error {
+	type legacyCategory struct {
+		ID struct {
+			GroupID  primitive.ObjectID `bson:"group_id"`
+			Category string             `bson:"category"`
+		} `bson:"_id"`
+		CreatedBy primitive.ObjectID `bson:"created_by"`
+	}
+
+	coll := mgm.Coll(&db.Transaction{})
+	filter := bson.M{
+		"type":        bson.M{"$in": []db.TransactionType{db.TransactionTypeExpense, db.TransactionTypeRefund}},
+		"category_id": bson.M{"$exists": false},
+	}
+
+	cursor, err := coll.Aggregate(mgm.Ctx(), []bson.M{
+		{"$match": filter},
+		{"$group": bson.M{
+			"_id":        bson.M{"group_id": "$group_id", "category": "$category"},
+			"created_by": bson.M{"$first": "$created_by"},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("finding legacy categories: %w", err)
+	}
+	var legacy []legacyCategory
+	if err := cursor.All(mgm.Ctx(), &legacy); err != nil {
+		return err
+	}
+
+	migrated := int64(0)
+	for _, entry := range legacy {
+		info, err := legacyCategoryInfo(entry.ID.GroupID, entry.ID.Category, entry.CreatedBy)
+		if err != nil {
+			return fmt.Errorf("creating category %q: %w", entry.ID.Category, err)
+		}
+
+		match := bson.M{"group_id": entry.ID.GroupID}
+		for key, value := range filter {
+			match[key] = value
+		}
+		if entry.ID.Category == "" {
+			match["category"] = bson.M{"$in": []interface{}{"", nil}}
+		} else {
+			match["category"] = entry.ID.Category
+		}
+
+		result, err := coll.UpdateMany(mgm.Ctx(), match, bson.M{
+			"$set": bson.M{"category_id": info.ID, "category": info.Name},
+		})
+		if err != nil {
+			return fmt.Errorf("setting category IDs: %w", err)
+		}
+		migrated += result.ModifiedCount
+	}
+
+	var recurring []*db.RecurringExpense
+	if err := mgm.Coll(&db.RecurringExpense{}).SimpleFind(&recurring, bson.M{}); err != nil {
+		return err
+	}
+	for _, expense := range recurring {
+		if info, err := resolveCategory(expense.GroupID, expense.Category); err == nil && info.ID == expense.Category {
+			continue
+		}
+		info, err := legacyCategoryInfo(expense.GroupID, expense.Category, expense.CreatedBy)
+		if err != nil {
+			return fmt.Errorf("creating category %q: %w", expense.Category, err)
+		}
+		_, err = mgm.Coll(expense).UpdateOne(mgm.Ctx(), bson.M{"_id": expense.ID}, bson.M{
+			"$set": bson.M{"category": info.ID},
+		})
+		if err != nil {
+			return fmt.Errorf("setting recurring expense categories: %w", err)
+		}
+		migrated++
+	}
+
+	if migrated > 0 {
+		log.Printf("Filed %d existing expenses and recurring expenses under categories\n", migrated)
+	}
+
+	return nil
+}
+
+// legacyCategoryInfo finds the category a name from before the catalog belongs to,
+// creating a category of the group's own when it is not a built-in one
+func legacyCategoryInfo(groupID primitive.ObjectID, name string, createdBy primitive.ObjectID) (db.CategoryInfo, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = db.DefaultCategoryID
+	}
+	if info, err := resolveCategory(groupID, name); err != errUnknownCategory {
+		return info, err
+	}
+
+	category := db.NewCategory(groupID, name, db.DefaultCategoryIcon, createdBy)
+	err := mgm.Coll(category).Create(category)
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return db.CategoryInfo{}, err
+	}
+	if err != nil {
+		// Created by an earlier run that stopped part way
+		return resolveCategory(groupID, name)
+	}
+	return category.Info(), nil
+}
+
+// MigrateRotationCategoryIDs keys rotations by category ID instead of by lowercased
+// name, so renaming a category keeps its rotation. Names become categories the way
+// expense categories did; a rotation whose category already has one is left without an
+// ID and logged, as a group can only have one rotation per category. The unique index
+// moves from the name to the ID.
+func MigrateRotationCategoryIDs() error {
+	var rotations []*db.Rotation
+	if err := mgm.Coll(&db.Rotation{}).SimpleFind(&rotations, bson.M{"category_id": bson.M{"$exists": false}}); err != nil {
+		return err
+	}
+
+	for _, rotation := range rotations {
+		info, err := legacyCategoryInfo(rotation.GroupID, rotation.Category, rotation.CreatedBy)
+		if err != nil {
+			return fmt.Errorf("creating category %q: %w", rotation.Category, err)
+		}
+
+		taken, err := mgm.Coll(rotation).CountDocuments(mgm.Ctx(), bson.M{"group_id": rotation.GroupID, "category_id": info.ID})
+		if err != nil {
+			return err
+		}
+		if taken > 0 {
+			log.Printf("Rotation %s is for the same category as another rotation of its group, left without a category\n", rotation.ID.Hex())
+			continue
+		}
+
+		_, err = mgm.Coll(rotation).UpdateOne(mgm.Ctx(), bson.M{"_id": rotation.ID}, bson.M{
+			"$set": bson.M{"category_id": info.ID, "category": info.Name},
+		})
+		if err != nil {
+			return fmt.Errorf("setting rotation categories: %w", err)
+		}
+	}
+
+	// Names no longer have to be unique, a renamed category can free its old one
+	_, err := mgm.Coll(&db.Rotation{}).Indexes().DropOne(mgm.Ctx(), "group_id_1_category_1")
+	var commandErr mongo.CommandError
+	if err != nil && !(errors.As(err, &commandErr) && commandErr.Name == "IndexNotFound") {
+		return err
+	}
+
+	_, err = mgm.Coll(&db.Rotation{}).Indexes().CreateOne(mgm.Ctx(), mongo.IndexModel{
+		Keys: bson.D{{Key: "group_id", Value: 1}, {Key: "category_id", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"category_id": bson.M{"$exists": true}}),
+	})
+	return err
+}
+
+// MigrateFriendshipIndexes removes duplicate friendships and then creates the friendship
+// indexes, whose unique (requester, addressee) index they would break. Rejected requests
+// used to be sent again as new documents, so a pair could have several. The newest
+// friendship of each pair, in either direction, is the one kept.
+func MigrateFriendshipIndexes() error {
+	var friendships []*db.Friendship
+	err := mgm.Coll(&db.Friendship{}).SimpleFind(&friendships, bson.M{},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}))
+	if err != nil {
+		return fmt.Errorf("finding friendships: %w", err)
+	}
+
+	kept := make(map[[2]primitive.ObjectID]bool)
+	var duplicates []primitive.ObjectID
+	for _, friendship := range friendships {
+		pair := [2]primitive.ObjectID{friendship.RequesterID, friendship.AddresseeID}
+		if pair[0].Hex() > pair[1].Hex() {
+			pair[0], pair[1] = pair[1], pair[0]
+		}
+		if kept[pair] {
+			duplicates = append(duplicates, friendship.ID)
+			continue
+		}
+		kept[pair] = true
+	}
+
+	if len(duplicates) > 0 {
+		if _, err := mgm.Coll(&db.Friendship{}).DeleteMany(mgm.Ctx(), bson.M{"_id": bson.M{"$in": duplicates}}); err != nil {
+			return fmt.Errorf("removing duplicate friendships: %w", err)
+		}
+		log.Printf("Removed %d duplicate friendships\n", len(duplicates))
+	}
+
+	return EnsureFriendshipIndexes()
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
+	"github.com/kamva/mgm/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migration is one versioned change to the database: creating indexes or rewriting
+// data. Each runs once, in version order. A server can stop between running a migration
+// and recording it, so running one again must do no harm.
+type Migration struct {
+	Version int
+	Name    string
+	Run     func() error
+}
+
+// MigrationState is a migration and when it was applied, if it has been
+type MigrationState struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// migrations lists every migration. Add new ones at the end with the next version, and
+// never renumber, reorder or remove one that has been released.
+var migrations = []Migration{
+	{Version: 1, Name: "money_to_minor_units", Run: MigrateMoneyToMinorUnits},
+	{Version: 2, Name: "group_currency_amounts", Run: MigrateGroupCurrencyAmounts},
+	{Version: 3, Name: "balances_to_ledger", Run: MigrateBalancesToLedger},
+	{Version: 4, Name: "settlement_statuses", Run: MigrateSettlementStatuses},
+	{Version: 5, Name: "ledger_indexes", Run: MigrateLedgerIndexes},
+	{Version: 6, Name: "idempotency_indexes", Run: EnsureIdempotencyIndexes},
+	{Version: 7, Name: "revision_indexes", Run: EnsureRevisionIndexes},
+	{Version: 8, Name: "recurring_expense_indexes", Run: EnsureRecurringExpenseIndexes},
+	{Version: 9, Name: "rotation_indexes", Run: EnsureRotationIndexes},
+	{Version: 10, Name: "transaction_query_indexes", Run: EnsureTransactionQueryIndexes},
+	{Version: 11, Name: "user_indexes", Run: EnsureUserIndexes},
+	{Version: 12, Name: "friendship_indexes", Run: MigrateFriendshipIndexes},
+	{Version: 13, Name: "group_indexes", Run: EnsureGroupIndexes},
+	{Version: 14, Name: "exchange_rate_indexes", Run: EnsureExchangeRateIndexes},
+	{Version: 15, Name: "participants_to_payers_and_splits", Run: MigrateParticipantsToPayersAndSplits},
+	{Version: 16, Name: "category_indexes", Run: EnsureCategoryIndexes},
+	{Version: 17, Name: "category_ids", Run: MigrateCategoryIDs},
+	{Version: 18, Name: "rotation_category_ids", Run: MigrateRotationCategoryIDs},
+}
+
+// RunMigrations applies every migration that has not been applied yet, in order, and
+// records each one as it completes. It stops at the first that fails, so later
+// migrations never run on top of a failed one.
+func RunMigrations() error {
+	// Two servers starting together must not both record a migration
+	_, err := mgm.Coll(&db.SchemaMigration{}).Indexes().CreateOne(mgm.Ctx(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "version", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	applied, err := appliedMigrations()
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		log.Printf("Applying migration %d %s\n", migration.Version, migration.Name)
+		start := time.Now()
+		if err := migration.Run(); err != nil {
+			return fmt.Errorf("migration %d %s failed: %w", migration.Version, migration.Name, err)
+		}
+
+		record := db.NewSchemaMigration(migration.Version, migration.Name, time.Since(start))
+		if err := mgm.Coll(record).Create(record); err != nil && !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetMigrationStates lists every migration in order with when it was applied
+func GetMigrationStates() ([]MigrationState, error) {
+	applied, err := appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationState, len(migrations))
+	for i, migration := range migrations {
+		states[i].Migration = migration
+		if record, ok := applied[migration.Version]; ok {
+			states[i].AppliedAt = &record.AppliedAt
+		}
+	}
+	return states, nil
+}
+
+// appliedMigrations returns the recorded migrations by version
+func appliedMigrations() (map[int]*db.SchemaMigration, error) {
+	var records []*db.SchemaMigration
+	if err := mgm.Coll(&db.SchemaMigration{}).SimpleFind(&records, bson.M{}); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]*db.SchemaMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
@@ -162,6 +162,31 @@ func SendTestPushNotification(userID primitive.ObjectID, subscriptionID string)
 	return Notification.SendJSONNotification(subscription, notification)
 }
 
+// NotifyUser sends a web push notification to every subscription of a user
+func NotifyUser(userID primitive.ObjectID, title, body string, data map[string]interface{}) {
+	if Notification == nil {
+		return
+	}
+
+	subs, err := GetPushSubscriptionsByUserID(userID)
+	if err != nil {
+		log.Printf("Error getting push subscriptions for user %s: %v\n", userID.Hex(), err)
+		return
+	}
+
+	notification := map[string]interface{}{
+		"title": title,
+		"body":  body,
+		"data":  data,
+	}
+
+	for _, sub := range subs {
+		if err := Notification.SendJSONNotification(sub, notification); err != nil {
+			log.Printf("Error sending web push notification to user %s: %v\n", userID.Hex(), err)
+		}
+	}
+}
+
 func (s *NotificationService) SendJSONNotification(subscription *db.PushSubscription, data interface{}) error {
 	message, err := json.Marshal(data)
 	if err != nil {
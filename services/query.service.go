@@ -0,0 +1,196 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models"
+	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
+	"github.com/kamva/mgm/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// defaultTransactionPageSize is how many transactions a page holds when no limit is given
+const defaultTransactionPageSize = 20
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// transactionListSort orders transaction lists newest first. The ID breaks ties between
+// transactions with the same date, so a cursor always points at one place in the list.
+var transactionListSort = bson.D{{Key: "date", Value: -1}, {Key: "_id", Value: -1}}
+
+// findTransactions returns a page of the transactions matching filter and the query's
+// filters. Days in the query are read in loc.
+func findTransactions(filter bson.M, query models.TransactionQuery, loc *time.Location) (*models.TransactionPage, error) {
+	if query.Limit <= 0 {
+		query.Limit = defaultTransactionPageSize
+	}
+
+	conditions, err := transactionQueryConditions(query, loc)
+	if err != nil {
+		return nil, err
+	}
+	conditions = append([]bson.M{filter}, conditions...)
+
+	findOptions := options.Find().
+		SetSort(transactionListSort).
+		SetLimit(int64(query.Limit + 1)) // +1 to check if there are more
+
+	if query.Cursor != "" {
+		date, id, err := decodeTransactionCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		// Everything after the last transaction of the previous page
+		conditions = append(conditions, bson.M{"$or": []bson.M{
+			{"date": bson.M{"$lt": date}},
+			{"date": date, "_id": bson.M{"$lt": id}},
+		}})
+	} else if query.Page > 0 {
+		findOptions.SetSkip(int64(query.Page * query.Limit))
+	}
+
+	transactions := []*db.Transaction{}
+	err = mgm.Coll(&db.Transaction{}).SimpleFind(&transactions, bson.M{"$and": conditions}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.TransactionPage{Transactions: transactions}
+	if len(transactions) > query.Limit {
+		page.Transactions = transactions[:query.Limit]
+		page.HasMore = true
+		page.NextCursor = encodeTransactionCursor(page.Transactions[query.Limit-1])
+	}
+	return page, nil
+}
+
+// transactionQueryConditions turns the filters of a query into Mongo conditions
+func transactionQueryConditions(query models.TransactionQuery, loc *time.Location) ([]bson.M, error) {
+	var conditions []bson.M
+
+	if query.Type != "" {
+		conditions = append(conditions, bson.M{"type": query.Type})
+	}
+
+	dateRange := bson.M{}
+	if query.From != "" {
+		from, _, err := parseQueryDate(query.From, loc)
+		if err != nil {
+			return nil, errors.New("from must be a day (YYYY-MM-DD) or an RFC 3339 timestamp")
+		}
+		dateRange["$gte"] = from
+	}
+	if query.To != "" {
+		to, day, err := parseQueryDate(query.To, loc)
+		if err != nil {
+			return nil, errors.New("to must be a day (YYYY-MM-DD) or an RFC 3339 timestamp")
+		}
+		if day {
+			// A day runs up to the start of the next one
+			dateRange["$lt"] = to.AddDate(0, 0, 1)
+		} else {
+			dateRange["$lte"] = to
+		}
+	}
+	if len(dateRange) > 0 {
+		conditions = append(conditions, bson.M{"date": dateRange})
+	}
+
+	if query.Category != "" {
+		conditions = append(conditions, bson.M{"category_id": query.Category})
+	}
+
+	// Payers of expenses and settlements alike are marked in the participants
+	if !query.PayerID.IsZero() {
+		conditions = append(conditions, bson.M{"participants": bson.M{"$elemMatch": bson.M{
+			"user_id":    query.PayerID,
+			"share_type": bson.M{"$in": []string{"payer", "both"}},
+		}}})
+	}
+	if !query.ParticipantID.IsZero() {
+		conditions = append(conditions, bson.M{"participants.user_id": query.ParticipantID})
+	}
+
+	amountRange := bson.M{}
+	if query.MinAmount != nil {
+		amountRange["$gte"] = *query.MinAmount
+	}
+	if query.MaxAmount != nil {
+		amountRange["$lte"] = *query.MaxAmount
+	}
+	if len(amountRange) > 0 {
+		conditions = append(conditions, bson.M{"converted_amount": amountRange})
+	}
+
+	if query.Completed != nil {
+		conditions = append(conditions, bson.M{"is_completed": *query.Completed})
+	}
+
+	if query.Search != "" {
+		conditions = append(conditions, bson.M{"$text": bson.M{"$search": query.Search}})
+	}
+
+	return conditions, nil
+}
+
+// parseQueryDate reads an RFC 3339 timestamp, or a day (YYYY-MM-DD) starting at midnight
+// in loc, and reports which it was
+func parseQueryDate(value string, loc *time.Location) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, loc)
+	return t, true, err
+}
+
+// encodeTransactionCursor points at a transaction in a list by its date and ID. Mongo
+// keeps dates to the millisecond, so that is all the cursor needs.
+func encodeTransactionCursor(transaction *db.Transaction) string {
+	value := fmt.Sprintf("%d:%s", transaction.Date.UnixMilli(), transaction.ID.Hex())
+	return base64.RawURLEncoding.EncodeToString([]byte(value))
+}
+
+func decodeTransactionCursor(cursor string) (time.Time, primitive.ObjectID, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, primitive.NilObjectID, errInvalidCursor
+	}
+
+	millis, idHex, found := strings.Cut(string(data), ":")
+	if !found {
+		return time.Time{}, primitive.NilObjectID, errInvalidCursor
+	}
+	ms, err := strconv.ParseInt(millis, 10, 64)
+	if err != nil {
+		return time.Time{}, primitive.NilObjectID, errInvalidCursor
+	}
+	id, err := primitive.ObjectIDFromHex(idHex)
+	if err != nil {
+		return time.Time{}, primitive.NilObjectID, errInvalidCursor
+	}
+
+	return time.UnixMilli(ms), id, nil
+}
+
+// EnsureTransactionQueryIndexes creates the indexes transaction lists are read through:
+// a group's or a member's transactions in list order, and the text index searches use
+func EnsureTransactionQueryIndexes() error {
+	_, err := mgm.Coll(&db.Transaction{}).Indexes().CreateMany(mgm.Ctx(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "group_id", Value: 1}, {Key: "date", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "participants.user_id", Value: 1}, {Key: "date", Value: -1}, {Key: "_id", Value: -1}}},
+		{
+			Keys: bson.D{{Key: "description", Value: "text"}, {Key: "notes", Value: "text"}},
+			// Descriptions are in any language, so words are matched as written
+			Options: options.Index().SetName("transaction_search").SetDefaultLanguage("none"),
+		},
+	})
+	return err
+}
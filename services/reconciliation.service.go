@@ -0,0 +1,252 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
+	"github.com/kamva/mgm/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// reconcileGracePeriod leaves groups with very recent activity alone, since a transaction
+// can be stored a moment before its ledger entries are
+const reconcileGracePeriod = 2 * time.Minute
+
+var errLedgerChanged = errors.New("the ledger changed while the group was being reconciled")
+
+// expectedBalance is what a member's totals add up to when a group's transactions are replayed
+type expectedBalance struct {
+	UserName string
+	Paid     db.Money
+	Owed     db.Money
+}
+
+// StartReconciliationJob runs the balance reconciliation in the background every
+// RECONCILE_INTERVAL_MINUTES. Discrepancies are only repaired when RECONCILE_AUTO_REPAIR
+// is on; otherwise they are reported for an admin to look at.
+func StartReconciliationJob() {
+	if Config.ReconcileIntervalMinutes <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(Config.ReconcileIntervalMinutes) * time.Minute)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			report, err := RunReconciliation(Config.ReconcileAutoRepair)
+			if err != nil {
+				log.Printf("Balance reconciliation failed: %s", err.Error())
+				continue
+			}
+			if len(report.Discrepancies) > 0 || len(report.Imbalances) > 0 {
+				log.Printf("Balance reconciliation found %d discrepancies and %d imbalanced groups, repaired %d groups\n",
+					len(report.Discrepancies), len(report.Imbalances), len(report.RepairedGroups))
+			}
+		}
+	}()
+}
+
+// RunReconciliation replays every group's transactions with the canonical balance rules
+// and compares the result with the group's ledger and stored balances. It also checks
+// that each group's balances add up to zero. With repair on, the ledger of a group that
+// is off gets correcting entries and its balances are rebuilt. The report is saved.
+func RunReconciliation(repair bool) (*db.ReconciliationReport, error) {
+	report := db.NewReconciliationReport(repair)
+
+	var groups []*db.Group
+	if err := mgm.Coll(&db.Group{}).SimpleFind(&groups, bson.M{}); err != nil {
+		return nil, err
+	}
+
+	for _, group := range groups {
+		if err := reconcileGroup(group, report); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("group %s: %s", group.ID.Hex(), err.Error()))
+		}
+	}
+
+	report.FinishedAt = time.Now()
+	if err := mgm.Coll(report).Create(report); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// LatestReconciliationReport returns the report of the most recent reconciliation run
+func LatestReconciliationReport() (*db.ReconciliationReport, error) {
+	report := &db.ReconciliationReport{}
+	err := mgm.Coll(report).FindOne(mgm.Ctx(), bson.M{},
+		options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}})).Decode(report)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("balances have not been reconciled yet")
+		}
+		return nil, err
+	}
+	return report, nil
+}
+
+func reconcileGroup(group *db.Group, report *db.ReconciliationReport) error {
+	before, err := latestLedgerEntry(mgm.Ctx(), group.ID)
+	if err != nil {
+		return err
+	}
+
+	var balances []*db.GroupBalance
+	if err := mgm.Coll(&db.GroupBalance{}).SimpleFind(&balances, bson.M{"group_id": group.ID}); err != nil {
+		return err
+	}
+
+	ledger, err := groupLedgerTotals(mgm.Ctx(), group.ID)
+	if err != nil {
+		return err
+	}
+
+	var transactions []*db.Transaction
+	if err := mgm.Coll(&db.Transaction{}).SimpleFind(&transactions, bson.M{"group_id": group.ID, "deleted_at": bson.M{"$exists": false}}); err != nil {
+		return err
+	}
+
+	// Skip the group if anything was written while it was being read, or very recently
+	after, err := latestLedgerEntry(mgm.Ctx(), group.ID)
+	if err != nil {
+		return err
+	}
+	busySince := time.Now().Add(-reconcileGracePeriod)
+	busy := after != nil && (before == nil || after.ID != before.ID || after.CreatedAt.After(busySince))
+	for _, transaction := range transactions {
+		if transaction.UpdatedAt.After(busySince) {
+			busy = true
+			break
+		}
+	}
+	if busy {
+		report.GroupsSkipped = append(report.GroupsSkipped, group.ID)
+		return nil
+	}
+	report.GroupsChecked++
+
+	expected := make(map[primitive.ObjectID]*expectedBalance)
+	for _, transaction := range transactions {
+		for _, effect := range balanceEffects(transaction) {
+			if expected[effect.UserID] == nil {
+				expected[effect.UserID] = &expectedBalance{UserName: effect.UserName}
+			}
+			expected[effect.UserID].Paid += effect.Paid
+			expected[effect.UserID].Owed += effect.Owed
+		}
+	}
+
+	stored := make(map[primitive.ObjectID]*db.GroupBalance, len(balances))
+	var sum db.Money
+	for _, balance := range balances {
+		stored[balance.UserID] = balance
+		sum += balance.Balance
+		if expected[balance.UserID] == nil {
+			expected[balance.UserID] = &expectedBalance{UserName: balance.UserName}
+		}
+	}
+	for userID, total := range ledger {
+		if expected[userID] == nil {
+			expected[userID] = &expectedBalance{UserName: total.UserName}
+		}
+	}
+
+	if sum != 0 {
+		report.Imbalances = append(report.Imbalances, db.GroupImbalance{GroupID: group.ID, Sum: sum})
+	}
+
+	var corrections []*db.LedgerEntry
+	discrepancies := 0
+	for userID, want := range expected {
+		discrepancy := db.BalanceDiscrepancy{
+			GroupID:         group.ID,
+			UserID:          userID,
+			UserName:        want.UserName,
+			ExpectedPaid:    want.Paid,
+			ExpectedOwed:    want.Owed,
+			ExpectedBalance: want.Paid - want.Owed,
+		}
+		if balance := stored[userID]; balance != nil {
+			discrepancy.StoredPaid = balance.TotalPaid
+			discrepancy.StoredOwed = balance.TotalOwed
+			discrepancy.StoredBalance = balance.Balance
+		}
+
+		var ledgerPaid, ledgerOwed db.Money
+		if total := ledger[userID]; total != nil {
+			ledgerPaid, ledgerOwed = total.TotalPaid, total.TotalOwed
+		}
+		discrepancy.LedgerBalance = ledgerPaid - ledgerOwed
+
+		if discrepancy.StoredPaid == want.Paid && discrepancy.StoredOwed == want.Owed &&
+			discrepancy.StoredBalance == discrepancy.ExpectedBalance &&
+			ledgerPaid == want.Paid && ledgerOwed == want.Owed {
+			continue
+		}
+		report.Discrepancies = append(report.Discrepancies, discrepancy)
+		discrepancies++
+
+		if ledgerPaid != want.Paid || ledgerOwed != want.Owed {
+			corrections = append(corrections, db.NewLedgerEntry(group.ID, userID, want.UserName, primitive.NilObjectID,
+				db.LedgerReconciliation, want.Paid-ledgerPaid, want.Owed-ledgerOwed, group.Currency))
+		}
+	}
+
+	if !report.AutoRepair || discrepancies == 0 {
+		return nil
+	}
+
+	// The ledger is brought in line with the transactions first, then the balances are
+	// rebuilt from it, all in one transaction. The corrections are only right for the
+	// ledger they were worked out from, so the group is left for the next run if
+	// anything was written to it since.
+	err = runInTransaction(func(sc mongo.SessionContext) error {
+		latest, err := latestLedgerEntry(sc, group.ID)
+		if err != nil {
+			return err
+		}
+		if (latest == nil) != (after == nil) || (latest != nil && latest.ID != after.ID) {
+			return errLedgerChanged
+		}
+
+		for _, entry := range corrections {
+			if err := mgm.Coll(entry).CreateWithCtx(sc, entry); err != nil {
+				return err
+			}
+		}
+		return rebuildGroupBalances(sc, group.ID)
+	})
+	if errors.Is(err, errLedgerChanged) {
+		report.GroupsSkipped = append(report.GroupsSkipped, group.ID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	report.RepairedGroups = append(report.RepairedGroups, group.ID)
+	return nil
+}
+
+// latestLedgerEntry returns the most recent ledger entry of a group, or nil if it has none
+func latestLedgerEntry(ctx context.Context, groupID primitive.ObjectID) (*db.LedgerEntry, error) {
+	entry := &db.LedgerEntry{}
+	err := mgm.Coll(entry).FindOne(ctx, bson.M{"group_id": groupID},
+		options.FindOne().SetSort(bson.D{{Key: "_id", Value: -1}})).Decode(entry)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return entry, nil
+}
@@ -0,0 +1,577 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models"
+	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
+	"github.com/kamva/mgm/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// recurringExpenseInterval is how often due occurrences are created
+	recurringExpenseInterval = 5 * time.Minute
+
+	// maxSkipLookahead bounds how many occurrences ahead a skip or upcoming list looks
+	maxSkipLookahead = 10000
+)
+
+// CreateRecurringExpense stores an expense template and the schedule it repeats on. Any
+// group member can create one; the template is checked like an expense before it is saved.
+func (ts *TransactionService) CreateRecurringExpense(userID primitive.ObjectID, req models.CreateRecurringExpenseRequest) (*db.RecurringExpense, error) {
+	groupID, err := primitive.ObjectIDFromHex(req.GroupID)
+	if err != nil {
+		return nil, errors.New("invalid group ID")
+	}
+
+	group, err := GetGroupById(groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	recurring := db.NewRecurringExpense(groupID, req.Description, req.Amount, req.Currency, db.SplitType(req.SplitType), req.Category, userID)
+	recurring.Notes = req.Notes
+	recurring.Payers = recurringPayers(req.Payers)
+	recurring.Splits = recurringSplits(req.Splits)
+
+	// Items are stored as the expense would store them
+	expense, err := ts.buildExpenseTransaction(group, userID, recurringExpenseRequest(recurring, req.Items))
+	if err != nil {
+		return nil, err
+	}
+	recurring.Items = expense.Items
+	recurring.Category = expense.CategoryID
+
+	if req.StartDate.Before(startOfDay(time.Now())) {
+		return nil, errors.New("start date cannot be in the past")
+	}
+	recurring.Frequency = db.RecurrenceFrequency(req.Frequency)
+	if req.Interval > 0 {
+		recurring.Interval = req.Interval
+	}
+	recurring.DayOfMonth = req.DayOfMonth
+	recurring.EndDate = req.EndDate
+	if err := startSchedule(recurring, req.StartDate); err != nil {
+		return nil, err
+	}
+	recurring.UpdatedBy = userID
+
+	if err := mgm.Coll(recurring).Create(recurring); err != nil {
+		return nil, err
+	}
+
+	return recurring, nil
+}
+
+// GetGroupRecurringExpenses returns a group's recurring expenses, soonest first
+func (ts *TransactionService) GetGroupRecurringExpenses(groupID, userID primitive.ObjectID) ([]*db.RecurringExpense, error) {
+	if _, err := GetGroupById(groupID, userID); err != nil {
+		return nil, err
+	}
+
+	recurring := []*db.RecurringExpense{}
+	err := mgm.Coll(&db.RecurringExpense{}).SimpleFind(&recurring, bson.M{"group_id": groupID},
+		options.Find().SetSort(bson.D{{Key: "next_occurrence", Value: 1}}))
+	return recurring, err
+}
+
+// GetRecurringExpense returns a recurring expense of a group the user belongs to
+func (ts *TransactionService) GetRecurringExpense(recurringID, userID primitive.ObjectID) (*db.RecurringExpense, error) {
+	recurring, _, err := findRecurringExpense(recurringID, userID)
+	return recurring, err
+}
+
+// UpdateRecurringExpense changes the template or schedule of a recurring expense.
+// Expenses already created from it are left as they are.
+func (ts *TransactionService) UpdateRecurringExpense(recurringID, userID primitive.ObjectID, req models.UpdateRecurringExpenseRequest) (*db.RecurringExpense, error) {
+	recurring, group, err := findRecurringExpense(recurringID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := canManageRecurringExpense(recurring, group, userID); err != nil {
+		return nil, err
+	}
+	lastUpdate := recurring.UpdatedAt
+
+	if req.Description != "" {
+		recurring.Description = req.Description
+	}
+	if req.Amount > 0 {
+		recurring.Amount = req.Amount
+	}
+	if req.SplitType != "" {
+		recurring.SplitType = db.SplitType(req.SplitType)
+	}
+	if req.Category != "" {
+		recurring.Category = req.Category
+	}
+	if req.Notes != "" {
+		recurring.Notes = req.Notes
+	}
+	if len(req.Payers) > 0 {
+		recurring.Payers = recurringPayers(req.Payers)
+	}
+	items := itemRequests(recurring.Items)
+	if len(req.Splits) > 0 {
+		recurring.Splits = recurringSplits(req.Splits)
+		items = nil
+	}
+	if len(req.Items) > 0 {
+		recurring.Splits = nil
+		items = req.Items
+	}
+
+	expense, err := ts.buildExpenseTransaction(group, recurring.CreatedBy, recurringExpenseRequest(recurring, items))
+	if err != nil {
+		return nil, err
+	}
+	recurring.Items = expense.Items
+	recurring.Category = expense.CategoryID
+
+	if req.EndDate != nil {
+		recurring.EndDate = req.EndDate
+	}
+	if req.NoEndDate {
+		recurring.EndDate = nil
+	}
+
+	// A new rule restarts the schedule; otherwise only the end date can have moved
+	if req.Frequency != "" || req.Interval > 0 || req.DayOfMonth > 0 || req.StartDate != nil {
+		if req.Frequency != "" {
+			recurring.Frequency = db.RecurrenceFrequency(req.Frequency)
+		}
+		if req.Interval > 0 {
+			recurring.Interval = req.Interval
+		}
+		if req.DayOfMonth > 0 {
+			recurring.DayOfMonth = req.DayOfMonth
+		}
+		if recurring.Frequency != db.RecurrenceMonthly {
+			recurring.DayOfMonth = 0
+		}
+
+		start := time.Now()
+		if recurring.NextOccurrence != nil {
+			start = *recurring.NextOccurrence
+		}
+		if req.StartDate != nil {
+			if req.StartDate.Before(startOfDay(time.Now())) {
+				return nil, errors.New("start date cannot be in the past")
+			}
+			start = *req.StartDate
+		}
+		if err := startSchedule(recurring, start); err != nil {
+			return nil, err
+		}
+	} else {
+		setNextOccurrence(recurring)
+	}
+
+	recurring.UpdatedBy = userID
+	if err := saveRecurringExpense(recurring, lastUpdate); err != nil {
+		return nil, err
+	}
+
+	return recurring, nil
+}
+
+// SetRecurringExpensePaused pauses or resumes a recurring expense. Occurrences that fell
+// while it was paused are not created when it is resumed.
+func (ts *TransactionService) SetRecurringExpensePaused(recurringID, userID primitive.ObjectID, paused bool) (*db.RecurringExpense, error) {
+	recurring, group, err := findRecurringExpense(recurringID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := canManageRecurringExpense(recurring, group, userID); err != nil {
+		return nil, err
+	}
+	if recurring.Paused == paused {
+		if paused {
+			return nil, errors.New("recurring expense is already paused")
+		}
+		return nil, errors.New("recurring expense is not paused")
+	}
+	lastUpdate := recurring.UpdatedAt
+
+	recurring.Paused = paused
+	if !paused {
+		recurring.LastError = ""
+		now := time.Now()
+		for recurring.NextOccurrence != nil && recurring.NextOccurrence.Before(now) {
+			recurring.NextIndex++
+			setNextOccurrence(recurring)
+		}
+	}
+
+	recurring.UpdatedBy = userID
+	if err := saveRecurringExpense(recurring, lastUpdate); err != nil {
+		return nil, err
+	}
+
+	return recurring, nil
+}
+
+// SkipRecurringOccurrence stops the occurrence on a given day from being created
+func (ts *TransactionService) SkipRecurringOccurrence(recurringID, userID primitive.ObjectID, date time.Time) (*db.RecurringExpense, error) {
+	recurring, group, err := findRecurringExpense(recurringID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := canManageRecurringExpense(recurring, group, userID); err != nil {
+		return nil, err
+	}
+	lastUpdate := recurring.UpdatedAt
+
+	var occurrence *time.Time
+	for i := recurring.NextIndex; i < recurring.NextIndex+maxSkipLookahead; i++ {
+		next, ok := occurrenceDate(recurring, i)
+		if !ok || startOfDay(next).After(date) {
+			break
+		}
+		if sameDay(next, date) {
+			occurrence = &next
+			break
+		}
+	}
+	if occurrence == nil {
+		return nil, errors.New("there is no upcoming occurrence on that day")
+	}
+	if isSkipped(recurring, *occurrence) {
+		return nil, errors.New("that occurrence is already skipped")
+	}
+
+	recurring.SkippedDates = append(recurring.SkippedDates, *occurrence)
+	recurring.UpdatedBy = userID
+	if err := saveRecurringExpense(recurring, lastUpdate); err != nil {
+		return nil, err
+	}
+
+	return recurring, nil
+}
+
+// GetUpcomingOccurrences lists the next occurrences of a recurring expense, skipped ones
+// included and marked
+func (ts *TransactionService) GetUpcomingOccurrences(recurringID, userID primitive.ObjectID, count int) ([]db.RecurringOccurrence, error) {
+	recurring, _, err := findRecurringExpense(recurringID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	occurrences := []db.RecurringOccurrence{}
+	for i := recurring.NextIndex; len(occurrences) < count && i < recurring.NextIndex+maxSkipLookahead; i++ {
+		date, ok := occurrenceDate(recurring, i)
+		if !ok {
+			break
+		}
+		occurrences = append(occurrences, db.RecurringOccurrence{Date: date, Skipped: isSkipped(recurring, date)})
+	}
+
+	return occurrences, nil
+}
+
+// DeleteRecurringExpense stops a recurring expense. Expenses already created from it stay.
+func (ts *TransactionService) DeleteRecurringExpense(recurringID, userID primitive.ObjectID) error {
+	recurring, group, err := findRecurringExpense(recurringID, userID)
+	if err != nil {
+		return err
+	}
+	if err := canManageRecurringExpense(recurring, group, userID); err != nil {
+		return err
+	}
+
+	return mgm.Coll(recurring).Delete(recurring)
+}
+
+// StartRecurringExpenseJob creates due occurrences of recurring expenses in the
+// background: once straight away, to catch up on anything missed while the server was
+// down, and then every few minutes
+func StartRecurringExpenseJob() {
+	go func() {
+		ticker := time.NewTicker(recurringExpenseInterval)
+		defer ticker.Stop()
+
+		for {
+			created, err := ProcessDueRecurringExpenses()
+			if err != nil {
+				log.Printf("Recurring expenses failed: %s", err.Error())
+			}
+			if created > 0 {
+				log.Printf("Created %d recurring expenses\n", created)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// ProcessDueRecurringExpenses creates every occurrence that is due and not skipped. A
+// recurring expense that cannot be created is paused with the reason, rather than retried
+// forever.
+func ProcessDueRecurringExpenses() (int, error) {
+	due := []*db.RecurringExpense{}
+	err := mgm.Coll(&db.RecurringExpense{}).SimpleFind(&due, bson.M{
+		"paused":          false,
+		"next_occurrence": bson.M{"$lte": time.Now()},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	ts := &TransactionService{}
+	created := 0
+	for _, recurring := range due {
+		n, err := ts.createDueOccurrences(recurring)
+		created += n
+		if err != nil {
+			log.Printf("Recurring expense %s: %s", recurring.ID.Hex(), err.Error())
+		}
+	}
+	return created, nil
+}
+
+// createDueOccurrences creates a recurring expense's occurrences up to now, moving the
+// schedule on after each. Occurrences are unique per recurring expense and date, so if
+// another server got to one first it is not created twice.
+func (ts *TransactionService) createDueOccurrences(recurring *db.RecurringExpense) (int, error) {
+	created := 0
+	now := time.Now()
+	for recurring.NextOccurrence != nil && !recurring.NextOccurrence.After(now) {
+		lastUpdate := recurring.UpdatedAt
+		date := *recurring.NextOccurrence
+
+		if !isSkipped(recurring, date) {
+			req := recurringExpenseRequest(recurring, itemRequests(recurring.Items))
+			req.RecurringExpenseID = recurring.ID
+			req.OccurrenceDate = &date
+
+			_, err := ts.CreateExpenseTransaction(recurring.CreatedBy, req)
+			if err != nil && !mongo.IsDuplicateKeyError(err) {
+				recurring.Paused = true
+				recurring.LastError = err.Error()
+				if saveErr := saveRecurringExpense(recurring, lastUpdate); saveErr != nil {
+					return created, saveErr
+				}
+				return created, err
+			}
+			if err == nil {
+				created++
+			}
+		}
+
+		recurring.NextIndex++
+		setNextOccurrence(recurring)
+		if err := saveRecurringExpense(recurring, lastUpdate); err != nil {
+			return created, err
+		}
+	}
+	return created, nil
+}
+
+// EnsureRecurringExpenseIndexes creates the indexes recurring expenses rely on: one to
+// find due occurrences, and a unique one so an occurrence can only become one expense
+func EnsureRecurringExpenseIndexes() error {
+	_, err := mgm.Coll(&db.RecurringExpense{}).Indexes().CreateOne(mgm.Ctx(), mongo.IndexModel{
+		Keys: bson.D{{Key: "paused", Value: 1}, {Key: "next_occurrence", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = mgm.Coll(&db.Transaction{}).Indexes().CreateOne(mgm.Ctx(), mongo.IndexModel{
+		Keys: bson.D{{Key: "recurring_expense_id", Value: 1}, {Key: "date", Value: 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+			"recurring_expense_id": bson.M{"$exists": true},
+		}),
+	})
+	return err
+}
+
+// findRecurringExpense loads a recurring expense and its group, if the user is a member
+func findRecurringExpense(recurringID, userID primitive.ObjectID) (*db.RecurringExpense, *db.Group, error) {
+	recurring := &db.RecurringExpense{}
+	err := mgm.Coll(recurring).FindByID(recurringID, recurring)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil, errors.New("recurring expense not found")
+		}
+		return nil, nil, err
+	}
+
+	group, err := GetGroupById(recurring.GroupID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return recurring, group, nil
+}
+
+// canManageRecurringExpense allows its creator, the group creator and admins to change a
+// recurring expense
+func canManageRecurringExpense(recurring *db.RecurringExpense, group *db.Group, userID primitive.ObjectID) error {
+	if recurring.CreatedBy == userID || group.CreatedBy == userID {
+		return nil
+	}
+	if user, err := FindUserById(userID); err == nil && user.Role == db.RoleAdmin {
+		return nil
+	}
+	return errors.New("only the creator of the recurring expense, the group creator or an admin can change it")
+}
+
+// saveRecurringExpense stores a recurring expense if nobody changed it since it was loaded
+func saveRecurringExpense(recurring *db.RecurringExpense, lastUpdate time.Time) error {
+	// Stored times only keep milliseconds; match them so the next save finds this one
+	recurring.UpdatedAt = time.Now().UTC().Truncate(time.Millisecond)
+
+	result, err := mgm.Coll(recurring).ReplaceOne(mgm.Ctx(), bson.M{
+		"_id":        recurring.ID,
+		"updated_at": lastUpdate,
+	}, recurring)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("the recurring expense was changed by someone else, reload and try again")
+	}
+	return nil
+}
+
+// startSchedule makes start the first occurrence of a recurring expense's schedule.
+// Monthly schedules start on the first DayOfMonth on or after it.
+func startSchedule(recurring *db.RecurringExpense, start time.Time) error {
+	recurring.StartDate = start.UTC()
+	recurring.NextIndex = 0
+
+	if recurring.Frequency == db.RecurrenceMonthly {
+		if recurring.DayOfMonth == 0 {
+			recurring.DayOfMonth = recurring.StartDate.Day()
+		}
+		if first, _ := occurrenceDate(recurring, 0); first.Before(recurring.StartDate) {
+			y, m, _ := recurring.StartDate.Date()
+			recurring.StartDate = time.Date(y, m+1, 1, recurring.StartDate.Hour(), recurring.StartDate.Minute(), recurring.StartDate.Second(), 0, time.UTC)
+		}
+		recurring.StartDate, _ = occurrenceDate(recurring, 0)
+	}
+
+	if recurring.EndDate != nil && recurring.EndDate.Before(recurring.StartDate) {
+		return errors.New("end date must be after the first occurrence")
+	}
+
+	setNextOccurrence(recurring)
+	return nil
+}
+
+// setNextOccurrence updates NextOccurrence from NextIndex, unsetting it once the schedule
+// has ended
+func setNextOccurrence(recurring *db.RecurringExpense) {
+	recurring.NextOccurrence = nil
+	if next, ok := occurrenceDate(recurring, recurring.NextIndex); ok {
+		recurring.NextOccurrence = &next
+	}
+}
+
+// occurrenceDate returns the date of occurrence n of a recurring expense, and false if it
+// falls after the end date. Months and years are counted from the start date, so a day
+// that does not exist in a month falls on its last day without drifting the schedule.
+func occurrenceDate(recurring *db.RecurringExpense, n int) (time.Time, bool) {
+	start := recurring.StartDate
+	step := n * recurring.Interval
+
+	var date time.Time
+	switch recurring.Frequency {
+	case db.RecurrenceDaily:
+		date = start.AddDate(0, 0, step)
+	case db.RecurrenceWeekly:
+		date = start.AddDate(0, 0, 7*step)
+	case db.RecurrenceMonthly:
+		date = dayOfMonth(start.Year(), start.Month()+time.Month(step), recurring.DayOfMonth, start)
+	case db.RecurrenceYearly:
+		date = dayOfMonth(start.Year()+step, start.Month(), start.Day(), start)
+	}
+
+	if recurring.EndDate != nil && date.After(*recurring.EndDate) {
+		return date, false
+	}
+	return date, true
+}
+
+// dayOfMonth returns the given day of a month at the time of day of clock, or the last
+// day of the month if it is shorter
+func dayOfMonth(year int, month time.Month, day int, clock time.Time) time.Time {
+	first := time.Date(year, month, 1, clock.Hour(), clock.Minute(), clock.Second(), 0, clock.Location())
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+func isSkipped(recurring *db.RecurringExpense, date time.Time) bool {
+	for _, skipped := range recurring.SkippedDates {
+		if skipped.Equal(date) {
+			return true
+		}
+	}
+	return false
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func sameDay(a, b time.Time) bool {
+	return startOfDay(a).Equal(startOfDay(b))
+}
+
+// recurringExpenseRequest is the expense request for an occurrence of a recurring expense
+func recurringExpenseRequest(recurring *db.RecurringExpense, items []models.TransactionItemRequest) models.CreateExpenseTransactionRequest {
+	req := models.CreateExpenseTransactionRequest{
+		GroupID:     recurring.GroupID.Hex(),
+		Description: recurring.Description,
+		Amount:      recurring.Amount,
+		Currency:    recurring.Currency,
+		SplitType:   string(recurring.SplitType),
+		Items:       items,
+		Category:    recurring.Category,
+		Notes:       recurring.Notes,
+	}
+	for _, payer := range recurring.Payers {
+		req.Payers = append(req.Payers, models.TransactionPayerRequest{UserID: payer.UserID.Hex(), Amount: payer.Amount})
+	}
+	for _, split := range recurring.Splits {
+		req.Splits = append(req.Splits, models.TransactionSplitRequest{
+			UserID:     split.UserID.Hex(),
+			Amount:     split.Amount,
+			Percentage: split.Percentage,
+			Shares:     split.Shares,
+		})
+	}
+	return req
+}
+
+func recurringPayers(requests []models.TransactionPayerRequest) []db.RecurringPayer {
+	payers := []db.RecurringPayer{}
+	for _, request := range requests {
+		userID, _ := primitive.ObjectIDFromHex(request.UserID)
+		payers = append(payers, db.RecurringPayer{UserID: userID, Amount: request.Amount})
+	}
+	return payers
+}
+
+func recurringSplits(requests []models.TransactionSplitRequest) []db.RecurringSplit {
+	var splits []db.RecurringSplit
+	for _, request := range requests {
+		userID, _ := primitive.ObjectIDFromHex(request.UserID)
+		splits = append(splits, db.RecurringSplit{
+			UserID:     userID,
+			Amount:     request.Amount,
+			Percentage: request.Percentage,
+			Shares:     request.Shares,
+		})
+	}
+	return splits
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
+)
+
+func testDate(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 9, 30, 0, 0, time.UTC)
+}
+
+func TestOccurrenceDate(t *testing.T) {
+	endDate := testDate(2024, time.March, 15)
+
+	tests := []struct {
+		name      string
+		recurring db.RecurringExpense
+		n         int
+		want      time.Time
+		wantOK    bool
+	}{
+		{
+			name:      "first occurrence is the start date",
+			recurring: db.RecurringExpense{Frequency: db.RecurrenceDaily, Interval: 1, StartDate: testDate(2024, time.January, 10)},
+			n:         0,
+			want:      testDate(2024, time.January, 10),
+			wantOK:    true,
+		},
+		{
+			name:      "daily",
+			recurring: db.RecurringExpense{Frequency: db.RecurrenceDaily, Interval: 3, StartDate: testDate(2024, time.February, 27)},
+			n:         1,
+			want:      testDate(2024, time.March, 1),
+			wantOK:    true,
+		},
+		{
+			name:      "every other week",
+			recurring: db.RecurringExpense{Frequency: db.RecurrenceWeekly, Interval: 2, StartDate: testDate(2024, time.January, 1)},
+			n:         3,
+			want:      testDate(2024, time.February, 12),
+			wantOK:    true,
+		},
+		{
+			name:      "month end in a leap year",
+			recurring: db.RecurringExpense{Frequency: db.RecurrenceMonthly, Interval: 1, DayOfMonth: 31, StartDate: testDate(2024, time.January, 31)},
+			n:         1,
+			want:      testDate(2024, time.February, 29),
+			wantOK:    true,
+		},
+		{
+			name:      "month end in a common year",
+			recurring: db.RecurringExpense{Frequency: db.RecurrenceMonthly, Interval: 1, DayOfMonth: 31, StartDate: testDate(2023, time.January, 31)},
+			n:         1,
+			want:      testDate(2023, time.February, 28),
+			wantOK:    true,
+		},
+		{
+			name:      "month end does not drift after a short month",
+			recurring: db.RecurringExpense{Frequency: db.RecurrenceMonthly, Interval: 1, DayOfMonth: 31, StartDate: testDate(2024, time.January, 31)},
+			n:         2,
+			want:      testDate(2024, time.March, 31),
+			wantOK:    true,
+		},
+		{
+			name:      "30-day month",
+			recurring: db.RecurringExpense{Frequency: db.RecurrenceMonthly, Interval: 1, DayOfMonth: 31, StartDate: testDate(2024, time.January, 31)},
+			n:         3,
+			want:      testDate(2024, time.April, 30),
+			wantOK:    true,
+		},
+		{
+			name:      "months roll over into the next year",
+			recurring: db.RecurringExpense{Frequency: db.RecurrenceMonthly, Interval: 2, DayOfMonth: 15, StartDate: testDate(2024, time.November, 15)},
+			n:         1,
+			want:      testDate(2025, time.January, 15),
+			wantOK:    true,
+		},
+		{
+			name:      "leap day in a common year",
+			recurring: db.RecurringExpense{Frequency: db.RecurrenceYearly, Interval: 1, StartDate: testDate(2024, time.February, 29)},
+			n:         1,
+			want:      testDate(2025, time.February, 28),
+			wantOK:    true,
+		},
+		{
+			name:      "leap day in the next leap year",
+			recurring: db.RecurringExpense{Frequency: db.RecurrenceYearly, Interval: 1, StartDate: testDate(2024, time.February, 29)},
+			n:         4,
+			want:      testDate(2028, time.February, 29),
+			wantOK:    true,
+		},
+		{
+			name:      "on the end date",
+			recurring: db.RecurringExpense{Frequency: db.RecurrenceDaily, Interval: 1, StartDate: testDate(2024, time.March, 14), EndDate: &endDate},
+			n:         1,
+			want:      testDate(2024, time.March, 15),
+			wantOK:    true,
+		},
+		{
+			name:      "after the end date",
+			recurring: db.RecurringExpense{Frequency: db.RecurrenceMonthly, Interval: 1, DayOfMonth: 31, StartDate: testDate(2024, time.January, 31), EndDate: &endDate},
+			n:         2,
+			want:      testDate(2024, time.March, 31),
+			wantOK:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := occurrenceDate(&tt.recurring, tt.n)
+			if !got.Equal(tt.want) || ok != tt.wantOK {
+				t.Fatalf("occurrence %d = %s, %v, want %s, %v", tt.n, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestStartSchedule(t *testing.T) {
+	tests := []struct {
+		name       string
+		dayOfMonth int
+		start      time.Time
+		want       time.Time
+		wantDay    int
+	}{
+		{"day of month defaults to the start day", 0, testDate(2024, time.January, 20), testDate(2024, time.January, 20), 20},
+		{"later day in the start month", 25, testDate(2024, time.January, 20), testDate(2024, time.January, 25), 25},
+		{"earlier day moves to the next month", 10, testDate(2024, time.January, 20), testDate(2024, time.February, 10), 10},
+		{"month end of a short month", 31, testDate(2024, time.February, 5), testDate(2024, time.February, 29), 31},
+		{"next month in the next year", 5, testDate(2024, time.December, 20), testDate(2025, time.January, 5), 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recurring := &db.RecurringExpense{Frequency: db.RecurrenceMonthly, Interval: 1, DayOfMonth: tt.dayOfMonth}
+			if err := startSchedule(recurring, tt.start); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !recurring.StartDate.Equal(tt.want) || recurring.DayOfMonth != tt.wantDay {
+				t.Fatalf("starts %s on day %d, want %s on day %d", recurring.StartDate, recurring.DayOfMonth, tt.want, tt.wantDay)
+			}
+			if recurring.NextOccurrence == nil || !recurring.NextOccurrence.Equal(tt.want) {
+				t.Fatalf("next occurrence = %v, want %s", recurring.NextOccurrence, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartScheduleRejectsEndBeforeFirstOccurrence(t *testing.T) {
+	endDate := testDate(2024, time.January, 31)
+	recurring := &db.RecurringExpense{Frequency: db.RecurrenceMonthly, Interval: 1, DayOfMonth: 10, EndDate: &endDate}
+
+	if err := startSchedule(recurring, testDate(2024, time.January, 20)); err == nil {
+		t.Fatal("expected an error for an end date before the first occurrence")
+	}
+}
@@ -0,0 +1,314 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models"
+	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
+	"github.com/kamva/mgm/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// refundableExpense is what is left of an expense after its earlier refunds, in total
+// and per payer and split, in both the expense currency and the group currency
+type refundableExpense struct {
+	Amount          db.Money
+	ConvertedAmount db.Money
+	Payers          []db.TransactionPayer
+	Splits          []db.TransactionSplit
+}
+
+// CreateRefundTransaction records money coming back for an expense, e.g. a partially
+// returned purchase. The refund lowers what the payers paid and what the splits owe, so
+// a full refund cancels the expense exactly. It uses the expense's exchange rate.
+func (ts *TransactionService) CreateRefundTransaction(userID primitive.ObjectID, req models.CreateRefundTransactionRequest) (*db.Transaction, error) {
+	expenseID, err := primitive.ObjectIDFromHex(req.ExpenseID)
+	if err != nil {
+		return nil, errors.New("invalid expense ID")
+	}
+
+	expense := &db.Transaction{}
+	if err := mgm.Coll(expense).FindByID(expenseID, expense); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("expense not found")
+		}
+		return nil, err
+	}
+
+	if expense.Type != db.TransactionTypeExpense {
+		return nil, errors.New("only expenses can be refunded")
+	}
+
+	if expense.DeletedAt != nil {
+		return nil, errors.New("deleted expenses cannot be refunded")
+	}
+
+	// Check if user is group member
+	group, err := GetGroupById(expense.GroupID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining, err := ts.refundableExpense(expense)
+	if err != nil {
+		return nil, err
+	}
+	if req.Amount > remaining.Amount {
+		return nil, errors.New("refund exceeds what is left of the expense")
+	}
+
+	description := req.Description
+	if description == "" {
+		description = "Refund: " + expense.Description
+	}
+
+	transaction := db.NewRefundTransaction(expense, description, req.Amount, userID)
+	transaction.Notes = req.Notes
+	transaction.GroupCurrency = expense.GroupCurrency
+	transaction.ExchangeRate = expense.ExchangeRate
+
+	// Refunding everything that is left hands back exactly what is left, so nobody
+	// keeps a stray minor unit from rounding
+	fullRefund := req.Amount == remaining.Amount
+	if fullRefund {
+		transaction.ConvertedAmount = remaining.ConvertedAmount
+	} else {
+		transaction.ConvertedAmount = ConvertMoney(req.Amount, expense.Currency, expense.GroupCurrency, expense.ExchangeRate)
+	}
+
+	if err := ts.buildRefundPayers(transaction, remaining, req.Payers, fullRefund); err != nil {
+		return nil, err
+	}
+	if err := ts.buildRefundSplits(transaction, remaining, req.SplitType, req.Splits, fullRefund); err != nil {
+		return nil, err
+	}
+
+	// Net participants: getting money back lowers a balance, a smaller share raises it
+	transaction.ComputeParticipants()
+
+	return ts.executeTransactionWithBalanceUpdate(transaction, group)
+}
+
+// buildRefundPayers decides who got the refund. By default it goes back to the expense's
+// payers in proportion to what is left of their payment.
+func (ts *TransactionService) buildRefundPayers(transaction *db.Transaction, remaining *refundableExpense, payerRequests []models.TransactionPayerRequest, fullRefund bool) error {
+	if len(payerRequests) == 0 {
+		if fullRefund {
+			transaction.Payers = remaining.Payers
+			return nil
+		}
+
+		amounts := make([]db.Money, len(remaining.Payers))
+		for i, payer := range remaining.Payers {
+			amounts[i] = payer.Amount
+		}
+		amounts, err := allocateRemaining(transaction.Amount, amounts)
+		if err != nil {
+			return errors.New("payers must be provided, the expense's payers have been fully refunded")
+		}
+		converted := allocateConverted(transaction.ConvertedAmount, amounts)
+		for i, payer := range remaining.Payers {
+			transaction.Payers = append(transaction.Payers, db.TransactionPayer{
+				UserID:          payer.UserID,
+				UserName:        payer.UserName,
+				Amount:          amounts[i],
+				ConvertedAmount: converted[i],
+			})
+		}
+		return nil
+	}
+
+	var total db.Money
+	amounts := make([]db.Money, len(payerRequests))
+	for i, payer := range payerRequests {
+		amounts[i] = payer.Amount
+		total += payer.Amount
+	}
+	if total != transaction.Amount {
+		return errors.New("refunded amounts must equal the refund amount")
+	}
+
+	converted := allocateConverted(transaction.ConvertedAmount, amounts)
+	for i, payer := range payerRequests {
+		payerUserID, err := primitive.ObjectIDFromHex(payer.UserID)
+		if err != nil {
+			return errors.New("invalid payer user ID")
+		}
+
+		user, err := FindUserById(payerUserID)
+		if err != nil {
+			return errors.New("payer user not found")
+		}
+
+		transaction.Payers = append(transaction.Payers, db.TransactionPayer{
+			UserID:          payerUserID,
+			UserName:        user.Name,
+			Amount:          amounts[i],
+			ConvertedAmount: converted[i],
+		})
+	}
+	return nil
+}
+
+// buildRefundSplits decides whose share goes down. By default every split of the expense
+// goes down in proportion to what is left of it; otherwise the refund is split by type.
+func (ts *TransactionService) buildRefundSplits(transaction *db.Transaction, remaining *refundableExpense, splitType string, splitRequests []models.TransactionSplitRequest, fullRefund bool) error {
+	if len(splitRequests) == 0 {
+		if splitType != "" {
+			return errors.New("splits must be provided with a split type")
+		}
+		if fullRefund {
+			transaction.Splits = remaining.Splits
+			return nil
+		}
+
+		amounts := make([]db.Money, len(remaining.Splits))
+		for i, split := range remaining.Splits {
+			amounts[i] = split.Amount
+		}
+		amounts, err := allocateRemaining(transaction.Amount, amounts)
+		if err != nil {
+			return errors.New("splits must be provided, the expense's shares have been fully refunded")
+		}
+		converted := allocateConverted(transaction.ConvertedAmount, amounts)
+		for i, split := range remaining.Splits {
+			transaction.Splits = append(transaction.Splits, db.TransactionSplit{
+				UserID:          split.UserID,
+				UserName:        split.UserName,
+				Amount:          amounts[i],
+				ConvertedAmount: converted[i],
+			})
+		}
+		return nil
+	}
+
+	if splitType == "" {
+		splitType = string(db.SplitTypeExact)
+	}
+	transaction.SplitType = db.SplitType(splitType)
+
+	amounts, err := ComputeSplitAmounts(transaction.Amount, transaction.SplitType, splitRequests)
+	if err != nil {
+		return err
+	}
+
+	// A share can only go down by what is left of it
+	left := make(map[primitive.ObjectID]db.Money)
+	for _, split := range remaining.Splits {
+		left[split.UserID] = split.Amount
+	}
+
+	converted := allocateConverted(transaction.ConvertedAmount, amounts)
+	for i, split := range splitRequests {
+		splitUserID, err := primitive.ObjectIDFromHex(split.UserID)
+		if err != nil {
+			return errors.New("invalid split user ID")
+		}
+		if amounts[i] > left[splitUserID] {
+			return errors.New("a refund cannot lower someone's share below zero")
+		}
+
+		user, err := FindUserById(splitUserID)
+		if err != nil {
+			return errors.New("split user not found")
+		}
+
+		transaction.Splits = append(transaction.Splits, db.TransactionSplit{
+			UserID:          splitUserID,
+			UserName:        user.Name,
+			Amount:          amounts[i],
+			ConvertedAmount: converted[i],
+		})
+	}
+	return nil
+}
+
+// allocateRemaining spreads a refund in proportion to what is left of each part. Parts
+// that are already fully refunded get nothing.
+func allocateRemaining(amount db.Money, left []db.Money) ([]db.Money, error) {
+	weights := make([]int64, len(left))
+	var total int64
+	for i, part := range left {
+		if part > 0 {
+			weights[i] = int64(part)
+			total += weights[i]
+		}
+	}
+	if total == 0 {
+		return nil, errors.New("nothing left to refund")
+	}
+	return amount.Allocate(weights), nil
+}
+
+// refundableExpense subtracts the expense's earlier refunds from its payers and splits
+func (ts *TransactionService) refundableExpense(expense *db.Transaction) (*refundableExpense, error) {
+	refunds, err := ts.expenseRefunds(expense.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := &refundableExpense{
+		Amount:          expense.Amount,
+		ConvertedAmount: expense.ConvertedAmount,
+		Payers:          append([]db.TransactionPayer(nil), expense.Payers...),
+		Splits:          append([]db.TransactionSplit(nil), expense.Splits...),
+	}
+
+	for _, refund := range refunds {
+		remaining.Amount -= refund.Amount
+		remaining.ConvertedAmount -= refund.ConvertedAmount
+
+		for _, refunded := range refund.Payers {
+			for i := range remaining.Payers {
+				if remaining.Payers[i].UserID == refunded.UserID {
+					remaining.Payers[i].Amount -= refunded.Amount
+					remaining.Payers[i].ConvertedAmount -= refunded.ConvertedAmount
+					break
+				}
+			}
+		}
+		for _, refunded := range refund.Splits {
+			for i := range remaining.Splits {
+				if remaining.Splits[i].UserID == refunded.UserID {
+					remaining.Splits[i].Amount -= refunded.Amount
+					remaining.Splits[i].ConvertedAmount -= refunded.ConvertedAmount
+					break
+				}
+			}
+		}
+	}
+
+	return remaining, nil
+}
+
+// covers reports whether a refund fits in what is left of the expense, the same way a
+// new refund is checked: in total, and for every share it lowers
+func (remaining *refundableExpense) covers(refund *db.Transaction) bool {
+	if refund.Amount > remaining.Amount {
+		return false
+	}
+
+	left := make(map[primitive.ObjectID]db.Money)
+	for _, split := range remaining.Splits {
+		left[split.UserID] = split.Amount
+	}
+	for _, refunded := range refund.Splits {
+		if refunded.Amount > left[refunded.UserID] {
+			return false
+		}
+	}
+	return true
+}
+
+// expenseRefunds returns the refunds recorded against an expense, leaving out deleted ones
+func (ts *TransactionService) expenseRefunds(expenseID primitive.ObjectID) ([]*db.Transaction, error) {
+	var refunds []*db.Transaction
+	err := mgm.Coll(&db.Transaction{}).SimpleFind(&refunds, bson.M{
+		"type":       db.TransactionTypeRefund,
+		"refund_of":  expenseID,
+		"deleted_at": bson.M{"$exists": false},
+	})
+	return refunds, err
+}
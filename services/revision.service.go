@@ -0,0 +1,141 @@
+package services
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+
+	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
+	"github.com/kamva/mgm/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// revisionIgnoredFields change on every revision and are already recorded on the revision itself
+var revisionIgnoredFields = map[string]bool{
+	"updated_at": true,
+	"updated_by": true,
+}
+
+// snapshotTransaction returns a deep copy of a transaction as it is stored, so later
+// changes to the transaction do not leak into the copy. Computed fields are left out.
+func snapshotTransaction(transaction *db.Transaction) *db.Transaction {
+	snapshot := &db.Transaction{}
+	data, err := bson.Marshal(transaction)
+	if err == nil {
+		err = bson.Unmarshal(data, snapshot)
+	}
+	if err != nil {
+		*snapshot = *transaction
+	}
+	return snapshot
+}
+
+// recordRevision stores an immutable revision of a transaction as part of the session
+// that changed it
+func recordRevision(sc mongo.SessionContext, action db.RevisionAction, before, after *db.Transaction, actorID primitive.ObjectID) error {
+	actorName := ""
+	if actor, err := FindUserById(actorID); err == nil {
+		actorName = actor.Name
+	}
+
+	revision := db.NewTransactionRevision(action, before, snapshotTransaction(after), actorID, actorName)
+	return mgm.Coll(revision).CreateWithCtx(sc, revision)
+}
+
+// GetTransactionHistory returns every revision of a transaction, oldest first, each with
+// the fields it changed
+func (ts *TransactionService) GetTransactionHistory(transactionID, userID primitive.ObjectID) ([]*db.TransactionRevision, error) {
+	// Check the user can see the transaction
+	if _, err := ts.GetTransactionById(transactionID, userID); err != nil {
+		return nil, err
+	}
+
+	revisions := []*db.TransactionRevision{}
+	err := mgm.Coll(&db.TransactionRevision{}).SimpleFind(&revisions, bson.M{"transaction_id": transactionID},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, revision := range revisions {
+		if revision.Before != nil {
+			revision.Changes = diffTransactions(revision.Before, revision.After)
+		}
+	}
+
+	return revisions, nil
+}
+
+// EnsureRevisionIndexes creates the index revisions are looked up by
+func EnsureRevisionIndexes() error {
+	_, err := mgm.Coll(&db.TransactionRevision{}).Indexes().CreateOne(mgm.Ctx(), mongo.IndexModel{
+		Keys: bson.D{{Key: "transaction_id", Value: 1}},
+	})
+	return err
+}
+
+// diffTransactions lists the fields that differ between two versions of a transaction, by
+// their JSON names, sorted by field
+func diffTransactions(before, after *db.Transaction) []db.FieldChange {
+	beforeFields, afterFields := flattenTransaction(before), flattenTransaction(after)
+
+	fields := make([]string, 0, len(beforeFields)+len(afterFields))
+	for field := range beforeFields {
+		fields = append(fields, field)
+	}
+	for field := range afterFields {
+		if _, ok := beforeFields[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	changes := []db.FieldChange{}
+	for _, field := range fields {
+		if revisionIgnoredFields[field] {
+			continue
+		}
+		if !reflect.DeepEqual(beforeFields[field], afterFields[field]) {
+			changes = append(changes, db.FieldChange{Field: field, Before: beforeFields[field], After: afterFields[field]})
+		}
+	}
+	return changes
+}
+
+// flattenTransaction maps each leaf field of a transaction's JSON to its value
+func flattenTransaction(transaction *db.Transaction) map[string]interface{} {
+	fields := make(map[string]interface{})
+
+	data, err := json.Marshal(transaction)
+	if err != nil {
+		return fields
+	}
+	var document map[string]interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return fields
+	}
+
+	for key, value := range document {
+		flattenField(key, value, fields)
+	}
+	return fields
+}
+
+func flattenField(path string, value interface{}, fields map[string]interface{}) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, child := range value {
+			flattenField(path+"."+key, child, fields)
+		}
+	case []interface{}:
+		for i, child := range value {
+			flattenField(path+"."+strconv.Itoa(i), child, fields)
+		}
+	default:
+		fields[path] = value
+	}
+}
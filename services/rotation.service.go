@@ -0,0 +1,301 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models"
+	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
+	"github.com/kamva/mgm/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// rotationTurnsKept is how many recent turns a rotation remembers
+const rotationTurnsKept = 50
+
+// CreateRotation starts taking turns paying for a category of a group's expenses. Without
+// members, everyone in the group takes part in the order they joined.
+func (ts *TransactionService) CreateRotation(groupID, userID primitive.ObjectID, req models.CreateRotationRequest) (*db.Rotation, error) {
+	group, err := GetGroupById(groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	members := group.Members
+	if len(req.MemberIDs) > 0 {
+		inGroup := make(map[primitive.ObjectID]bool)
+		for _, memberID := range group.Members {
+			inGroup[memberID] = true
+		}
+
+		members = []primitive.ObjectID{}
+		seen := make(map[primitive.ObjectID]bool)
+		for _, idHex := range req.MemberIDs {
+			memberID, err := primitive.ObjectIDFromHex(idHex)
+			if err != nil {
+				return nil, errors.New("invalid member ID")
+			}
+			if !inGroup[memberID] {
+				return nil, fmt.Errorf("user %s is not a member of this group", idHex)
+			}
+			if seen[memberID] {
+				return nil, fmt.Errorf("user %s is in the rotation twice", idHex)
+			}
+			seen[memberID] = true
+			members = append(members, memberID)
+		}
+	}
+	if len(members) < 2 {
+		return nil, errors.New("a rotation needs at least two members")
+	}
+
+	strategy := db.RotationFixedOrder
+	if req.Strategy != "" {
+		strategy = db.RotationStrategy(req.Strategy)
+	}
+
+	category, err := resolveCategory(groupID, req.Category)
+	if err != nil {
+		return nil, err
+	}
+
+	rotation := db.NewRotation(groupID, req.Name, category, strategy, members, userID)
+	if err := mgm.Coll(rotation).Create(rotation); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errors.New("this group already has a rotation for that category")
+		}
+		return nil, err
+	}
+
+	return rotation, nil
+}
+
+// GetGroupRotations returns a group's rotations
+func (ts *TransactionService) GetGroupRotations(groupID, userID primitive.ObjectID) ([]*db.Rotation, error) {
+	if _, err := GetGroupById(groupID, userID); err != nil {
+		return nil, err
+	}
+
+	rotations := []*db.Rotation{}
+	err := mgm.Coll(&db.Rotation{}).SimpleFind(&rotations, bson.M{"group_id": groupID},
+		options.Find().SetSort(bson.D{{Key: "category", Value: 1}}))
+	return rotations, err
+}
+
+// SuggestNextPayer works out whose turn it is to pay the next expense of a category,
+// given by ID or name
+func (ts *TransactionService) SuggestNextPayer(groupID, userID primitive.ObjectID, category string) (*db.RotationSuggestion, error) {
+	group, err := GetGroupById(groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := resolveCategory(groupID, category)
+	if err != nil {
+		return nil, err
+	}
+
+	rotation := &db.Rotation{}
+	err = mgm.Coll(rotation).First(bson.M{"group_id": groupID, "category_id": info.ID}, rotation)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("this group has no rotation for that category")
+		}
+		return nil, err
+	}
+
+	return nextPayer(rotation, group)
+}
+
+// RemindRotation notifies whoever's turn it is to pay next
+func (ts *TransactionService) RemindRotation(groupID, rotationID, userID primitive.ObjectID) (*db.RotationSuggestion, error) {
+	rotation, group, err := findRotation(groupID, rotationID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestion, err := nextPayer(rotation, group)
+	if err != nil {
+		return nil, err
+	}
+
+	go sendRotationTurnNotification(suggestion, group)
+
+	return suggestion, nil
+}
+
+// DeleteRotation stops a rotation. Its creator, the group creator and admins can delete it.
+func (ts *TransactionService) DeleteRotation(groupID, rotationID, userID primitive.ObjectID) error {
+	rotation, group, err := findRotation(groupID, rotationID, userID)
+	if err != nil {
+		return err
+	}
+
+	if rotation.CreatedBy != userID && group.CreatedBy != userID {
+		user, err := FindUserById(userID)
+		if err != nil || user.Role != db.RoleAdmin {
+			return errors.New("only the creator of the rotation, the group creator or an admin can delete it")
+		}
+	}
+
+	return mgm.Coll(rotation).Delete(rotation)
+}
+
+// recordRotationTurn counts an expense as its payer's turn in the rotation for its
+// category, if there is one, moves the rotation on to the member after them and reminds
+// whoever is next. Rotations never block an expense, so failures are only logged.
+func (ts *TransactionService) recordRotationTurn(transaction *db.Transaction, group *db.Group) {
+	if transaction.Type != db.TransactionTypeExpense || len(transaction.Payers) == 0 || transaction.CategoryID == "" {
+		return
+	}
+
+	rotation := &db.Rotation{}
+	err := mgm.Coll(rotation).First(bson.M{"group_id": group.ID, "category_id": transaction.CategoryID}, rotation)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Printf("Error finding rotation for transaction %s: %v\n", transaction.ID.Hex(), err)
+		}
+		return
+	}
+
+	// With several payers, whoever paid the most took the turn
+	payer := transaction.Payers[0]
+	for _, p := range transaction.Payers[1:] {
+		if p.ConvertedAmount > payer.ConvertedAmount {
+			payer = p
+		}
+	}
+
+	index := -1
+	for i, memberID := range rotation.Members {
+		if memberID == payer.UserID {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return
+	}
+
+	rotation.NextIndex = (index + 1) % len(rotation.Members)
+	turn := db.RotationTurn{UserID: payer.UserID, TransactionID: transaction.ID, PaidAt: time.Now()}
+	_, err = mgm.Coll(rotation).UpdateOne(mgm.Ctx(), bson.M{"_id": rotation.ID}, bson.M{
+		"$set":  bson.M{"next_index": rotation.NextIndex, "updated_at": time.Now()},
+		"$push": bson.M{"turns": bson.M{"$each": []db.RotationTurn{turn}, "$slice": -rotationTurnsKept}},
+	})
+	if err != nil {
+		log.Printf("Error recording rotation turn for transaction %s: %v\n", transaction.ID.Hex(), err)
+		return
+	}
+
+	if suggestion, err := nextPayer(rotation, group); err == nil {
+		go sendRotationTurnNotification(suggestion, group)
+	}
+}
+
+// EnsureRotationIndexes creates the unique index that keeps a group to one rotation per
+// category
+func EnsureRotationIndexes() error {
+	_, err := mgm.Coll(&db.Rotation{}).Indexes().CreateOne(mgm.Ctx(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "group_id", Value: 1}, {Key: "category", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// findRotation loads a rotation of a group the user belongs to
+func findRotation(groupID, rotationID, userID primitive.ObjectID) (*db.Rotation, *db.Group, error) {
+	group, err := GetGroupById(groupID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rotation := &db.Rotation{}
+	err = mgm.Coll(rotation).First(bson.M{"_id": rotationID, "group_id": groupID}, rotation)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil, errors.New("rotation not found")
+		}
+		return nil, nil, err
+	}
+
+	return rotation, group, nil
+}
+
+// nextPayer works out whose turn it is in a rotation. Members who have left the group
+// are passed over. In a fixed order it is the member the rotation points at; by balance
+// it is whoever has the lowest group balance, ties going to whoever is next in order.
+func nextPayer(rotation *db.Rotation, group *db.Group) (*db.RotationSuggestion, error) {
+	inGroup := make(map[primitive.ObjectID]bool)
+	for _, memberID := range group.Members {
+		inGroup[memberID] = true
+	}
+
+	// Members still in the group, starting from whoever the rotation points at
+	var order []primitive.ObjectID
+	for i := range rotation.Members {
+		memberID := rotation.Members[(rotation.NextIndex+i)%len(rotation.Members)]
+		if inGroup[memberID] {
+			order = append(order, memberID)
+		}
+	}
+	if len(order) == 0 {
+		return nil, errors.New("nobody in this rotation is still in the group")
+	}
+
+	suggestion := &db.RotationSuggestion{
+		RotationID: rotation.ID,
+		Name:       rotation.Name,
+		CategoryID: rotation.CategoryID,
+		Category:   rotation.Category,
+		Strategy:   rotation.Strategy,
+		UserID:     order[0],
+	}
+
+	if rotation.Strategy == db.RotationBalance {
+		var balances []*db.GroupBalance
+		if err := mgm.Coll(&db.GroupBalance{}).SimpleFind(&balances, bson.M{"group_id": group.ID}); err != nil {
+			return nil, err
+		}
+		memberBalances := make(map[primitive.ObjectID]db.Money)
+		for _, balance := range balances {
+			memberBalances[balance.UserID] = balance.Balance
+		}
+
+		lowest := memberBalances[order[0]]
+		for _, memberID := range order[1:] {
+			if memberBalances[memberID] < lowest {
+				suggestion.UserID, lowest = memberID, memberBalances[memberID]
+			}
+		}
+		suggestion.Balance = &lowest
+	}
+
+	if user, err := FindUserById(suggestion.UserID); err == nil {
+		suggestion.UserName = user.Name
+	}
+
+	return suggestion, nil
+}
+
+// sendRotationTurnNotification reminds a member that it is their turn to pay
+func sendRotationTurnNotification(suggestion *db.RotationSuggestion, group *db.Group) {
+	name := suggestion.Name
+	if name == "" {
+		name = suggestion.Category
+	}
+
+	data := map[string]interface{}{
+		"type":        "rotation_turn",
+		"group_id":    group.ID.Hex(),
+		"rotation_id": suggestion.RotationID.Hex(),
+		"category_id": suggestion.CategoryID,
+		"category":    suggestion.Category,
+	}
+	NotifyUser(suggestion.UserID, "Your Turn to Pay", fmt.Sprintf("It's your turn to pay for %s in %s", name, group.Name), data)
+}
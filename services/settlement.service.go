@@ -0,0 +1,438 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models"
+	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
+	"github.com/kamva/mgm/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// settlementTransitions lists the states a settlement can move to from each state.
+// Confirmed and cancelled settlements are final.
+var settlementTransitions = map[db.SettlementStatus][]db.SettlementStatus{
+	db.SettlementProposed: {db.SettlementPaid, db.SettlementConfirmed, db.SettlementCancelled},
+	db.SettlementPaid:     {db.SettlementConfirmed, db.SettlementDisputed, db.SettlementCancelled},
+	db.SettlementDisputed: {db.SettlementPaid, db.SettlementConfirmed, db.SettlementCancelled},
+}
+
+// initialSettlementStatus is the state a new settlement starts in. A settlement recorded
+// as completed by the payee is confirmed straight away; recorded by anyone else it only
+// counts as paid until the payee confirms it.
+func initialSettlementStatus(payeeID primitive.ObjectID, isCompleted bool, createdBy primitive.ObjectID) db.SettlementStatus {
+	if !isCompleted {
+		return db.SettlementProposed
+	}
+	if createdBy == payeeID {
+		return db.SettlementConfirmed
+	}
+	return db.SettlementPaid
+}
+
+// settlementParties returns who pays and who receives a settlement
+func settlementParties(transaction *db.Transaction) (payerID, payeeID primitive.ObjectID) {
+	if len(transaction.Payers) > 0 {
+		payerID = transaction.Payers[0].UserID
+	}
+	if len(transaction.Splits) > 0 {
+		payeeID = transaction.Splits[0].UserID
+	}
+	return payerID, payeeID
+}
+
+// settledAmount is how much of a settlement the payee has confirmed receiving, in the
+// group currency. A confirmed settlement is settled in full.
+func settledAmount(transaction *db.Transaction) db.Money {
+	if transaction.Status == db.SettlementConfirmed {
+		return transaction.ConvertedAmount
+	}
+
+	var settled db.Money
+	for _, payment := range transaction.Payments {
+		if payment.ConfirmedAt != nil {
+			settled += payment.ConvertedAmount
+		}
+	}
+	return settled
+}
+
+// findSettlement loads a settlement transaction
+func findSettlement(transactionID primitive.ObjectID) (*db.Transaction, error) {
+	transaction := &db.Transaction{}
+	err := mgm.Coll(transaction).FindByID(transactionID, transaction)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("transaction not found")
+		}
+		return nil, err
+	}
+
+	if transaction.Type != db.TransactionTypeSettlement {
+		return nil, errors.New("transaction is not a settlement")
+	}
+
+	if transaction.DeletedAt != nil {
+		return nil, errors.New("deleted settlements cannot be changed, restore them first")
+	}
+
+	return transaction, nil
+}
+
+// UpdateSettlementStatus moves a settlement to a new state. Only the payer can mark it
+// paid, only the payee can confirm or dispute it, and either can cancel it. Balances
+// move when the settlement is confirmed, and the other party is notified of every change.
+func (ts *TransactionService) UpdateSettlementStatus(transactionID, userID primitive.ObjectID, req models.UpdateSettlementStatusRequest) (*db.Transaction, error) {
+	transaction, err := findSettlement(transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	payerID, payeeID := settlementParties(transaction)
+	if userID != payerID && userID != payeeID {
+		return nil, errors.New("only the payer or payee can update a settlement")
+	}
+
+	status := db.SettlementStatus(req.Status)
+	allowed := false
+	for _, next := range settlementTransitions[transaction.Status] {
+		if next == status {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("a %s settlement cannot be marked as %s", transaction.Status, status)
+	}
+
+	switch status {
+	case db.SettlementPaid:
+		if userID != payerID {
+			return nil, errors.New("only the payer can mark a settlement as paid")
+		}
+	case db.SettlementConfirmed, db.SettlementDisputed:
+		if userID != payeeID {
+			return nil, errors.New("only the payee can confirm or dispute a settlement")
+		}
+	}
+
+	group, err := GetGroupById(transaction.GroupID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	before := snapshotTransaction(transaction)
+
+	now := time.Now()
+	if req.SettlementMethod != "" {
+		transaction.SettlementMethod = req.SettlementMethod
+	}
+	if req.ProofOfPayment != "" {
+		transaction.ProofOfPayment = req.ProofOfPayment
+	}
+	setSettlementStatus(transaction, status, userID, now, req.Notes)
+
+	if err := ts.saveSettlement(transaction, before, group, db.RevisionStatus); err != nil {
+		return nil, err
+	}
+
+	go ts.sendSettlementStatusNotifications(transaction, group, userID)
+
+	return transaction, nil
+}
+
+// RecordSettlementPayment records an installment paid towards a settlement. A payment the
+// payee records is confirmed straight away; one the payer records waits for the payee to
+// confirm it. Balances move by each confirmed payment, and the settlement is confirmed
+// once it has been paid in full.
+func (ts *TransactionService) RecordSettlementPayment(transactionID, userID primitive.ObjectID, req models.RecordSettlementPaymentRequest) (*db.Transaction, error) {
+	transaction, err := findSettlement(transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	payerID, payeeID := settlementParties(transaction)
+	if userID != payerID && userID != payeeID {
+		return nil, errors.New("only the payer or payee can record a payment")
+	}
+	if len(settlementTransitions[transaction.Status]) == 0 {
+		return nil, fmt.Errorf("payments cannot be recorded against a %s settlement", transaction.Status)
+	}
+
+	// Payments waiting for confirmation count too, so the total can never go over
+	remaining := transaction.Amount
+	for _, payment := range transaction.Payments {
+		remaining -= payment.Amount
+	}
+	if req.Amount > remaining {
+		return nil, fmt.Errorf("the payment is more than the %s left to pay", remaining.Format(transaction.Currency))
+	}
+
+	group, err := GetGroupById(transaction.GroupID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	before := snapshotTransaction(transaction)
+
+	now := time.Now()
+	payment := db.SettlementPayment{
+		ID:             primitive.NewObjectID(),
+		Amount:         req.Amount,
+		Date:           now,
+		Method:         req.Method,
+		ProofOfPayment: req.ProofOfPayment,
+		Notes:          req.Notes,
+		RecordedBy:     userID,
+		RecordedAt:     now,
+	}
+	if req.Date != nil {
+		payment.Date = *req.Date
+	}
+	transaction.Payments = append(transaction.Payments, payment)
+	if userID == payeeID {
+		confirmSettlementPayment(transaction, len(transaction.Payments)-1, userID, now)
+	}
+
+	if err := ts.saveSettlement(transaction, before, group, db.RevisionPayment); err != nil {
+		return nil, err
+	}
+
+	go ts.sendSettlementPaymentNotifications(transaction, &transaction.Payments[len(transaction.Payments)-1], group, userID)
+
+	return transaction, nil
+}
+
+// ConfirmSettlementPayment confirms, as the payee, that a payment recorded by the payer
+// has arrived
+func (ts *TransactionService) ConfirmSettlementPayment(transactionID, paymentID, userID primitive.ObjectID) (*db.Transaction, error) {
+	transaction, err := findSettlement(transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, payeeID := settlementParties(transaction)
+	if userID != payeeID {
+		return nil, errors.New("only the payee can confirm a payment")
+	}
+	if len(settlementTransitions[transaction.Status]) == 0 {
+		return nil, fmt.Errorf("payments cannot be confirmed on a %s settlement", transaction.Status)
+	}
+
+	index := -1
+	for i, payment := range transaction.Payments {
+		if payment.ID == paymentID {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return nil, errors.New("payment not found")
+	}
+	if transaction.Payments[index].ConfirmedAt != nil {
+		return nil, errors.New("payment is already confirmed")
+	}
+
+	group, err := GetGroupById(transaction.GroupID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	before := snapshotTransaction(transaction)
+	confirmSettlementPayment(transaction, index, userID, time.Now())
+
+	if err := ts.saveSettlement(transaction, before, group, db.RevisionPayment); err != nil {
+		return nil, err
+	}
+
+	go ts.sendSettlementPaymentNotifications(transaction, &transaction.Payments[index], group, userID)
+
+	return transaction, nil
+}
+
+// setSettlementStatus moves a settlement into a state and records who did it
+func setSettlementStatus(transaction *db.Transaction, status db.SettlementStatus, userID primitive.ObjectID, now time.Time, notes string) {
+	transaction.Status = status
+	transaction.StatusHistory = append(transaction.StatusHistory, db.SettlementStatusChange{
+		Status:    status,
+		ChangedBy: userID,
+		ChangedAt: now,
+		Notes:     notes,
+	})
+	transaction.UpdatedAt = now
+	transaction.UpdatedBy = userID
+
+	if status == db.SettlementConfirmed {
+		transaction.IsCompleted = true
+		transaction.SettledAt = &now
+		transaction.AmountPaid = transaction.Amount
+		transaction.Outstanding = 0
+	}
+}
+
+// confirmSettlementPayment marks a payment as received. Its group currency amount is its
+// share of the settlement's converted amount, worked out on the running total so the
+// payments add up exactly to the settlement once it is paid in full.
+func confirmSettlementPayment(transaction *db.Transaction, index int, userID primitive.ObjectID, now time.Time) {
+	payment := &transaction.Payments[index]
+	paidBefore := transaction.AmountPaid
+
+	transaction.AmountPaid += payment.Amount
+	transaction.Outstanding = transaction.Amount - transaction.AmountPaid
+	transaction.UpdatedAt = now
+	transaction.UpdatedBy = userID
+
+	payment.ConfirmedAt = &now
+	payment.ConvertedAmount = convertedPart(transaction, transaction.AmountPaid) - convertedPart(transaction, paidBefore)
+
+	if transaction.Outstanding <= 0 {
+		setSettlementStatus(transaction, db.SettlementConfirmed, userID, now, "Paid in full")
+	}
+}
+
+// convertedPart is the group currency value of part of a settlement's amount
+func convertedPart(transaction *db.Transaction, part db.Money) db.Money {
+	if part == transaction.Amount {
+		return transaction.ConvertedAmount
+	}
+	return db.Money(math.Round(float64(transaction.ConvertedAmount) * float64(part) / float64(transaction.Amount)))
+}
+
+// saveSettlement stores a settlement's state and payments, moves balances by however
+// much more of it is settled than before and records the change as a revision. The update
+// only goes through if nobody else changed the settlement since it was loaded, so a
+// payment is never applied twice.
+func (ts *TransactionService) saveSettlement(transaction, before *db.Transaction, group *db.Group, action db.RevisionAction) error {
+	// Completing the settlement is worth telling apart from whatever led to it
+	if before.Status != db.SettlementConfirmed && transaction.Status == db.SettlementConfirmed {
+		action = db.RevisionComplete
+	}
+
+	updateDoc := bson.M{
+		"status":         transaction.Status,
+		"status_history": transaction.StatusHistory,
+		"payments":       transaction.Payments,
+		"amount_paid":    transaction.AmountPaid,
+		"outstanding":    transaction.Outstanding,
+		"is_completed":   transaction.IsCompleted,
+		"updated_at":     transaction.UpdatedAt,
+		"updated_by":     transaction.UpdatedBy,
+	}
+	if transaction.SettledAt != nil {
+		updateDoc["settled_at"] = transaction.SettledAt
+	}
+	if transaction.SettlementMethod != "" {
+		updateDoc["settlement_method"] = transaction.SettlementMethod
+	}
+	if transaction.ProofOfPayment != "" {
+		updateDoc["proof_of_payment"] = transaction.ProofOfPayment
+	}
+
+	return runInTransaction(func(sc mongo.SessionContext) error {
+		result, err := mgm.Coll(transaction).UpdateOne(sc, bson.M{
+			"_id":        transaction.ID,
+			"updated_at": before.UpdatedAt,
+		}, bson.M{"$set": updateDoc})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return errors.New("the settlement was changed by someone else, reload and try again")
+		}
+
+		for _, effect := range settlementEffects(transaction, settledAmount(transaction)-settledAmount(before)) {
+			entry := db.NewLedgerEntry(transaction.GroupID, effect.UserID, effect.UserName, transaction.ID, transaction.Type, effect.Paid, effect.Owed, group.Currency)
+			if err := recordLedgerEntry(sc, entry); err != nil {
+				return err
+			}
+		}
+
+		return recordRevision(sc, action, before, transaction, transaction.UpdatedBy)
+	})
+}
+
+// sendSettlementStatusNotifications tells the other party of a settlement about a change to its state
+func (ts *TransactionService) sendSettlementStatusNotifications(transaction *db.Transaction, group *db.Group, actorID primitive.ObjectID) {
+	actorName := "Someone"
+	if actor, err := FindUserById(actorID); err == nil {
+		actorName = actor.Name
+	}
+
+	amount := transaction.Amount.Format(transaction.Currency)
+	var title, body string
+	switch transaction.Status {
+	case db.SettlementPaid:
+		title = "Settlement Paid"
+		body = fmt.Sprintf("%s says they paid %s in %s, please confirm", actorName, amount, group.Name)
+	case db.SettlementConfirmed:
+		title = "Settlement Confirmed"
+		body = fmt.Sprintf("%s confirmed receiving %s in %s", actorName, amount, group.Name)
+	case db.SettlementDisputed:
+		title = "Settlement Disputed"
+		body = fmt.Sprintf("%s has not received %s in %s", actorName, amount, group.Name)
+	case db.SettlementCancelled:
+		title = "Settlement Cancelled"
+		body = fmt.Sprintf("%s cancelled the settlement of %s in %s", actorName, amount, group.Name)
+	default:
+		return
+	}
+
+	data := map[string]interface{}{
+		"type":           "settlement_status",
+		"transaction_id": transaction.ID.Hex(),
+		"group_id":       group.ID.Hex(),
+		"status":         transaction.Status,
+		"changed_by":     actorID.Hex(),
+	}
+
+	payerID, payeeID := settlementParties(transaction)
+	for _, recipientID := range []primitive.ObjectID{payerID, payeeID} {
+		if recipientID != actorID {
+			NotifyUser(recipientID, title, body, data)
+		}
+	}
+}
+
+// sendSettlementPaymentNotifications tells the other party of a settlement about a payment.
+// When the payment paid the settlement off, the other party hears that instead.
+func (ts *TransactionService) sendSettlementPaymentNotifications(transaction *db.Transaction, payment *db.SettlementPayment, group *db.Group, actorID primitive.ObjectID) {
+	if transaction.Status == db.SettlementConfirmed {
+		ts.sendSettlementStatusNotifications(transaction, group, actorID)
+		return
+	}
+
+	actorName := "Someone"
+	if actor, err := FindUserById(actorID); err == nil {
+		actorName = actor.Name
+	}
+
+	amount := payment.Amount.Format(transaction.Currency)
+	outstanding := transaction.Outstanding.Format(transaction.Currency)
+
+	title := "Payment Confirmed"
+	body := fmt.Sprintf("%s confirmed receiving %s in %s, %s left to pay", actorName, amount, group.Name, outstanding)
+	if payment.ConfirmedAt == nil {
+		title = "Payment Recorded"
+		body = fmt.Sprintf("%s says they paid %s in %s, please confirm", actorName, amount, group.Name)
+	}
+
+	data := map[string]interface{}{
+		"type":           "settlement_payment",
+		"transaction_id": transaction.ID.Hex(),
+		"payment_id":     payment.ID.Hex(),
+		"group_id":       group.ID.Hex(),
+		"changed_by":     actorID.Hex(),
+	}
+
+	payerID, payeeID := settlementParties(transaction)
+	for _, recipientID := range []primitive.ObjectID{payerID, payeeID} {
+		if recipientID != actorID {
+			NotifyUser(recipientID, title, body, data)
+		}
+	}
+}
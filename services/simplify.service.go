@@ -0,0 +1,279 @@
+package services
+
+import (
+	"errors"
+	"sort"
+
+	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
+	"github.com/kamva/mgm/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// SimplifyGreedy repeatedly settles the largest debtor against the largest creditor
+	SimplifyGreedy = "greedy"
+	// SimplifyMinimal finds the fewest possible transfers
+	SimplifyMinimal = "minimal"
+	// SimplifyPairwiseOnly only suggests transfers between members who owe each other
+	SimplifyPairwiseOnly = "pairwise-only"
+
+	// minimalMaxMembers caps the members with a balance the minimal strategy searches
+	// over; the search is exponential, so larger groups are settled greedily
+	minimalMaxMembers = 16
+)
+
+// netBalance is a member's net position: positive is owed money, negative owes money
+type netBalance struct {
+	UserID primitive.ObjectID
+	Amount db.Money
+}
+
+// transfer is a suggested payment from one member to another
+type transfer struct {
+	From   primitive.ObjectID
+	To     primitive.ObjectID
+	Amount db.Money
+}
+
+// SimplificationStrategy turns a group's balances into the transfers that settle them
+type SimplificationStrategy interface {
+	Simplify(groupID primitive.ObjectID, balances []netBalance) ([]transfer, error)
+}
+
+// SimplificationStrategies are the strategies available on /groups/:id/simplify
+var SimplificationStrategies = map[string]SimplificationStrategy{
+	SimplifyGreedy:       greedyStrategy{},
+	SimplifyMinimal:      minimalStrategy{},
+	SimplifyPairwiseOnly: pairwiseStrategy{},
+}
+
+// simplificationStrategy looks up a strategy by name, defaulting to greedy
+func simplificationStrategy(name string) (SimplificationStrategy, error) {
+	if name == "" {
+		name = SimplifyGreedy
+	}
+	strategy, ok := SimplificationStrategies[name]
+	if !ok {
+		return nil, errors.New("unknown simplification strategy, use greedy, minimal or pairwise-only")
+	}
+	return strategy, nil
+}
+
+// greedyStrategy settles the largest debtor against the largest creditor until everyone
+// is settled. Ties go to the lower user ID, so the same balances always give the same
+// suggestions.
+type greedyStrategy struct{}
+
+func (greedyStrategy) Simplify(groupID primitive.ObjectID, balances []netBalance) ([]transfer, error) {
+	return settleGreedy(balances), nil
+}
+
+func settleGreedy(balances []netBalance) []transfer {
+	remaining := make([]netBalance, 0, len(balances))
+	for _, balance := range balances {
+		if balance.Amount != 0 {
+			remaining = append(remaining, balance)
+		}
+	}
+
+	var transfers []transfer
+	for {
+		creditor, debtor := -1, -1
+		for i, balance := range remaining {
+			if balance.Amount > 0 && (creditor < 0 || largerBalance(balance.Amount, balance.UserID, remaining[creditor].Amount, remaining[creditor].UserID)) {
+				creditor = i
+			}
+			if balance.Amount < 0 && (debtor < 0 || largerBalance(-balance.Amount, balance.UserID, -remaining[debtor].Amount, remaining[debtor].UserID)) {
+				debtor = i
+			}
+		}
+		if creditor < 0 || debtor < 0 {
+			return transfers
+		}
+
+		amount := remaining[creditor].Amount
+		if -remaining[debtor].Amount < amount {
+			amount = -remaining[debtor].Amount
+		}
+
+		transfers = append(transfers, transfer{From: remaining[debtor].UserID, To: remaining[creditor].UserID, Amount: amount})
+		remaining[debtor].Amount += amount
+		remaining[creditor].Amount -= amount
+	}
+}
+
+// largerBalance orders balances by amount, then by user ID for ties
+func largerBalance(amount db.Money, userID primitive.ObjectID, otherAmount db.Money, otherUserID primitive.ObjectID) bool {
+	if amount != otherAmount {
+		return amount > otherAmount
+	}
+	return userID.Hex() < otherUserID.Hex()
+}
+
+// minimalStrategy finds the fewest transfers. Members that settle among themselves form
+// a zero-sum subset, and a subset of k members needs k-1 transfers, so the fewest
+// transfers come from splitting the group into as many zero-sum subsets as possible.
+// Each subset is then settled greedily.
+type minimalStrategy struct{}
+
+func (minimalStrategy) Simplify(groupID primitive.ObjectID, balances []netBalance) ([]transfer, error) {
+	var members []netBalance
+	for _, balance := range balances {
+		if balance.Amount != 0 {
+			members = append(members, balance)
+		}
+	}
+	if len(members) > minimalMaxMembers {
+		return settleGreedy(members), nil
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].UserID.Hex() < members[j].UserID.Hex() })
+
+	// subsets[mask] is the most zero-sum subsets the members in mask can be split into.
+	// Removing members one at a time, every prefix that sums to zero closes a subset.
+	full := 1<<len(members) - 1
+	sums := make([]db.Money, full+1)
+	subsets := make([]int8, full+1)
+	for mask := 1; mask <= full; mask++ {
+		best := int8(-1)
+		for i := range members {
+			if mask&(1<<i) == 0 {
+				continue
+			}
+			rest := mask &^ (1 << i)
+			sums[mask] = sums[rest] + members[i].Amount
+			if subsets[rest] > best {
+				best = subsets[rest]
+			}
+		}
+		if sums[mask] == 0 {
+			best++
+		}
+		subsets[mask] = best
+	}
+
+	// Walk back through the choices; consecutive zero-sum masks differ by one subset
+	var transfers []transfer
+	mask, closed := full, full
+	for mask != 0 {
+		for i := range members {
+			if mask&(1<<i) == 0 {
+				continue
+			}
+			rest := mask &^ (1 << i)
+			want := subsets[mask]
+			if sums[mask] == 0 {
+				want--
+			}
+			if subsets[rest] == want {
+				mask = rest
+				break
+			}
+		}
+
+		if sums[mask] == 0 {
+			var subset []netBalance
+			for i := range members {
+				if (closed&^mask)&(1<<i) != 0 {
+					subset = append(subset, members[i])
+				}
+			}
+			transfers = append(transfers, settleGreedy(subset)...)
+			closed = mask
+		}
+	}
+
+	return transfers, nil
+}
+
+// pairwiseStrategy never introduces a transfer between members who do not already owe
+// each other. Every transaction in the ledger is broken down into who owes whom, the
+// debts between each pair are netted, and each remaining debt is paid directly.
+type pairwiseStrategy struct{}
+
+func (pairwiseStrategy) Simplify(groupID primitive.ObjectID, balances []netBalance) ([]transfer, error) {
+	debts, err := pairwiseDebts(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	var transfers []transfer
+	for pair, amount := range debts {
+		switch {
+		case amount > 0:
+			transfers = append(transfers, transfer{From: pair.A, To: pair.B, Amount: amount})
+		case amount < 0:
+			transfers = append(transfers, transfer{From: pair.B, To: pair.A, Amount: -amount})
+		}
+	}
+
+	sort.Slice(transfers, func(i, j int) bool {
+		if transfers[i].Amount != transfers[j].Amount {
+			return transfers[i].Amount > transfers[j].Amount
+		}
+		if transfers[i].From != transfers[j].From {
+			return transfers[i].From.Hex() < transfers[j].From.Hex()
+		}
+		return transfers[i].To.Hex() < transfers[j].To.Hex()
+	})
+
+	return transfers, nil
+}
+
+// memberPair is an unordered pair of members, stored with the lower user ID first
+type memberPair struct {
+	A primitive.ObjectID
+	B primitive.ObjectID
+}
+
+// ledgerNet is a member's net ledger total for one transaction
+type ledgerNet struct {
+	Key struct {
+		TransactionID primitive.ObjectID `bson:"transaction_id"`
+		UserID        primitive.ObjectID `bson:"user_id"`
+	} `bson:"_id"`
+	Net db.Money `bson:"net"`
+}
+
+// pairwiseDebts works out who owes whom in a group. Each transaction's net effect is
+// settled greedily on its own, e.g. the people sharing an expense owe its payers, and the
+// resulting debts are netted per pair. A positive amount means A owes B.
+func pairwiseDebts(groupID primitive.ObjectID) (map[memberPair]db.Money, error) {
+	cursor, err := mgm.Coll(&db.LedgerEntry{}).Aggregate(mgm.Ctx(), mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"group_id": groupID}}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{"transaction_id": "$transaction_id", "user_id": "$user_id"},
+			"net": bson.M{"$sum": bson.M{"$subtract": bson.A{"$paid_delta", "$owed_delta"}}},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var nets []ledgerNet
+	if err := cursor.All(mgm.Ctx(), &nets); err != nil {
+		return nil, err
+	}
+
+	// Entries without a transaction, like opening balances, are settled together
+	byTransaction := make(map[primitive.ObjectID][]netBalance)
+	for _, net := range nets {
+		if net.Net != 0 {
+			byTransaction[net.Key.TransactionID] = append(byTransaction[net.Key.TransactionID], netBalance{UserID: net.Key.UserID, Amount: net.Net})
+		}
+	}
+
+	debts := make(map[memberPair]db.Money)
+	for _, balances := range byTransaction {
+		for _, t := range settleGreedy(balances) {
+			if t.From.Hex() < t.To.Hex() {
+				debts[memberPair{A: t.From, B: t.To}] += t.Amount
+			} else {
+				debts[memberPair{A: t.To, B: t.From}] -= t.Amount
+			}
+		}
+	}
+
+	return debts, nil
+}
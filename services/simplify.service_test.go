@@ -0,0 +1,110 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// testUserID is a fixed user ID, so tests that depend on ID order are deterministic
+func testUserID(n int) primitive.ObjectID {
+	id, _ := primitive.ObjectIDFromHex(fmt.Sprintf("%024x", n))
+	return id
+}
+
+func testBalances(amounts ...db.Money) []netBalance {
+	balances := make([]netBalance, len(amounts))
+	for i, amount := range amounts {
+		balances[i] = netBalance{UserID: testUserID(i + 1), Amount: amount}
+	}
+	return balances
+}
+
+func TestMinimalStrategy(t *testing.T) {
+	tests := []struct {
+		name      string
+		balances  []netBalance
+		transfers int
+	}{
+		{"nobody owes anything", testBalances(0, 0), 0},
+		{"one debtor", testBalances(-30, 10, 20), 2},
+		{"two separate pairs", testBalances(10, -10, 20, -20), 2},
+		{"fewer transfers than greedy", testBalances(6, 4, -4, -3, -3), 3},
+		{"no zero-sum subsets", testBalances(5, 2, -4, -3), 3},
+		{"three pairs", testBalances(1, 2, 3, -1, -2, -3), 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transfers, err := minimalStrategy{}.Simplify(primitive.NilObjectID, tt.balances)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(transfers) != tt.transfers {
+				t.Fatalf("got %d transfers, want %d: %v", len(transfers), tt.transfers, transfers)
+			}
+
+			remaining := make(map[primitive.ObjectID]db.Money)
+			for _, balance := range tt.balances {
+				remaining[balance.UserID] = balance.Amount
+			}
+			for _, transfer := range transfers {
+				if transfer.Amount <= 0 {
+					t.Fatalf("transfer of %d from %s to %s", transfer.Amount, transfer.From.Hex(), transfer.To.Hex())
+				}
+				remaining[transfer.From] += transfer.Amount
+				remaining[transfer.To] -= transfer.Amount
+			}
+			for userID, amount := range remaining {
+				if amount != 0 {
+					t.Errorf("%s is left with %d", userID.Hex(), amount)
+				}
+			}
+		})
+	}
+}
+
+func TestMinimalStrategyIsDeterministic(t *testing.T) {
+	balances := testBalances(-30, 10, 20)
+	want := []transfer{
+		{From: testUserID(1), To: testUserID(3), Amount: 20},
+		{From: testUserID(1), To: testUserID(2), Amount: 10},
+	}
+
+	reversed := []netBalance{balances[2], balances[1], balances[0]}
+	for _, input := range [][]netBalance{balances, reversed} {
+		transfers, err := minimalStrategy{}.Simplify(primitive.NilObjectID, input)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(transfers, want) {
+			t.Fatalf("transfers = %v, want %v", transfers, want)
+		}
+	}
+}
+
+func TestMinimalStrategyFallsBackToGreedy(t *testing.T) {
+	var amounts []db.Money
+	for i := 0; i <= minimalMaxMembers/5; i++ {
+		amounts = append(amounts, 6, 4, -4, -3, -3)
+	}
+	balances := testBalances(amounts...)
+
+	transfers, err := minimalStrategy{}.Simplify(primitive.NilObjectID, balances)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := settleGreedy(balances); !reflect.DeepEqual(transfers, want) {
+		t.Fatalf("transfers = %v, want the greedy %v", transfers, want)
+	}
+}
+
+func TestSettleGreedy(t *testing.T) {
+	transfers := settleGreedy(testBalances(6, 4, -4, -3, -3))
+	if len(transfers) != 4 {
+		t.Fatalf("got %d transfers, want 4: %v", len(transfers), transfers)
+	}
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models"
+	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// percentageScale turns percentages into integer weights (two decimal places, 100% = 10000)
+const percentageScale = 100
+
+// ComputeSplitAmounts derives how much each split participant owes from the split type.
+// The returned amounts are in the same order as splits and always add up to total.
+//
+//   - exact: the client-supplied amounts are used as-is and must add up to total
+//   - equal: only user IDs are needed, total is divided evenly
+//   - percentage: percentages (up to two decimals) must add up to 100
+//   - shares: positive integer weights, e.g. 2 shares pays twice as much as 1
+//   - itemized: exact amounts derived from receipt items by ComputeItemizedSplits
+//
+// Leftover minor units are handed out by largest remainder with ties broken by
+// user ID, so the result does not depend on the order the client sent the splits in.
+func ComputeSplitAmounts(total db.Money, splitType db.SplitType, splits []models.TransactionSplitRequest) ([]db.Money, error) {
+	if len(splits) == 0 {
+		return nil, errors.New("at least one split is required")
+	}
+
+	seen := make(map[string]bool)
+	for _, split := range splits {
+		if seen[split.UserID] {
+			return nil, errors.New("each user can only appear once in splits")
+		}
+		seen[split.UserID] = true
+	}
+
+	weights := make([]int64, len(splits))
+
+	switch splitType {
+	case db.SplitTypeExact, db.SplitTypeItemized:
+		amounts := make([]db.Money, len(splits))
+		var totalSplit db.Money
+		for i, split := range splits {
+			amounts[i] = split.Amount
+			totalSplit += split.Amount
+		}
+		if totalSplit != total {
+			return nil, errors.New("total split amount must equal transaction amount")
+		}
+		return amounts, nil
+
+	case db.SplitTypeEqual:
+		for i := range splits {
+			weights[i] = 1
+		}
+
+	case db.SplitTypePercentage:
+		var totalWeight int64
+		for i, split := range splits {
+			if split.Percentage <= 0 {
+				return nil, errors.New("every percentage split must be greater than 0")
+			}
+			weights[i] = int64(math.Round(split.Percentage * percentageScale))
+			totalWeight += weights[i]
+		}
+		if totalWeight != 100*percentageScale {
+			return nil, errors.New("split percentages must add up to 100")
+		}
+
+	case db.SplitTypeShares:
+		for i, split := range splits {
+			if split.Shares <= 0 {
+				return nil, errors.New("every shares split must have at least 1 share")
+			}
+			weights[i] = split.Shares
+		}
+
+	default:
+		return nil, errors.New("unsupported split type")
+	}
+
+	// Allocate in user ID order so leftover cents always land on the same people
+	order := make([]int, len(splits))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return splits[order[a]].UserID < splits[order[b]].UserID
+	})
+
+	orderedWeights := make([]int64, len(order))
+	for i, index := range order {
+		orderedWeights[i] = weights[index]
+	}
+
+	amounts := make([]db.Money, len(splits))
+	for i, part := range total.Allocate(orderedWeights) {
+		amounts[order[i]] = part
+	}
+
+	return amounts, nil
+}
+
+// ComputeItemizedSplits derives the splits of an itemized expense from its receipt lines.
+// Every item is shared equally by its users. Tax, tip and service charge lines are then
+// spread over everyone in proportion to their item subtotal, so whoever ordered more
+// also pays more of the tip. All lines must add up to total. The returned splits carry
+// exact amounts, ordered by user ID.
+func ComputeItemizedSplits(total db.Money, itemRequests []models.TransactionItemRequest) ([]db.TransactionItem, []models.TransactionSplitRequest, error) {
+	if len(itemRequests) == 0 {
+		return nil, nil, errors.New("items are required for an itemized split")
+	}
+
+	items := make([]db.TransactionItem, 0, len(itemRequests))
+	subtotals := make(map[string]db.Money)
+	var itemTotal, chargeTotal db.Money
+
+	for _, itemRequest := range itemRequests {
+		item := db.TransactionItem{
+			Name:  itemRequest.Name,
+			Type:  db.ItemType(itemRequest.Type),
+			Price: itemRequest.Price,
+		}
+		if item.Type == "" {
+			item.Type = db.ItemTypeItem
+		}
+
+		if item.Type != db.ItemTypeItem {
+			if len(itemRequest.UserIDs) > 0 {
+				return nil, nil, errors.New("tax, tip and service charge lines are shared by everyone and cannot have users")
+			}
+			chargeTotal += item.Price
+			items = append(items, item)
+			continue
+		}
+
+		if len(itemRequest.UserIDs) == 0 {
+			return nil, nil, errors.New("every item needs at least one user")
+		}
+
+		// Sorted so leftover minor units always land on the same people
+		userIDs := append([]string(nil), itemRequest.UserIDs...)
+		sort.Strings(userIDs)
+
+		weights := make([]int64, len(userIDs))
+		for i, userID := range userIDs {
+			if i > 0 && userIDs[i-1] == userID {
+				return nil, nil, errors.New("each user can only appear once per item")
+			}
+			objectID, err := primitive.ObjectIDFromHex(userID)
+			if err != nil {
+				return nil, nil, errors.New("invalid item user ID")
+			}
+			item.UserIDs = append(item.UserIDs, objectID)
+			weights[i] = 1
+		}
+
+		for i, part := range item.Price.Allocate(weights) {
+			subtotals[userIDs[i]] += part
+		}
+		itemTotal += item.Price
+		items = append(items, item)
+	}
+
+	if itemTotal+chargeTotal != total {
+		return nil, nil, errors.New("items must add up to the transaction amount")
+	}
+	if chargeTotal > 0 && itemTotal == 0 {
+		return nil, nil, errors.New("tax, tip and service charges need priced items to be spread over")
+	}
+
+	userIDs := make([]string, 0, len(subtotals))
+	for userID := range subtotals {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Strings(userIDs)
+
+	weights := make([]int64, len(userIDs))
+	for i, userID := range userIDs {
+		weights[i] = int64(subtotals[userID])
+	}
+
+	charges := chargeTotal.Allocate(weights)
+	splits := make([]models.TransactionSplitRequest, len(userIDs))
+	for i, userID := range userIDs {
+		splits[i] = models.TransactionSplitRequest{
+			UserID: userID,
+			Amount: subtotals[userID] + charges[i],
+		}
+	}
+
+	return items, splits, nil
+}
+
+// itemRequests turns stored receipt lines back into requests, to re-derive splits on edit
+func itemRequests(items []db.TransactionItem) []models.TransactionItemRequest {
+	requests := make([]models.TransactionItemRequest, len(items))
+	for i, item := range items {
+		requests[i] = models.TransactionItemRequest{
+			Name:  item.Name,
+			Type:  string(item.Type),
+			Price: item.Price,
+		}
+		for _, userID := range item.UserIDs {
+			requests[i].UserIDs = append(requests[i].UserIDs, userID.Hex())
+		}
+	}
+	return requests
+}
@@ -0,0 +1,268 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models"
+	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
+)
+
+func TestComputeSplitAmounts(t *testing.T) {
+	tests := []struct {
+		name      string
+		total     db.Money
+		splitType db.SplitType
+		splits    []models.TransactionSplitRequest
+		want      []db.Money
+		wantErr   string
+	}{
+		{
+			name:      "equal",
+			total:     90,
+			splitType: db.SplitTypeEqual,
+			splits:    []models.TransactionSplitRequest{{UserID: "a"}, {UserID: "b"}, {UserID: "c"}},
+			want:      []db.Money{30, 30, 30},
+		},
+		{
+			name:      "equal leftover goes by user ID, not request order",
+			total:     100,
+			splitType: db.SplitTypeEqual,
+			splits:    []models.TransactionSplitRequest{{UserID: "c"}, {UserID: "a"}, {UserID: "b"}},
+			want:      []db.Money{33, 34, 33},
+		},
+		{
+			name:      "exact",
+			total:     100,
+			splitType: db.SplitTypeExact,
+			splits:    []models.TransactionSplitRequest{{UserID: "a", Amount: 75}, {UserID: "b", Amount: 25}},
+			want:      []db.Money{75, 25},
+		},
+		{
+			name:      "exact must add up",
+			total:     100,
+			splitType: db.SplitTypeExact,
+			splits:    []models.TransactionSplitRequest{{UserID: "a", Amount: 75}, {UserID: "b", Amount: 24}},
+			wantErr:   "total split amount must equal transaction amount",
+		},
+		{
+			name:      "percentage",
+			total:     1001,
+			splitType: db.SplitTypePercentage,
+			splits: []models.TransactionSplitRequest{
+				{UserID: "a", Percentage: 33.33},
+				{UserID: "b", Percentage: 33.33},
+				{UserID: "c", Percentage: 33.34},
+			},
+			want: []db.Money{334, 333, 334},
+		},
+		{
+			name:      "percentages must add up to 100",
+			total:     100,
+			splitType: db.SplitTypePercentage,
+			splits:    []models.TransactionSplitRequest{{UserID: "a", Percentage: 50}, {UserID: "b", Percentage: 49.99}},
+			wantErr:   "split percentages must add up to 100",
+		},
+		{
+			name:      "percentage must be positive",
+			total:     100,
+			splitType: db.SplitTypePercentage,
+			splits:    []models.TransactionSplitRequest{{UserID: "a", Percentage: 100}, {UserID: "b"}},
+			wantErr:   "every percentage split must be greater than 0",
+		},
+		{
+			name:      "shares",
+			total:     100,
+			splitType: db.SplitTypeShares,
+			splits:    []models.TransactionSplitRequest{{UserID: "b", Shares: 1}, {UserID: "a", Shares: 2}},
+			want:      []db.Money{33, 67},
+		},
+		{
+			name:      "shares must be positive",
+			total:     100,
+			splitType: db.SplitTypeShares,
+			splits:    []models.TransactionSplitRequest{{UserID: "a", Shares: 1}, {UserID: "b"}},
+			wantErr:   "every shares split must have at least 1 share",
+		},
+		{
+			name:      "negative total",
+			total:     -100,
+			splitType: db.SplitTypeEqual,
+			splits:    []models.TransactionSplitRequest{{UserID: "a"}, {UserID: "b"}, {UserID: "c"}},
+			want:      []db.Money{-34, -33, -33},
+		},
+		{
+			name:      "no splits",
+			total:     100,
+			splitType: db.SplitTypeEqual,
+			wantErr:   "at least one split is required",
+		},
+		{
+			name:      "duplicate user",
+			total:     100,
+			splitType: db.SplitTypeEqual,
+			splits:    []models.TransactionSplitRequest{{UserID: "a"}, {UserID: "a"}},
+			wantErr:   "each user can only appear once in splits",
+		},
+		{
+			name:      "unknown split type",
+			total:     100,
+			splitType: "halves",
+			splits:    []models.TransactionSplitRequest{{UserID: "a"}},
+			wantErr:   "unsupported split type",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ComputeSplitAmounts(tt.total, tt.splitType, tt.splits)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("amounts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeItemizedSplits(t *testing.T) {
+	const (
+		alice = "000000000000000000000001"
+		bob   = "000000000000000000000002"
+		carol = "000000000000000000000003"
+	)
+
+	tests := []struct {
+		name    string
+		total   db.Money
+		items   []models.TransactionItemRequest
+		want    []models.TransactionSplitRequest
+		wantErr string
+	}{
+		{
+			name:  "items only",
+			total: 1500,
+			items: []models.TransactionItemRequest{
+				{Name: "Pizza", Price: 1000, UserIDs: []string{alice, bob}},
+				{Name: "Beer", Price: 500, UserIDs: []string{alice}},
+			},
+			want: []models.TransactionSplitRequest{{UserID: alice, Amount: 1000}, {UserID: bob, Amount: 500}},
+		},
+		{
+			name:  "tax is spread by item subtotal",
+			total: 1650,
+			items: []models.TransactionItemRequest{
+				{Name: "Pizza", Price: 1000, UserIDs: []string{alice, bob}},
+				{Name: "Beer", Price: 500, UserIDs: []string{alice}},
+				{Name: "Tax", Type: string(db.ItemTypeTax), Price: 150},
+			},
+			want: []models.TransactionSplitRequest{{UserID: alice, Amount: 1100}, {UserID: bob, Amount: 550}},
+		},
+		{
+			name:  "leftover minor units go by user ID",
+			total: 110,
+			items: []models.TransactionItemRequest{
+				{Name: "Nachos", Price: 100, UserIDs: []string{carol, alice, bob}},
+				{Name: "Tip", Type: string(db.ItemTypeTip), Price: 10},
+			},
+			want: []models.TransactionSplitRequest{
+				{UserID: alice, Amount: 38},
+				{UserID: bob, Amount: 36},
+				{UserID: carol, Amount: 36},
+			},
+		},
+		{
+			name:    "no items",
+			total:   100,
+			wantErr: "items are required for an itemized split",
+		},
+		{
+			name:  "items must add up",
+			total: 1000,
+			items: []models.TransactionItemRequest{
+				{Name: "Pizza", Price: 900, UserIDs: []string{alice}},
+			},
+			wantErr: "items must add up to the transaction amount",
+		},
+		{
+			name:  "charges cannot have users",
+			total: 110,
+			items: []models.TransactionItemRequest{
+				{Name: "Pizza", Price: 100, UserIDs: []string{alice}},
+				{Name: "Tip", Type: string(db.ItemTypeTip), Price: 10, UserIDs: []string{alice}},
+			},
+			wantErr: "tax, tip and service charge lines are shared by everyone and cannot have users",
+		},
+		{
+			name:  "item needs a user",
+			total: 100,
+			items: []models.TransactionItemRequest{
+				{Name: "Pizza", Price: 100},
+			},
+			wantErr: "every item needs at least one user",
+		},
+		{
+			name:  "user once per item",
+			total: 100,
+			items: []models.TransactionItemRequest{
+				{Name: "Pizza", Price: 100, UserIDs: []string{alice, alice}},
+			},
+			wantErr: "each user can only appear once per item",
+		},
+		{
+			name:  "invalid user ID",
+			total: 100,
+			items: []models.TransactionItemRequest{
+				{Name: "Pizza", Price: 100, UserIDs: []string{"alice"}},
+			},
+			wantErr: "invalid item user ID",
+		},
+		{
+			name:  "charges need priced items",
+			total: 10,
+			items: []models.TransactionItemRequest{
+				{Name: "Water", Price: 0, UserIDs: []string{alice}},
+				{Name: "Service", Type: string(db.ItemTypeServiceCharge), Price: 10},
+			},
+			wantErr: "tax, tip and service charges need priced items to be spread over",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, splits, err := ComputeItemizedSplits(tt.total, tt.items)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(splits, tt.want) {
+				t.Fatalf("splits = %v, want %v", splits, tt.want)
+			}
+
+			if len(items) != len(tt.items) {
+				t.Fatalf("got %d items, want %d", len(items), len(tt.items))
+			}
+			for i, item := range items {
+				want := db.ItemType(tt.items[i].Type)
+				if want == "" {
+					want = db.ItemTypeItem
+				}
+				if item.Type != want {
+					t.Errorf("item %d has type %q, want %q", i, item.Type, want)
+				}
+			}
+		})
+	}
+}
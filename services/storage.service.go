@@ -8,6 +8,7 @@ import (
 	"github.com/go-redis/redis/v8"
 	"github.com/kamva/mgm/v3"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"log"
 	"sync"
@@ -24,6 +25,27 @@ func InitMongoDB() {
 	log.Println("Connected to MongoDB!")
 }
 
+// runInTransaction runs fn inside a MongoDB transaction, so everything it writes through
+// sc is committed together or not at all. Transactions need a replica set; the driver
+// retries fn on transient errors, so it must be safe to run more than once.
+func runInTransaction(fn func(sc mongo.SessionContext) error) error {
+	_, client, _, err := mgm.DefaultConfigs()
+	if err != nil {
+		return err
+	}
+
+	session, err := client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.Background())
+
+	_, err = session.WithTransaction(context.Background(), func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
 var redisDefaultClient *redis.Client
 var redisDefaultOnce sync.Once
 
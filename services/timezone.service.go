@@ -0,0 +1,102 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxFutureTransactionDate is how far ahead of now a transaction can be dated. A day
+// covers clients in timezones that are already on the next date.
+const maxFutureTransactionDate = 24 * time.Hour
+
+// resolveLocation returns the first of the named IANA timezones that exists, and its
+// name, or UTC if none does
+func resolveLocation(names ...string) (*time.Location, string) {
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc, name
+		}
+	}
+	return time.UTC, "UTC"
+}
+
+// setTransactionDate dates a transaction from the date and timezone a client sent. The
+// timezone defaults to the user's, then the group's, and is kept on the transaction so
+// its day is known wherever it is viewed from. A timezone that does not exist is
+// rejected rather than replaced.
+func setTransactionDate(transaction *db.Transaction, date, timezone string, userID primitive.ObjectID, group *db.Group) error {
+	if timezone != "" {
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return errors.New("invalid timezone")
+		}
+	} else {
+		if user, err := FindUserById(userID); err == nil {
+			timezone = user.Timezone
+		}
+	}
+	loc, name := resolveLocation(timezone, group.Timezone)
+
+	if date != "" {
+		t, err := parseTransactionDate(date, loc, time.Now())
+		if err != nil {
+			return err
+		}
+		transaction.Date = t
+	}
+	transaction.Timezone = name
+	return nil
+}
+
+// parseTransactionDate reads a date sent by a client: an RFC 3339 timestamp, or a day
+// (YYYY-MM-DD) in loc. A past day is taken at noon, so it stays the same day when viewed
+// from nearby timezones; today is taken as now.
+func parseTransactionDate(date string, loc *time.Location, now time.Time) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		day, dayErr := time.ParseInLocation("2006-01-02", date, loc)
+		if dayErr != nil {
+			return time.Time{}, errors.New("date must be a day (YYYY-MM-DD) or an RFC 3339 timestamp")
+		}
+
+		y, m, d := day.Date()
+		if ny, nm, nd := now.In(loc).Date(); y == ny && m == nm && d == nd {
+			t = now
+		} else {
+			t = time.Date(y, m, d, 12, 0, 0, 0, loc)
+		}
+	}
+
+	if t.After(now.Add(maxFutureTransactionDate)) {
+		return time.Time{}, errors.New("date cannot be more than a day in the future")
+	}
+	return t, nil
+}
+
+// viewerLocation is the timezone days are counted in for a user looking at a group, or
+// at no group in particular when it is nil: the one asked for, else the user's, else the
+// group's, else UTC
+func viewerLocation(timezone string, userID primitive.ObjectID, group *db.Group) (*time.Location, string, error) {
+	if timezone != "" {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, "", errors.New("invalid timezone")
+		}
+		return loc, timezone, nil
+	}
+
+	userTimezone, groupTimezone := "", ""
+	if user, err := FindUserById(userID); err == nil {
+		userTimezone = user.Timezone
+	}
+	if group != nil {
+		groupTimezone = group.Timezone
+	}
+	loc, name := resolveLocation(userTimezone, groupTimezone)
+	return loc, name, nil
+}
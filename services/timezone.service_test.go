@@ -0,0 +1,130 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseTransactionDate(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*60*60)
+	now := time.Date(2024, time.March, 12, 15, 0, 0, 0, time.UTC) // Already March 13 in Tokyo
+
+	tests := []struct {
+		name    string
+		date    string
+		loc     *time.Location
+		want    time.Time
+		wantErr string
+	}{
+		{
+			name: "timestamp",
+			date: "2024-03-10T08:00:00Z",
+			loc:  tokyo,
+			want: time.Date(2024, time.March, 10, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "timestamp with an offset",
+			date: "2024-03-10T08:00:00+02:00",
+			loc:  time.UTC,
+			want: time.Date(2024, time.March, 10, 6, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "past day is taken at noon",
+			date: "2024-03-10",
+			loc:  time.UTC,
+			want: time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "past day is read in the location",
+			date: "2024-03-12",
+			loc:  tokyo,
+			want: time.Date(2024, time.March, 12, 12, 0, 0, 0, tokyo),
+		},
+		{
+			name: "today is taken as now",
+			date: "2024-03-12",
+			loc:  time.UTC,
+			want: now,
+		},
+		{
+			name: "today in the location is taken as now",
+			date: "2024-03-13",
+			loc:  tokyo,
+			want: now,
+		},
+		{
+			name: "tomorrow is allowed within a day",
+			date: "2024-03-13T14:00:00Z",
+			loc:  time.UTC,
+			want: time.Date(2024, time.March, 13, 14, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "more than a day ahead",
+			date:    "2024-03-13T16:00:00Z",
+			loc:     time.UTC,
+			wantErr: "date cannot be more than a day in the future",
+		},
+		{
+			name:    "day more than a day ahead",
+			date:    "2024-03-15",
+			loc:     time.UTC,
+			wantErr: "date cannot be more than a day in the future",
+		},
+		{
+			name:    "not a date",
+			date:    "12/03/2024",
+			loc:     time.UTC,
+			wantErr: "date must be a day (YYYY-MM-DD) or an RFC 3339 timestamp",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTransactionDate(tt.date, tt.loc, now)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Fatalf("date = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveLocation(t *testing.T) {
+	tests := []struct {
+		name  string
+		names []string
+		want  string
+	}{
+		{"first that exists", []string{"", "Not/AZone", "Europe/Berlin", "Asia/Tokyo"}, "Europe/Berlin"},
+		{"none exists", []string{"Not/AZone"}, "UTC"},
+		{"none given", nil, "UTC"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, name := resolveLocation(tt.names...)
+			if name != tt.want || loc.String() != tt.want {
+				t.Fatalf("resolveLocation(%q) = %s, %s, want %s", tt.names, loc, name, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetTransactionDateRejectsUnknownTimezone(t *testing.T) {
+	transaction := &db.Transaction{}
+	err := setTransactionDate(transaction, "2024-03-10", "Not/AZone", primitive.NilObjectID, &db.Group{Timezone: "Europe/Berlin"})
+	if err == nil || err.Error() != "invalid timezone" {
+		t.Fatalf("error = %v, want %q", err, "invalid timezone")
+	}
+}
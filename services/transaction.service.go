@@ -1,11 +1,11 @@
 package services
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"log"
-	"math"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models"
@@ -33,122 +33,161 @@ func (ts *TransactionService) CreateExpenseTransaction(userID primitive.ObjectID
 		return nil, err
 	}
 
-	// Create the transaction
-	transaction := db.NewExpenseTransaction(groupID, req.Description, req.Amount, req.Currency, userID, db.SplitType(req.SplitType), req.Category)
+	transaction, err := ts.buildExpenseTransaction(group, userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Execute transaction with balance updates atomically
+	transaction, err = ts.executeTransactionWithBalanceUpdate(transaction, group)
+	if err != nil {
+		return nil, err
+	}
+
+	// Paying for a rotation's category takes that turn
+	ts.recordRotationTurn(transaction, group)
+
+	return transaction, nil
+}
+
+// buildExpenseTransaction works out an expense from a request without storing it
+func (ts *TransactionService) buildExpenseTransaction(group *db.Group, userID primitive.ObjectID, req models.CreateExpenseTransactionRequest) (*db.Transaction, error) {
+	transaction := db.NewExpenseTransaction(group.ID, req.Description, req.Amount, req.Currency, userID, db.SplitType(req.SplitType), req.Category)
 	transaction.Notes = req.Notes
 	transaction.IsCompleted = req.IsCompleted
+	transaction.RecurringExpenseID = req.RecurringExpenseID
+	if err := setTransactionDate(transaction, req.Date, req.Timezone, userID, group); err != nil {
+		return nil, err
+	}
+	if req.OccurrenceDate != nil {
+		transaction.Date = *req.OccurrenceDate
+	}
+	if err := setTransactionCategory(transaction, req.Category, userID); err != nil {
+		return nil, err
+	}
+
+	// Balances are kept in the group currency
+	if err := setGroupCurrencyConversion(transaction, group, req.ExchangeRate); err != nil {
+		return nil, err
+	}
+
+	// Itemized expenses derive their splits from the receipt items
+	splitRequests := req.Splits
+	if transaction.SplitType == db.SplitTypeItemized || len(req.Items) > 0 {
+		if transaction.SplitType != db.SplitTypeItemized {
+			return nil, errors.New("items can only be used with an itemized split")
+		}
+		var err error
+		transaction.Items, splitRequests, err = ComputeItemizedSplits(transaction.Amount, req.Items)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	// Process payers and splits
-	if len(req.Payers) == 0 {
-		return nil, errors.New("at least one payer is required")
+	if err := ts.buildExpenseParties(transaction, req.Payers, splitRequests); err != nil {
+		return nil, err
 	}
-	if len(req.Splits) == 0 {
-		return nil, errors.New("at least one split is required")
+
+	return transaction, nil
+}
+
+// buildExpenseParties resolves payer and split requests into the payers, splits and net
+// participants of an expense, using the transaction's amount and split type. The
+// converted total must already be set; it is allocated over payers and splits in
+// proportion to their original amounts.
+func (ts *TransactionService) buildExpenseParties(transaction *db.Transaction, payerRequests []models.TransactionPayerRequest, splitRequests []models.TransactionSplitRequest) error {
+	if len(payerRequests) == 0 {
+		return errors.New("at least one payer is required")
+	}
+	if len(splitRequests) == 0 {
+		return errors.New("at least one split is required")
 	}
 
-	// Validate total amounts
-	var totalPaid, totalSplit float64
-	for _, payer := range req.Payers {
+	// Validate total paid (exact, amounts are integer minor units)
+	var totalPaid db.Money
+	for _, payer := range payerRequests {
 		totalPaid += payer.Amount
 	}
-	for _, split := range req.Splits {
-		totalSplit += split.Amount
+
+	if totalPaid != transaction.Amount {
+		return errors.New("total paid amount must equal transaction amount")
 	}
 
-	if math.Abs(totalPaid-req.Amount) > 0.01 {
-		return nil, errors.New("total paid amount must equal transaction amount")
+	// Split amounts are computed server-side from the split type
+	splitAmounts, err := ComputeSplitAmounts(transaction.Amount, transaction.SplitType, splitRequests)
+	if err != nil {
+		return err
 	}
-	if math.Abs(totalSplit-req.Amount) > 0.01 {
-		return nil, errors.New("total split amount must equal transaction amount")
+
+	transaction.Payers = nil
+	transaction.Splits = nil
+
+	payerAmounts := make([]db.Money, len(payerRequests))
+	for i, payer := range payerRequests {
+		payerAmounts[i] = payer.Amount
 	}
+	convertedPaid := allocateConverted(transaction.ConvertedAmount, payerAmounts)
+	convertedOwed := allocateConverted(transaction.ConvertedAmount, splitAmounts)
 
 	// Process payers
-	for _, payer := range req.Payers {
+	for i, payer := range payerRequests {
 		payerUserID, err := primitive.ObjectIDFromHex(payer.UserID)
 		if err != nil {
-			return nil, errors.New("invalid payer user ID")
+			return errors.New("invalid payer user ID")
 		}
 
 		// Get user name
 		user, err := FindUserById(payerUserID)
 		if err != nil {
-			return nil, errors.New("payer user not found")
+			return errors.New("payer user not found")
 		}
 
 		transaction.Payers = append(transaction.Payers, db.TransactionPayer{
-			UserID:   payerUserID,
-			UserName: user.Name,
-			Amount:   payer.Amount,
+			UserID:          payerUserID,
+			UserName:        user.Name,
+			Amount:          payer.Amount,
+			ConvertedAmount: convertedPaid[i],
 		})
 	}
 
 	// Process splits
-	for _, split := range req.Splits {
+	for i, split := range splitRequests {
 		splitUserID, err := primitive.ObjectIDFromHex(split.UserID)
 		if err != nil {
-			return nil, errors.New("invalid split user ID")
+			return errors.New("invalid split user ID")
 		}
 
 		// Get user name
 		user, err := FindUserById(splitUserID)
 		if err != nil {
-			return nil, errors.New("split user not found")
+			return errors.New("split user not found")
 		}
 
-		transaction.Splits = append(transaction.Splits, db.TransactionSplit{
-			UserID:   splitUserID,
-			UserName: user.Name,
-			Amount:   split.Amount,
-		})
-	}
-
-	// Calculate net participants (for balance updates)
-	participantMap := make(map[primitive.ObjectID]*db.TransactionParticipant)
-
-	// Add payers (positive amounts - they paid money)
-	for _, payer := range transaction.Payers {
-		if participant, exists := participantMap[payer.UserID]; exists {
-			participant.Amount += payer.Amount
-		} else {
-			participantMap[payer.UserID] = &db.TransactionParticipant{
-				UserID:    payer.UserID,
-				UserName:  payer.UserName,
-				Amount:    payer.Amount,
-				ShareType: "payer",
-			}
+		transactionSplit := db.TransactionSplit{
+			UserID:          splitUserID,
+			UserName:        user.Name,
+			Amount:          splitAmounts[i],
+			ConvertedAmount: convertedOwed[i],
 		}
-	}
-
-	// Subtract splits (negative amounts - they owe money)
-	for _, split := range transaction.Splits {
-		if participant, exists := participantMap[split.UserID]; exists {
-			participant.Amount -= split.Amount
-			if participant.ShareType == "payer" {
-				participant.ShareType = "both"
-			} else {
-				participant.ShareType = "split"
-			}
-		} else {
-			participantMap[split.UserID] = &db.TransactionParticipant{
-				UserID:    split.UserID,
-				UserName:  split.UserName,
-				Amount:    -split.Amount,
-				ShareType: "split",
-			}
+		switch transaction.SplitType {
+		case db.SplitTypePercentage:
+			transactionSplit.Percentage = split.Percentage
+		case db.SplitTypeShares:
+			transactionSplit.Shares = split.Shares
 		}
-	}
 
-	// Convert map to slice
-	for _, participant := range participantMap {
-		transaction.Participants = append(transaction.Participants, *participant)
+		transaction.Splits = append(transaction.Splits, transactionSplit)
 	}
 
-	// Execute transaction with balance updates atomically
-	return ts.executeTransactionWithBalanceUpdate(transaction, group)
+	transaction.ComputeParticipants()
+
+	return nil
 }
 
-// CreateSettlementTransaction creates a settlement between two users
-func (ts *TransactionService) CreateSettlementTransaction(groupID, payerID, payeeID primitive.ObjectID, amount float64, currency string, notes string, isCompleted bool, createdBy primitive.ObjectID) (*db.Transaction, error) {
+// CreateSettlementTransaction creates a settlement between two users, dated date in
+// timezone or now when date is empty
+func (ts *TransactionService) CreateSettlementTransaction(groupID, payerID, payeeID primitive.ObjectID, amount db.Money, currency string, exchangeRate float64, notes string, isCompleted bool, createdBy primitive.ObjectID, date, timezone string) (*db.Transaction, error) {
 	// Verify users are group members
 	group, err := GetGroupById(groupID, createdBy)
 	if err != nil {
@@ -166,116 +205,140 @@ func (ts *TransactionService) CreateSettlementTransaction(groupID, payerID, paye
 		return nil, errors.New("payee not found")
 	}
 
-	transaction := db.NewSettlementTransaction(groupID, payerID, payeeID, amount, currency)
+	transaction, err := buildSettlementTransaction(group, payer, payee, amount, currency, exchangeRate, notes, isCompleted, createdBy, date, timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	return ts.executeTransactionWithBalanceUpdate(transaction, group)
+}
+
+// buildSettlementTransaction prepares a settlement of amount from payer to payee in a group
+func buildSettlementTransaction(group *db.Group, payer, payee *db.User, amount db.Money, currency string, exchangeRate float64, notes string, isCompleted bool, createdBy primitive.ObjectID, date, timezone string) (*db.Transaction, error) {
+	payerID, payeeID := payer.ID, payee.ID
+
+	transaction := db.NewSettlementTransaction(group.ID, payerID, payeeID, amount, currency)
 	transaction.Notes = notes
-	transaction.IsCompleted = isCompleted
 	transaction.CreatedBy = createdBy
+	if err := setTransactionDate(transaction, date, timezone, createdBy, group); err != nil {
+		return nil, err
+	}
 
-	// Update participant names
-	for i := range transaction.Participants {
-		if transaction.Participants[i].UserID == payerID {
-			transaction.Participants[i].UserName = payer.Name
-		} else if transaction.Participants[i].UserID == payeeID {
-			transaction.Participants[i].UserName = payee.Name
-		}
+	// Only a settlement the payee has confirmed is completed and moves balances
+	now := time.Now()
+	transaction.Status = initialSettlementStatus(payeeID, isCompleted, createdBy)
+	transaction.StatusHistory = []db.SettlementStatusChange{{Status: transaction.Status, ChangedBy: createdBy, ChangedAt: now}}
+	if transaction.Status == db.SettlementConfirmed {
+		transaction.IsCompleted = true
+		transaction.SettledAt = &now
+		transaction.AmountPaid = transaction.Amount
+		transaction.Outstanding = 0
 	}
 
-	return ts.executeTransactionWithBalanceUpdate(transaction, group)
+	// Settlements can be paid in another currency; balances move by the converted amount
+	if err := setGroupCurrencyConversion(transaction, group, exchangeRate); err != nil {
+		return nil, err
+	}
+
+	// Name the payer and payee and give them the converted amount
+	transaction.Payers[0].UserName = payer.Name
+	transaction.Payers[0].ConvertedAmount = transaction.ConvertedAmount
+	transaction.Splits[0].UserName = payee.Name
+	transaction.Splits[0].ConvertedAmount = transaction.ConvertedAmount
+	transaction.ComputeParticipants()
+
+	return transaction, nil
 }
 
 // executeTransactionWithBalanceUpdate performs atomic transaction creation and balance updates
 func (ts *TransactionService) executeTransactionWithBalanceUpdate(transaction *db.Transaction, group *db.Group) (*db.Transaction, error) {
-	_, client, _, err := mgm.DefaultConfigs()
-	if err != nil {
-		return nil, err
-	}
-
-	session, err := client.StartSession()
+	err := runInTransaction(func(sc mongo.SessionContext) error {
+		return ts.insertTransaction(sc, transaction, group.Currency)
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer session.EndSession(context.Background())
-
-	var result *db.Transaction
 
-	err = mongo.WithSession(context.Background(), session, func(sc mongo.SessionContext) error {
-		// 1. Create the transaction
-		if err := mgm.Coll(transaction).Create(transaction); err != nil {
-			return err
-		}
+	// Send notifications in background after successful transaction
+	go ts.sendTransactionNotifications(transaction, group)
 
-		// 2. Update balances for expenses using payers and splits directly
-		if transaction.Type == db.TransactionTypeExpense {
-			// Update balances for payers (they paid money)
-			for _, payer := range transaction.Payers {
-				if err := ts.updateUserBalance(sc, transaction.GroupID, payer.UserID, payer.UserName, payer.Amount, 0, transaction.Type, transaction.ID, group.Currency); err != nil {
-					return err
-				}
-			}
+	return transaction, nil
+}
 
-			// Update balances for splits (they owe money)
-			for _, split := range transaction.Splits {
-				if err := ts.updateUserBalance(sc, transaction.GroupID, split.UserID, split.UserName, 0, split.Amount, transaction.Type, transaction.ID, group.Currency); err != nil {
-					return err
-				}
-			}
-		} else {
-			// For settlements, handle payer and payee correctly
-			for _, participant := range transaction.Participants {
-				if participant.Amount > 0 {
-					// They paid money (payer) - increase their TotalPaid
-					if err := ts.updateUserBalance(sc, transaction.GroupID, participant.UserID, participant.UserName, participant.Amount, 0, transaction.Type, transaction.ID, group.Currency); err != nil {
-						return err
-					}
-				} else {
-					// They received money (payee) - increase their TotalOwed to effectively reduce their debt
-					// Convert negative amount to positive for received amount
-					receivedAmount := -participant.Amount
-					if err := ts.updateUserBalance(sc, transaction.GroupID, participant.UserID, participant.UserName, 0, receivedAmount, transaction.Type, transaction.ID, group.Currency); err != nil {
-						return err
-					}
-				}
-			}
-		}
+// insertTransaction stores a new transaction, applies it to the balances of everyone
+// involved and records its first revision
+func (ts *TransactionService) insertTransaction(sc mongo.SessionContext, transaction *db.Transaction, currency string) error {
+	if err := mgm.Coll(transaction).CreateWithCtx(sc, transaction); err != nil {
+		return err
+	}
+	if err := ts.applyBalanceEffects(sc, transaction, currency, 1); err != nil {
+		return err
+	}
+	return recordRevision(sc, db.RevisionCreate, nil, transaction, transaction.CreatedBy)
+}
 
-		result = transaction
-		return nil
-	})
+// balanceEffect is what a single transaction adds to one member's paid and owed totals
+type balanceEffect struct {
+	UserID   primitive.ObjectID
+	UserName string
+	Paid     db.Money
+	Owed     db.Money
+}
 
-	// Send notifications in background after successful transaction
-	if err == nil {
-		go ts.sendTransactionNotifications(transaction, group)
+// balanceEffects returns the canonical effect of a transaction on group balances, in
+// the group currency, worked out from its payers and splits alone. Expenses and
+// adjustments credit every payer with what they paid and debit every split with what
+// they owe; refunds take the same amounts back off. Settlements credit the payer and
+// debit the payee with as much as the payee has confirmed receiving.
+func balanceEffects(transaction *db.Transaction) []balanceEffect {
+	switch transaction.Type {
+	case db.TransactionTypeSettlement:
+		return settlementEffects(transaction, settledAmount(transaction))
+	case db.TransactionTypeRefund:
+		return partyEffects(transaction, -1)
 	}
+	return partyEffects(transaction, 1)
+}
 
-	return result, err
+// partyEffects credits each payer and debits each split of a transaction with their
+// converted amounts, times sign
+func partyEffects(transaction *db.Transaction, sign db.Money) []balanceEffect {
+	var effects []balanceEffect
+	for _, payer := range transaction.Payers {
+		effects = append(effects, balanceEffect{UserID: payer.UserID, UserName: payer.UserName, Paid: sign * payer.ConvertedAmount})
+	}
+	for _, split := range transaction.Splits {
+		effects = append(effects, balanceEffect{UserID: split.UserID, UserName: split.UserName, Owed: sign * split.ConvertedAmount})
+	}
+	return effects
 }
 
-// updateUserBalance updates a user's balance in a group atomically
-func (ts *TransactionService) updateUserBalance(sc mongo.SessionContext, groupID, userID primitive.ObjectID, userName string, amountPaid, amountOwed float64, transactionType db.TransactionType, transactionID primitive.ObjectID, currency string) error {
-	balance := &db.GroupBalance{}
+// settlementEffects credits the payer and debits the payee of a settlement with amount
+func settlementEffects(transaction *db.Transaction, amount db.Money) []balanceEffect {
+	if amount == 0 {
+		return nil
+	}
 
-	// Try to find existing balance
-	filter := bson.M{
-		"group_id": groupID,
-		"user_id":  userID,
+	var effects []balanceEffect
+	for _, payer := range transaction.Payers {
+		effects = append(effects, balanceEffect{UserID: payer.UserID, UserName: payer.UserName, Paid: amount})
 	}
+	for _, split := range transaction.Splits {
+		effects = append(effects, balanceEffect{UserID: split.UserID, UserName: split.UserName, Owed: amount})
+	}
+	return effects
+}
 
-	err := mgm.Coll(balance).FindOne(mgm.Ctx(), filter).Decode(balance)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			// Create new balance record
-			balance = db.NewGroupBalance(groupID, userID, userName, currency)
-		} else {
+// applyBalanceEffects applies a transaction to group balances (direction 1) or reverses it
+// (direction -1) by appending one ledger entry per member involved
+func (ts *TransactionService) applyBalanceEffects(sc mongo.SessionContext, transaction *db.Transaction, currency string, direction db.Money) error {
+	for _, effect := range balanceEffects(transaction) {
+		entry := db.NewLedgerEntry(transaction.GroupID, effect.UserID, effect.UserName, transaction.ID, transaction.Type, direction*effect.Paid, direction*effect.Owed, currency)
+		if err := recordLedgerEntry(sc, entry); err != nil {
 			return err
 		}
 	}
-	// Update balance with the provided amounts
-	balance.UpdateBalance(amountPaid, amountOwed, transactionID)
-
-	// Upsert the balance record
-	opts := options.Replace().SetUpsert(true)
-	_, err = mgm.Coll(balance).ReplaceOne(sc, filter, balance, opts)
-	return err
+	return nil
 }
 
 // GetGroupBalances returns current balances for all group members
@@ -294,20 +357,14 @@ func (ts *TransactionService) GetGroupBalances(groupID, userID primitive.ObjectI
 	return balances, err
 }
 
-// logGroupTransactions logs all transactions for debugging purposes
-func (ts *TransactionService) logGroupTransactions(groupID primitive.ObjectID) {
-	var transactions []*db.Transaction
-	err := mgm.Coll(&db.Transaction{}).SimpleFind(&transactions, bson.M{
-		"group_id": groupID,
-	})
-
+// SimplifyDebtsFromBalances suggests the settlements that clear a group's current
+// balances, using the named simplification strategy (greedy by default)
+func (ts *TransactionService) SimplifyDebtsFromBalances(groupID, userID primitive.ObjectID, strategyName string) ([]models.SettlementSuggestion, error) {
+	strategy, err := simplificationStrategy(strategyName)
 	if err != nil {
-		return
+		return nil, err
 	}
-}
 
-// SimplifyDebtsFromBalances calculates optimal settlements from current balances
-func (ts *TransactionService) SimplifyDebtsFromBalances(groupID, userID primitive.ObjectID) ([]models.SettlementSuggestion, error) {
 	balances, err := ts.GetGroupBalances(groupID, userID)
 	if err != nil {
 		// Log the error for debugging
@@ -318,75 +375,39 @@ func (ts *TransactionService) SimplifyDebtsFromBalances(groupID, userID primitiv
 		return []models.SettlementSuggestion{}, nil
 	}
 
-	// Debug: Log recent transactions to understand how we got these balances
-	ts.logGroupTransactions(groupID)
-
-	// Convert to map for simplification algorithm
-	netBalances := make(map[primitive.ObjectID]float64)
+	netBalances := make([]netBalance, 0, len(balances))
 	userLookup := make(map[primitive.ObjectID]string)
 	currency := balances[0].Currency
 
 	for _, balance := range balances {
-		netBalances[balance.UserID] = balance.Balance
+		netBalances = append(netBalances, netBalance{UserID: balance.UserID, Amount: balance.Balance})
 		userLookup[balance.UserID] = balance.UserName
 	}
 
-	// Use the same simplification algorithm but with pre-calculated balances
-	var settlements []models.SettlementSuggestion
-
-
-	for {
-		var maxCreditorID, maxDebtorID primitive.ObjectID
-		maxCredit, maxDebt := 0.0, 0.0
-
-		for userID, balance := range netBalances {
-			if balance > maxCredit {
-				maxCredit = balance
-				maxCreditorID = userID
-			}
-			if balance < maxDebt {
-				maxDebt = balance
-				maxDebtorID = userID
-			}
-		}
-
-
-
-		// maxCredit > 0 means user is owed money
-		// maxDebt < 0 means user owes money
-		if maxCredit <= 0.01 && maxDebt >= -0.01 {
-			break
-		}
-
-		settlementAmount := maxCredit
-		if -maxDebt < maxCredit {
-			settlementAmount = -maxDebt
-		}
-
-		if settlementAmount > 0.01 {
-			settlement := models.SettlementSuggestion{
-				GroupID:   groupID,
-				PayerID:   maxDebtorID, // Person who owes money (negative balance)
-				PayerName: userLookup[maxDebtorID],
-				PayeeID:   maxCreditorID, // Person who is owed money (positive balance)
-				PayeeName: userLookup[maxCreditorID],
-				Amount:    settlementAmount,
-				Currency:  currency,
-				Status:    "pending",
-			}
-			settlements = append(settlements, settlement)
+	transfers, err := strategy.Simplify(groupID, netBalances)
+	if err != nil {
+		return nil, err
+	}
 
-			netBalances[maxDebtorID] += settlementAmount   // Debtor's balance increases (less negative)
-			netBalances[maxCreditorID] -= settlementAmount // Creditor's balance decreases (less positive)
-		} else {
-			break
-		}
+	settlements := []models.SettlementSuggestion{}
+	for _, t := range transfers {
+		settlements = append(settlements, models.SettlementSuggestion{
+			GroupID:   groupID,
+			PayerID:   t.From, // Person who owes money (negative balance)
+			PayerName: userLookup[t.From],
+			PayeeID:   t.To, // Person who is owed money (positive balance)
+			PayeeName: userLookup[t.To],
+			Amount:    t.Amount,
+			Currency:  currency,
+			Status:    "pending",
+		})
 	}
 
 	return settlements, nil
 }
 
-// MarkTransactionComplete marks a settlement transaction as completed
+// MarkTransactionComplete completes a settlement from the caller's side: the payer marks
+// it as paid and the payee confirms it
 func (ts *TransactionService) MarkTransactionComplete(transactionID, userID primitive.ObjectID, notes string, settlementMethod string, proofOfPayment string) error {
 	transaction := &db.Transaction{}
 
@@ -402,71 +423,42 @@ func (ts *TransactionService) MarkTransactionComplete(transactionID, userID prim
 		return errors.New("only settlement transactions can be marked as complete")
 	}
 
-	// Only participants can mark as complete
-	isParticipant := false
-	for _, participant := range transaction.Participants {
-		if participant.UserID == userID {
-			isParticipant = true
-			break
-		}
-	}
-
-	if !isParticipant {
-		return errors.New("only participants can mark settlement as complete")
-	}
-
 	if transaction.IsCompleted {
 		return errors.New("transaction is already completed")
 	}
 
-	now := time.Now()
-	updateDoc := bson.M{
-		"is_completed": true,
-		"settled_at":   now,
-		"updated_at":   now,
-		"updated_by":   userID,
+	status := db.SettlementConfirmed
+	if payerID, _ := settlementParties(transaction); userID == payerID {
+		status = db.SettlementPaid
 	}
 
-	if notes != "" {
-		updateDoc["notes"] = notes
-	}
-	if settlementMethod != "" {
-		updateDoc["settlement_method"] = settlementMethod
-	}
-	if proofOfPayment != "" {
-		updateDoc["proof_of_payment"] = proofOfPayment
-	}
-
-	_, err = mgm.Coll(transaction).UpdateOne(mgm.Ctx(), bson.M{"_id": transactionID}, bson.M{
-		"$set": updateDoc,
+	_, err = ts.UpdateSettlementStatus(transactionID, userID, models.UpdateSettlementStatusRequest{
+		Status:           string(status),
+		Notes:            notes,
+		SettlementMethod: settlementMethod,
+		ProofOfPayment:   proofOfPayment,
 	})
-
 	return err
 }
 
 // GetGroupTransactions returns all transactions for a group
-func (ts *TransactionService) GetGroupTransactions(groupID, userID primitive.ObjectID, transactionType string, page, limit int) ([]*db.Transaction, error) {
+func (ts *TransactionService) GetGroupTransactions(groupID, userID primitive.ObjectID, query models.TransactionQuery) (*models.TransactionPage, error) {
 	// Check if user is group member
-	_, err := GetGroupById(groupID, userID)
+	group, err := GetGroupById(groupID, userID)
 	if err != nil {
 		return nil, err
 	}
 
-	filter := bson.M{"group_id": groupID}
-	if transactionType != "" {
-		filter["type"] = transactionType
+	loc, _, err := viewerLocation(query.Timezone, userID, group)
+	if err != nil {
+		return nil, err
 	}
 
-	var transactions []*db.Transaction
-	findOptions := options.Find().
-		SetSkip(int64(page * limit)).
-		SetLimit(int64(limit + 1)).
-		SetSort(bson.D{{Key: "date", Value: -1}})
-
-	err = mgm.Coll(&db.Transaction{}).SimpleFind(&transactions, filter, findOptions)
+	page, err := findTransactions(bson.M{"group_id": groupID, "deleted_at": bson.M{"$exists": false}}, query, loc)
 	if err != nil {
 		return nil, err
 	}
+	transactions := page.Transactions
 
 	// Collect all unique user IDs first
 	userIDs := make(map[primitive.ObjectID]bool)
@@ -515,7 +507,7 @@ func (ts *TransactionService) GetGroupTransactions(groupID, userID primitive.Obj
 		}
 	}
 
-	return transactions, err
+	return page, nil
 }
 
 // GetTransactionById returns a single transaction by ID
@@ -581,216 +573,374 @@ func (ts *TransactionService) GetTransactionById(transactionID, userID primitive
 	return transaction, nil
 }
 
-// UpdateTransaction updates an expense transaction
-func (ts *TransactionService) UpdateTransaction(transactionID, userID primitive.ObjectID, req models.UpdateTransactionRequest) error {
+// UpdateTransaction updates an expense transaction. Changing the amount, split type,
+// payers or splits recomputes the split and rebalances the group: the old effect
+// on group balances is reversed and the new one applied in the same session.
+func (ts *TransactionService) UpdateTransaction(transactionID, userID primitive.ObjectID, req models.UpdateTransactionRequest) (*db.Transaction, error) {
 	transaction, err := ts.GetTransactionById(transactionID, userID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if transaction.Type != db.TransactionTypeExpense {
-		return errors.New("only expense transactions can be updated")
+		return nil, errors.New("only expense transactions can be updated")
+	}
+
+	if transaction.DeletedAt != nil {
+		return nil, errors.New("deleted transactions cannot be updated, restore them first")
 	}
 
 	// Only the creator can update the transaction
 	if transaction.CreatedBy != userID {
-		return errors.New("only the creator can update this transaction")
+		return nil, errors.New("only the creator can update this transaction")
 	}
 
 	if transaction.IsCompleted {
-		return errors.New("completed transactions cannot be updated")
+		return nil, errors.New("completed transactions cannot be updated")
 	}
 
-	// Create update document
-	updateDoc := bson.M{
-		"updated_at": time.Now(),
-		"updated_by": userID,
+	group, err := GetGroupById(transaction.GroupID, userID)
+	if err != nil {
+		return nil, err
 	}
 
-	if req.Description != "" {
-		updateDoc["description"] = req.Description
-	}
-	if req.Amount > 0 {
-		updateDoc["amount"] = req.Amount
+	// Keep the original to reverse its balance effect and report what changed
+	before := *transaction
+	snapshot := snapshotTransaction(transaction)
+	changed := false
+
+	if req.Description != "" && req.Description != transaction.Description {
+		transaction.Description = req.Description
+		changed = true
 	}
 	if req.Category != "" {
-		updateDoc["category"] = req.Category
+		category, err := resolveCategory(transaction.GroupID, req.Category)
+		if err != nil {
+			return nil, err
+		}
+		if category.ID != transaction.CategoryID {
+			transaction.CategoryID = category.ID
+			transaction.Category = category.Name
+			changed = true
+		}
 	}
-	if req.Notes != "" {
-		updateDoc["notes"] = req.Notes
+	if req.Notes != "" && req.Notes != transaction.Notes {
+		transaction.Notes = req.Notes
+		changed = true
 	}
+	if req.Date != "" || req.Timezone != "" {
+		// A new timezone alone re-reads nothing, it only changes which day the date falls on
+		timezone := req.Timezone
+		if timezone == "" {
+			timezone = transaction.Timezone
+		}
+		if err := setTransactionDate(transaction, req.Date, timezone, userID, group); err != nil {
+			return nil, err
+		}
+		changed = changed || !transaction.Date.Equal(before.Date) || transaction.Timezone != before.Timezone
+	}
+
+	rebalance := req.Amount > 0 || req.ExchangeRate > 0 || req.SplitType != "" || len(req.Payers) > 0 || len(req.Splits) > 0 || len(req.Items) > 0
+	if rebalance {
+		// Refunds are worked out against the payers and splits they were made for
+		refunds, err := ts.expenseRefunds(transaction.ID)
+		if err != nil {
+			return nil, err
+		}
+		if len(refunds) > 0 {
+			return nil, errors.New("the amount and split of a refunded expense cannot be changed, delete its refunds first")
+		}
+
+		if req.Amount > 0 {
+			transaction.Amount = req.Amount
+		}
+		if req.SplitType != "" {
+			transaction.SplitType = db.SplitType(req.SplitType)
+		} else if len(req.Items) > 0 {
+			transaction.SplitType = db.SplitTypeItemized
+		}
+
+		// Keep the rate the expense was entered with unless a new one is given
+		rate := transaction.ExchangeRate
+		if req.ExchangeRate > 0 {
+			rate = req.ExchangeRate
+		}
+		if err := setGroupCurrencyConversion(transaction, group, rate); err != nil {
+			return nil, err
+		}
 
-	// Handle split updates with balance recalculation
-	if req.SplitType != "" && (len(req.Payers) > 0 || len(req.Splits) > 0) {
-		// This would require complex balance recalculation
-		// For now, prevent updates that change payers/splits
-		return errors.New("payer/split updates not yet supported - please delete and recreate the transaction")
+		payerRequests, splitRequests, err := ts.expensePartyRequests(&before, transaction, req)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := ts.buildExpenseParties(transaction, payerRequests, splitRequests); err != nil {
+			return nil, err
+		}
+		changed = true
 	}
 
-	if len(updateDoc) <= 2 { // Only updated_at and updated_by
-		return errors.New("no fields to update")
+	if !changed {
+		return nil, errors.New("no fields to update")
 	}
 
-	_, err = mgm.Coll(transaction).UpdateOne(mgm.Ctx(), bson.M{"_id": transactionID}, bson.M{
-		"$set": updateDoc,
-	})
+	transaction.UpdatedAt = time.Now()
+	transaction.UpdatedBy = userID
 
-	return err
-}
+	updateDoc := bson.M{
+		"description":      transaction.Description,
+		"category_id":      transaction.CategoryID,
+		"category":         transaction.Category,
+		"notes":            transaction.Notes,
+		"date":             transaction.Date,
+		"timezone":         transaction.Timezone,
+		"amount":           transaction.Amount,
+		"group_currency":   transaction.GroupCurrency,
+		"exchange_rate":    transaction.ExchangeRate,
+		"converted_amount": transaction.ConvertedAmount,
+		"split_type":       transaction.SplitType,
+		"payers":           transaction.Payers,
+		"splits":           transaction.Splits,
+		"participants":     transaction.Participants,
+		"updated_at":       transaction.UpdatedAt,
+		"updated_by":       transaction.UpdatedBy,
+	}
+	update := bson.M{"$set": updateDoc}
+	if len(transaction.Items) > 0 {
+		updateDoc["items"] = transaction.Items
+	} else {
+		update["$unset"] = bson.M{"items": ""}
+	}
+
+	err = runInTransaction(func(sc mongo.SessionContext) error {
+		// Only goes through if nobody changed the transaction since it was loaded, so two
+		// edits never both reverse the same old effect
+		result, err := mgm.Coll(transaction).UpdateOne(sc, bson.M{
+			"_id":        transactionID,
+			"updated_at": before.UpdatedAt,
+		}, update)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return errors.New("the transaction was changed by someone else, reload and try again")
+		}
 
-// DeleteTransaction deletes a transaction and recalculates balances
-func (ts *TransactionService) DeleteTransaction(transactionID, userID primitive.ObjectID) error {
-	transaction, err := ts.GetTransactionById(transactionID, userID)
+		if rebalance {
+			// Reverse the old effect, then apply the new one
+			if err := ts.applyBalanceEffects(sc, &before, group.Currency, -1); err != nil {
+				return err
+			}
+			if err := ts.applyBalanceEffects(sc, transaction, group.Currency, 1); err != nil {
+				return err
+			}
+		}
+
+		return recordRevision(sc, db.RevisionEdit, snapshot, transaction, userID)
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Only the creator can delete the transaction
-	if transaction.CreatedBy != userID {
-		return errors.New("only the creator can delete this transaction")
-	}
+	go ts.sendTransactionUpdateNotifications(&before, transaction, group, userID)
 
-	if transaction.IsCompleted && transaction.Type == db.TransactionTypeSettlement {
-		return errors.New("completed settlements cannot be deleted")
-	}
+	return transaction, nil
+}
 
-	// Get group for currency info
-	group, err := GetGroupById(transaction.GroupID, userID)
-	if err != nil {
-		return err
+// expensePartyRequests returns the payers and splits an edited expense should be rebuilt
+// from. Whatever the request leaves out is carried over from the stored expense, so
+// changing only the amount re-splits an equal, percentage or shares expense as before.
+// Itemized expenses get their splits from their items, which are stored on after.
+func (ts *TransactionService) expensePartyRequests(before, after *db.Transaction, req models.UpdateTransactionRequest) ([]models.TransactionPayerRequest, []models.TransactionSplitRequest, error) {
+	payerRequests := req.Payers
+	if len(payerRequests) == 0 {
+		if after.Amount != before.Amount && len(before.Payers) != 1 {
+			return nil, nil, errors.New("payers must be provided when changing the amount of an expense with several payers")
+		}
+
+		for _, payer := range before.Payers {
+			amount := payer.Amount
+			if after.Amount != before.Amount {
+				amount = after.Amount // single payer covers the new total
+			}
+			payerRequests = append(payerRequests, models.TransactionPayerRequest{
+				UserID: payer.UserID.Hex(),
+				Amount: amount,
+			})
+		}
 	}
 
-	// Start transaction to delete and update balances atomically
-	_, client, _, err := mgm.DefaultConfigs()
-	if err != nil {
-		return err
+	// Itemized splits are always re-derived from the items, edited or stored
+	if after.SplitType == db.SplitTypeItemized {
+		if len(req.Splits) > 0 {
+			return nil, nil, errors.New("splits of an itemized expense are derived from its items")
+		}
+
+		items := req.Items
+		if len(items) == 0 {
+			items = itemRequests(before.Items)
+		}
+
+		var splitRequests []models.TransactionSplitRequest
+		var err error
+		after.Items, splitRequests, err = ComputeItemizedSplits(after.Amount, items)
+		return payerRequests, splitRequests, err
 	}
 
-	session, err := client.StartSession()
-	if err != nil {
-		return err
+	if len(req.Items) > 0 {
+		return nil, nil, errors.New("items can only be used with an itemized split")
 	}
-	defer session.EndSession(context.Background())
+	after.Items = nil
 
-	err = mongo.WithSession(context.Background(), session, func(sc mongo.SessionContext) error {
-		// Delete the transaction
-		if err := mgm.Coll(transaction).Delete(transaction); err != nil {
-			return err
+	splitRequests := req.Splits
+	if len(splitRequests) == 0 {
+		if after.SplitType != before.SplitType && after.SplitType != db.SplitTypeEqual {
+			return nil, nil, errors.New("splits must be provided when changing the split type")
 		}
-
-		// Reverse balance updates for all participants
-		for _, participant := range transaction.Participants {
-			if err := ts.reverseUserBalance(sc, transaction.GroupID, participant.UserID, participant.Amount, transaction.Type, group.Currency); err != nil {
-				return err
-			}
+		if after.SplitType == db.SplitTypeExact && after.Amount != before.Amount {
+			return nil, nil, errors.New("splits must be provided when changing the amount of an exact split")
 		}
 
-		return nil
-	})
+		for _, split := range before.Splits {
+			splitRequests = append(splitRequests, models.TransactionSplitRequest{
+				UserID:     split.UserID.Hex(),
+				Amount:     split.Amount,
+				Percentage: split.Percentage,
+				Shares:     split.Shares,
+			})
+		}
+	}
 
-	return err
+	return payerRequests, splitRequests, nil
 }
 
-// reverseUserBalance reverses a balance update when a transaction is deleted
-func (ts *TransactionService) reverseUserBalance(sc mongo.SessionContext, groupID, userID primitive.ObjectID, amount float64, transactionType db.TransactionType, currency string) error {
-	balance := &db.GroupBalance{}
+// DeleteTransaction moves a transaction to the trash and reverses its effect on balances.
+// It stays visible to the group and can be restored until it is purged.
+func (ts *TransactionService) DeleteTransaction(transactionID, userID primitive.ObjectID) error {
+	transaction, err := ts.GetTransactionById(transactionID, userID)
+	if err != nil {
+		return err
+	}
 
-	filter := bson.M{
-		"group_id": groupID,
-		"user_id":  userID,
+	// Only the creator can delete the transaction
+	if transaction.CreatedBy != userID {
+		return errors.New("only the creator can delete this transaction")
 	}
 
-	err := mgm.Coll(balance).FindOne(mgm.Ctx(), filter).Decode(balance)
-	if err != nil {
-		return err // Balance should exist if transaction existed
+	if transaction.DeletedAt != nil {
+		return errors.New("transaction is already deleted")
 	}
 
-	// Reverse the balance changes
-	var amountPaid, amountOwed float64
+	if transaction.Type == db.TransactionTypeSettlement && transaction.Status == db.SettlementConfirmed {
+		return errors.New("confirmed settlements cannot be deleted")
+	}
+	if transaction.Type == db.TransactionTypeSettlement && len(transaction.Payments) > 0 {
+		return errors.New("settlements with payments cannot be deleted, cancel them instead")
+	}
 
-	switch transactionType {
-	case db.TransactionTypeExpense:
-		if amount > 0 {
-			amountPaid = -amount // Reverse payment
-		} else {
-			amountOwed = amount // Reverse debt (negative amount becomes positive)
+	if transaction.Type == db.TransactionTypeExpense {
+		refunds, err := ts.expenseRefunds(transaction.ID)
+		if err != nil {
+			return err
 		}
-	case db.TransactionTypeSettlement:
-		if amount > 0 {
-			amountPaid = -amount // Reverse payment
-		} else {
-			amountOwed = -amount // Reverse receipt
+		if len(refunds) > 0 {
+			return errors.New("expenses with refunds cannot be deleted, delete the refunds first")
 		}
 	}
 
-	// Update balance
-	balance.TotalPaid += amountPaid
-	balance.TotalOwed += amountOwed
-	balance.Balance = balance.TotalPaid - balance.TotalOwed
-	balance.LastUpdated = time.Now()
-	balance.Version++
+	// Get group for currency info
+	group, err := GetGroupById(transaction.GroupID, userID)
+	if err != nil {
+		return err
+	}
 
-	// Update the balance record
-	_, err = mgm.Coll(balance).ReplaceOne(sc, filter, balance)
-	return err
+	// Trash the transaction and update balances atomically
+	return runInTransaction(func(sc mongo.SessionContext) error {
+		return ts.trashTransaction(sc, transaction, group.Currency, userID)
+	})
 }
 
-// GetGroupBalanceHistory returns balance change history for a group
-func (ts *TransactionService) GetGroupBalanceHistory(groupID, userID primitive.ObjectID, days int) (interface{}, error) {
+// GetGroupBalanceHistory returns balance change history for a group by day, and the
+// timezone the days are counted in
+func (ts *TransactionService) GetGroupBalanceHistory(groupID, userID primitive.ObjectID, days int, timezone string) (interface{}, string, error) {
 	// Check if user is group member
-	_, err := GetGroupById(groupID, userID)
+	group, err := GetGroupById(groupID, userID)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	// Get transactions from the last N days
-	startDate := time.Now().AddDate(0, 0, -days)
+	loc, timezone, err := viewerLocation(timezone, userID, group)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Get transactions from the start of the day N days ago
+	y, m, d := time.Now().In(loc).AddDate(0, 0, -days).Date()
+	startDate := time.Date(y, m, d, 0, 0, 0, 0, loc)
 
 	var transactions []*db.Transaction
 	filter := bson.M{
-		"group_id": groupID,
-		"date":     bson.M{"$gte": startDate},
+		"group_id":   groupID,
+		"date":       bson.M{"$gte": startDate},
+		"deleted_at": bson.M{"$exists": false},
 	}
 
 	findOptions := options.Find().SetSort(bson.D{{Key: "date", Value: 1}})
 	err = mgm.Coll(&db.Transaction{}).SimpleFind(&transactions, filter, findOptions)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	// Build balance history by replaying transactions
 	history := make(map[string][]map[string]interface{})
 
 	for _, transaction := range transactions {
-		dateStr := transaction.Date.Format("2006-01-02")
+		dateStr := transaction.Date.In(loc).Format("2006-01-02")
 		if history[dateStr] == nil {
 			history[dateStr] = []map[string]interface{}{}
 		}
 
-		history[dateStr] = append(history[dateStr], map[string]interface{}{
-			"transaction_id": transaction.ID,
-			"type":           transaction.Type,
-			"description":    transaction.Description,
-			"amount":         transaction.Amount,
-			"participants":   transaction.Participants,
-		})
+		entry := map[string]interface{}{
+			"transaction_id":   transaction.ID,
+			"type":             transaction.Type,
+			"description":      transaction.Description,
+			"amount":           transaction.Amount,
+			"currency":         transaction.Currency,
+			"converted_amount": transaction.ConvertedAmount,
+			"participants":     transaction.Participants,
+		}
+		if !transaction.RefundOf.IsZero() {
+			entry["refund_of"] = transaction.RefundOf
+		}
+		if transaction.Reason != "" {
+			entry["reason"] = transaction.Reason
+		}
+
+		history[dateStr] = append(history[dateStr], entry)
 	}
 
-	return history, nil
+	return history, timezone, nil
 }
 
-// GetGroupAnalytics returns analytics data for a group
-func (ts *TransactionService) GetGroupAnalytics(groupID, userID primitive.ObjectID) (interface{}, error) {
+// GetGroupAnalytics returns analytics data for a group. Monthly figures are counted in
+// the timezone asked for, else the user's or the group's.
+func (ts *TransactionService) GetGroupAnalytics(groupID, userID primitive.ObjectID, timezone string) (interface{}, error) {
 	// Check if user is group member
 	group, err := GetGroupById(groupID, userID)
 	if err != nil {
 		return nil, err
 	}
 
+	loc, timezone, err := viewerLocation(timezone, userID, group)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get all transactions for the group
 	var transactions []*db.Transaction
 	err = mgm.Coll(&db.Transaction{}).SimpleFind(&transactions, bson.M{
-		"group_id": groupID,
+		"group_id":   groupID,
+		"deleted_at": bson.M{"$exists": false},
 	})
 	if err != nil {
 		return nil, err
@@ -809,8 +959,9 @@ func (ts *TransactionService) GetGroupAnalytics(groupID, userID primitive.Object
 		"total_transactions": len(transactions),
 		"total_expenses":     0,
 		"total_settlements":  0,
-		"total_amount":       0.0,
+		"total_amount":       db.Money(0),
 		"currency":           group.Currency,
+		"timezone":           timezone,
 		"member_count":       len(group.Members),
 		"balances_summary": map[string]int{
 			"positive": 0, // Members who are owed money
@@ -820,32 +971,51 @@ func (ts *TransactionService) GetGroupAnalytics(groupID, userID primitive.Object
 	}
 
 	// Process transactions
-	var totalExpenseAmount, totalSettlementAmount float64
-	expenseCount, settlementCount := 0, 0
+	var totalExpenseAmount, totalSettlementAmount, totalRefundAmount db.Money
+	expenseCount, settlementCount, refundCount, adjustmentCount := 0, 0, 0, 0
+	monthlySpending := make(map[string]db.Money) // Net spend by YYYY-MM
 
 	for _, transaction := range transactions {
+		month := transaction.Date.In(loc).Format("2006-01")
 		switch transaction.Type {
 		case db.TransactionTypeExpense:
 			expenseCount++
-			totalExpenseAmount += transaction.Amount
+			totalExpenseAmount += transaction.ConvertedAmount
+			monthlySpending[month] += transaction.ConvertedAmount
 		case db.TransactionTypeSettlement:
 			settlementCount++
-			totalSettlementAmount += transaction.Amount
+			totalSettlementAmount += transaction.ConvertedAmount
+		case db.TransactionTypeRefund:
+			refundCount++
+			totalRefundAmount += transaction.ConvertedAmount
+			monthlySpending[month] -= transaction.ConvertedAmount
+		case db.TransactionTypeAdjustment:
+			adjustmentCount++
 		}
 	}
 
 	analytics["total_expenses"] = expenseCount
 	analytics["total_settlements"] = settlementCount
+	analytics["total_refunds"] = refundCount
+	analytics["total_adjustments"] = adjustmentCount
 	analytics["total_expense_amount"] = totalExpenseAmount
 	analytics["total_settlement_amount"] = totalSettlementAmount
-	analytics["total_amount"] = totalExpenseAmount
+	analytics["total_refund_amount"] = totalRefundAmount
+	analytics["total_amount"] = totalExpenseAmount - totalRefundAmount // Net spend after refunds
+	analytics["monthly_spending"] = monthlySpending
+
+	spending, err := categorySpending(groupID, transactions)
+	if err != nil {
+		return nil, err
+	}
+	analytics["category_spending"] = spending
 
 	// Process balances
 	balanceSummary := analytics["balances_summary"].(map[string]int)
 	for _, balance := range balances {
-		if balance.Balance > 0.01 {
+		if balance.Balance > 0 {
 			balanceSummary["positive"]++
-		} else if balance.Balance < -0.01 {
+		} else if balance.Balance < 0 {
 			balanceSummary["negative"]++
 		} else {
 			balanceSummary["zero"]++
@@ -855,10 +1025,12 @@ func (ts *TransactionService) GetGroupAnalytics(groupID, userID primitive.Object
 	return analytics, nil
 }
 
-// CreateBulkSettlements creates multiple settlements from suggested settlements
+// CreateBulkSettlements creates multiple settlements from suggested settlements as one
+// batch. They share a batch ID and are stored in a single MongoDB transaction, so either
+// every settlement and balance update is committed or none is.
 func (ts *TransactionService) CreateBulkSettlements(groupID, userID primitive.ObjectID, settlementRequests []models.CreateSettlementTransactionRequest) ([]*db.Transaction, error) {
 	// Verify user is group member
-	_, err := GetGroupById(groupID, userID)
+	group, err := GetGroupById(groupID, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -871,130 +1043,162 @@ func (ts *TransactionService) CreateBulkSettlements(groupID, userID primitive.Ob
 		return nil, errors.New("too many settlements - maximum 50 per request")
 	}
 
+	batchID := primitive.NewObjectID()
+	users := make(map[string]*db.User)
 	var settlements []*db.Transaction
 
-	// Create settlements one by one (could be optimized with bulk operations)
-	for _, req := range settlementRequests {
+	// Build every settlement first, so a bad one fails the batch before anything is written
+	for i, req := range settlementRequests {
 		if req.GroupID != groupID.Hex() {
 			return nil, errors.New("all settlements must be for the same group")
 		}
 
-		payerID, err := primitive.ObjectIDFromHex(req.PayerID)
+		payer, err := batchUser(users, req.PayerID)
 		if err != nil {
-			return nil, errors.New("invalid payer ID in settlement request")
+			return nil, fmt.Errorf("settlement %d: payer: %w", i+1, err)
 		}
 
-		payeeID, err := primitive.ObjectIDFromHex(req.PayeeID)
+		payee, err := batchUser(users, req.PayeeID)
 		if err != nil {
-			return nil, errors.New("invalid payee ID in settlement request")
+			return nil, fmt.Errorf("settlement %d: payee: %w", i+1, err)
 		}
 
-		settlement, err := ts.CreateSettlementTransaction(groupID, payerID, payeeID, req.Amount, req.Currency, req.Notes, req.IsCompleted, userID)
+		settlement, err := buildSettlementTransaction(group, payer, payee, req.Amount, req.Currency, req.ExchangeRate, req.Notes, req.IsCompleted, userID, req.Date, req.Timezone)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("settlement %d: %w", i+1, err)
 		}
+		settlement.BatchID = batchID
 
 		settlements = append(settlements, settlement)
 	}
 
+	err = runInTransaction(func(sc mongo.SessionContext) error {
+		for _, settlement := range settlements {
+			if err := ts.insertTransaction(sc, settlement, group.Currency); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, settlement := range settlements {
+		go ts.sendTransactionNotifications(settlement, group)
+	}
+
 	return settlements, nil
 }
 
-// RecalculateGroupBalances recalculates all balances for a group (admin operation)
-func (ts *TransactionService) RecalculateGroupBalances(groupID, userID primitive.ObjectID) error {
-	// Check if user is group member
-	group, err := GetGroupById(groupID, userID)
+// batchUser looks up a user of a bulk request by hex ID, once per batch
+func batchUser(users map[string]*db.User, idHex string) (*db.User, error) {
+	if user, ok := users[idHex]; ok {
+		return user, nil
+	}
+
+	userID, err := primitive.ObjectIDFromHex(idHex)
 	if err != nil {
-		return err
+		return nil, errors.New("invalid user ID")
 	}
 
-	// Get all transactions for the group
-	var transactions []*db.Transaction
-	findOptions := options.Find().SetSort(bson.D{{Key: "date", Value: 1}})
-	err = mgm.Coll(&db.Transaction{}).SimpleFind(&transactions, bson.M{
-		"group_id": groupID,
-	}, findOptions)
+	user, err := FindUserById(userID)
 	if err != nil {
-		return err
+		return nil, errors.New("user not found")
 	}
 
-	// Reset all balances for the group
-	_, err = mgm.Coll(&db.GroupBalance{}).DeleteMany(mgm.Ctx(), bson.M{
-		"group_id": groupID,
+	users[idHex] = user
+	return user, nil
+}
+
+// GetSettlementBatch returns the settlements created together in a batch
+func (ts *TransactionService) GetSettlementBatch(groupID, batchID, userID primitive.ObjectID) ([]*db.Transaction, error) {
+	if _, err := GetGroupById(groupID, userID); err != nil {
+		return nil, err
+	}
+
+	settlements := []*db.Transaction{}
+	err := mgm.Coll(&db.Transaction{}).SimpleFind(&settlements, bson.M{
+		"group_id":   groupID,
+		"batch_id":   batchID,
+		"deleted_at": bson.M{"$exists": false},
 	})
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if len(settlements) == 0 {
+		return nil, errors.New("settlement batch not found")
 	}
 
-	// Replay all transactions to rebuild balances
-	for _, transaction := range transactions {
-		for _, participant := range transaction.Participants {
-			var amountPaid, amountOwed float64
-
-			switch transaction.Type {
-			case db.TransactionTypeExpense:
-				if participant.Amount > 0 {
-					amountPaid = participant.Amount
-				} else {
-					amountOwed = -participant.Amount
-				}
-			case db.TransactionTypeSettlement:
-				if participant.Amount > 0 {
-					amountPaid = participant.Amount
-				} else {
-					amountOwed = participant.Amount // This will be negative
-				}
-			}
+	return settlements, nil
+}
 
-			// Update or create balance
-			filter := bson.M{
-				"group_id": groupID,
-				"user_id":  participant.UserID,
-			}
+// UndoSettlementBatch moves every settlement of a batch to the trash and reverses what
+// they did to balances, in one MongoDB transaction. Only the batch's creator can undo it, and only
+// while nobody else has confirmed, disputed or paid any of its settlements.
+func (ts *TransactionService) UndoSettlementBatch(groupID, batchID, userID primitive.ObjectID) error {
+	settlements, err := ts.GetSettlementBatch(groupID, batchID, userID)
+	if err != nil {
+		return err
+	}
 
-			balance := &db.GroupBalance{}
-			err := mgm.Coll(balance).FindOne(mgm.Ctx(), filter).Decode(balance)
-			if err != nil {
-				if err == mongo.ErrNoDocuments {
-					// Create new balance
-					balance = db.NewGroupBalance(groupID, participant.UserID, participant.UserName, group.Currency)
-				} else {
-					return err
-				}
+	for _, settlement := range settlements {
+		if settlement.CreatedBy != userID {
+			return errors.New("only the creator can undo this batch")
+		}
+		if len(settlement.Payments) > 0 {
+			return errors.New("settlements in this batch have payments and cannot be undone")
+		}
+		for _, change := range settlement.StatusHistory {
+			if change.ChangedBy != userID {
+				return errors.New("settlements in this batch have been acted on by others and cannot be undone")
 			}
+		}
+	}
 
-			balance.UpdateBalance(amountPaid, amountOwed, transaction.ID)
+	group, err := GetGroupById(groupID, userID)
+	if err != nil {
+		return err
+	}
 
-			// Upsert the balance
-			opts := options.Replace().SetUpsert(true)
-			_, err = mgm.Coll(balance).ReplaceOne(mgm.Ctx(), filter, balance, opts)
-			if err != nil {
+	return runInTransaction(func(sc mongo.SessionContext) error {
+		for _, settlement := range settlements {
+			if err := ts.trashTransaction(sc, settlement, group.Currency, userID); err != nil {
 				return err
 			}
 		}
+		return nil
+	})
+}
+
+// RecalculateGroupBalances rebuilds all balances for a group from its ledger. Only admins
+// can do it, since members have no way to tell whether the balances have drifted.
+func (ts *TransactionService) RecalculateGroupBalances(groupID, userID primitive.ObjectID) error {
+	user, err := FindUserById(userID)
+	if err != nil || user.Role != db.RoleAdmin {
+		return errors.New("only admins can recalculate balances")
 	}
 
-	return nil
+	group := &db.Group{}
+	if err := mgm.Coll(group).FindByID(groupID, group); err != nil {
+		return errors.New("group not found")
+	}
+
+	return RebuildGroupBalances(groupID)
 }
 
 // GetUserTransactions returns all transactions for a user across all groups
-func (ts *TransactionService) GetUserTransactions(userID primitive.ObjectID, transactionType string, page, limit int) ([]*db.Transaction, error) {
-	filter := bson.M{
-		"participants.user_id": userID,
+func (ts *TransactionService) GetUserTransactions(userID primitive.ObjectID, query models.TransactionQuery) (*models.TransactionPage, error) {
+	loc, _, err := viewerLocation(query.Timezone, userID, nil)
+	if err != nil {
+		return nil, err
 	}
 
-	if transactionType != "" {
-		filter["type"] = transactionType
+	filter := bson.M{
+		"participants.user_id": userID,
+		"deleted_at":           bson.M{"$exists": false},
 	}
-
-	var transactions []*db.Transaction
-	findOptions := options.Find().
-		SetSkip(int64(page * limit)).
-		SetLimit(int64(limit + 1)).
-		SetSort(bson.D{{Key: "date", Value: -1}})
-
-	err := mgm.Coll(&db.Transaction{}).SimpleFind(&transactions, filter, findOptions)
-	return transactions, err
+	return findTransactions(filter, query, loc)
 }
 
 // GetUserBalances returns all group balances for a user
@@ -1006,8 +1210,10 @@ func (ts *TransactionService) GetUserBalances(userID primitive.ObjectID) ([]*db.
 	return balances, err
 }
 
-// GetUserAnalytics returns analytics data for a user
-func (ts *TransactionService) GetUserAnalytics(userID primitive.ObjectID) (interface{}, error) {
+// GetUserAnalytics returns analytics data for a user. Group balances are in their own
+// group currencies, so the net balance is converted into currency at today's rates;
+// when currency is empty the currency most of the user's groups use is picked.
+func (ts *TransactionService) GetUserAnalytics(userID primitive.ObjectID, currency string) (interface{}, error) {
 	// Get user's balances across all groups
 	balances, err := ts.GetUserBalances(userID)
 	if err != nil {
@@ -1015,19 +1221,38 @@ func (ts *TransactionService) GetUserAnalytics(userID primitive.ObjectID) (inter
 	}
 
 	// Get user's transactions across all groups
-	transactions, err := ts.GetUserTransactions(userID, "", 0, 1000) // Get up to 1000 recent transactions
+	page, err := ts.GetUserTransactions(userID, models.TransactionQuery{PageQuery: models.PageQuery{Limit: 1000}}) // Get up to 1000 recent transactions
 	if err != nil {
 		return nil, err
 	}
+	transactions := page.Transactions
+
+	// Direct expenses with friends count towards the totals but are not groups
+	var directGroups []*db.Group
+	err = mgm.Coll(&db.Group{}).SimpleFind(&directGroups, bson.M{"members": userID, "direct": true})
+	if err != nil {
+		return nil, err
+	}
+	directGroupIDs := make(map[primitive.ObjectID]bool, len(directGroups))
+	for _, group := range directGroups {
+		directGroupIDs[group.ID] = true
+	}
+	directBalances := 0
+	for _, balance := range balances {
+		if directGroupIDs[balance.GroupID] {
+			directBalances++
+		}
+	}
 
 	// Calculate analytics
 	analytics := map[string]interface{}{
 		"user_id":            userID,
-		"total_groups":       len(balances),
+		"total_groups":       len(balances) - directBalances,
+		"total_direct":       directBalances, // Direct balances with friends, one per currency
 		"total_transactions": len(transactions),
 		"total_expenses":     0,
 		"total_settlements":  0,
-		"net_balance":        0.0,
+		"net_balance":        db.Money(0),
 		"groups_summary": map[string]int{
 			"owe_money":  0, // Groups where user owes money
 			"owed_money": 0, // Groups where user is owed money
@@ -1036,36 +1261,68 @@ func (ts *TransactionService) GetUserAnalytics(userID primitive.ObjectID) (inter
 	}
 
 	// Process balances
-	var netBalance float64
 	groupsSummary := analytics["groups_summary"].(map[string]int)
+	balancesByCurrency := make(map[string]db.Money)
+	groupsByCurrency := make(map[string]int)
 
 	for _, balance := range balances {
-		netBalance += balance.Balance
+		balancesByCurrency[balance.Currency] += balance.Balance
+		groupsByCurrency[balance.Currency]++
 
-		if balance.Balance > 0.01 {
+		if balance.Balance > 0 {
 			groupsSummary["owed_money"]++
-		} else if balance.Balance < -0.01 {
+		} else if balance.Balance < 0 {
 			groupsSummary["owe_money"]++
 		} else {
 			groupsSummary["balanced"]++
 		}
 	}
 
+	if currency == "" {
+		for balanceCurrency, count := range groupsByCurrency {
+			if currency == "" || count > groupsByCurrency[currency] || (count == groupsByCurrency[currency] && balanceCurrency < currency) {
+				currency = balanceCurrency
+			}
+		}
+	}
+
+	var netBalance db.Money
+	unconverted := []string{}
+	now := time.Now()
+	for balanceCurrency, amount := range balancesByCurrency {
+		converted, err := ConvertAt(amount, balanceCurrency, currency, now)
+		if err != nil {
+			unconverted = append(unconverted, balanceCurrency)
+			continue
+		}
+		netBalance += converted
+	}
+	sort.Strings(unconverted)
+
+	analytics["currency"] = currency
 	analytics["net_balance"] = netBalance
+	analytics["balances_by_currency"] = balancesByCurrency
+	analytics["unconverted_currencies"] = unconverted // Left out of net_balance, no rate available
 
 	// Process transactions
-	expenseCount, settlementCount := 0, 0
+	expenseCount, settlementCount, refundCount, adjustmentCount := 0, 0, 0, 0
 	for _, transaction := range transactions {
 		switch transaction.Type {
 		case db.TransactionTypeExpense:
 			expenseCount++
 		case db.TransactionTypeSettlement:
 			settlementCount++
+		case db.TransactionTypeRefund:
+			refundCount++
+		case db.TransactionTypeAdjustment:
+			adjustmentCount++
 		}
 	}
 
 	analytics["total_expenses"] = expenseCount
 	analytics["total_settlements"] = settlementCount
+	analytics["total_refunds"] = refundCount
+	analytics["total_adjustments"] = adjustmentCount
 
 	return analytics, nil
 }
@@ -1126,6 +1383,12 @@ func (ts *TransactionService) sendTransactionNotifications(transaction *db.Trans
 			case db.TransactionTypeSettlement:
 				title = "New Settlement"
 				body = fmt.Sprintf("A settlement was recorded in %s", group.Name)
+			case db.TransactionTypeRefund:
+				title = "New Refund"
+				body = fmt.Sprintf("A refund '%s' was recorded in %s", transaction.Description, group.Name)
+			case db.TransactionTypeAdjustment:
+				title = "Balances Adjusted"
+				body = fmt.Sprintf("Balances in %s were adjusted: %s", group.Name, transaction.Reason)
 			}
 
 			notificationData := map[string]interface{}{
@@ -1150,3 +1413,106 @@ func (ts *TransactionService) sendTransactionNotifications(transaction *db.Trans
 		}
 	}
 }
+
+// sendTransactionUpdateNotifications tells everyone involved before or after an edit what changed
+func (ts *TransactionService) sendTransactionUpdateNotifications(before, after *db.Transaction, group *db.Group, editorID primitive.ObjectID) {
+	changes := transactionChanges(before, after)
+	if len(changes) == 0 {
+		return
+	}
+
+	recipients := make(map[primitive.ObjectID]bool)
+	for _, participant := range before.Participants {
+		recipients[participant.UserID] = true
+	}
+	for _, participant := range after.Participants {
+		recipients[participant.UserID] = true
+	}
+
+	body := fmt.Sprintf("'%s' in %s was edited: %s", after.Description, group.Name, strings.Join(changes, "; "))
+	data := map[string]interface{}{
+		"type":           "transaction_updated",
+		"transaction_id": after.ID.Hex(),
+		"group_id":       group.ID.Hex(),
+		"updated_by":     editorID.Hex(),
+		"changes":        changes,
+	}
+
+	for recipientID := range recipients {
+		NotifyUser(recipientID, "Expense Updated", body, data)
+	}
+}
+
+// transactionChanges describes the user-visible differences between two versions of an expense
+func transactionChanges(before, after *db.Transaction) []string {
+	var changes []string
+	currency := after.Currency
+
+	if before.Description != after.Description {
+		changes = append(changes, fmt.Sprintf("description '%s' → '%s'", before.Description, after.Description))
+	}
+	if before.Amount != after.Amount {
+		changes = append(changes, fmt.Sprintf("amount %s → %s %s", before.Amount.Format(currency), after.Amount.Format(currency), currency))
+	}
+	if before.ExchangeRate != after.ExchangeRate {
+		changes = append(changes, fmt.Sprintf("exchange rate %g → %g", before.ExchangeRate, after.ExchangeRate))
+	}
+	if before.Category != after.Category {
+		changes = append(changes, fmt.Sprintf("category '%s' → '%s'", before.Category, after.Category))
+	}
+	if before.SplitType != after.SplitType {
+		changes = append(changes, fmt.Sprintf("split %s → %s", before.SplitType, after.SplitType))
+	}
+
+	type share struct {
+		name   string
+		before db.Money
+		after  db.Money
+	}
+
+	paid := make(map[primitive.ObjectID]*share)
+	var paidOrder []primitive.ObjectID
+	for _, payers := range [][]db.TransactionPayer{before.Payers, after.Payers} {
+		for _, payer := range payers {
+			if paid[payer.UserID] == nil {
+				paid[payer.UserID] = &share{name: payer.UserName}
+				paidOrder = append(paidOrder, payer.UserID)
+			}
+		}
+	}
+	for _, payer := range before.Payers {
+		paid[payer.UserID].before += payer.Amount
+	}
+	for _, payer := range after.Payers {
+		paid[payer.UserID].after += payer.Amount
+	}
+	for _, userID := range paidOrder {
+		if s := paid[userID]; s.before != s.after {
+			changes = append(changes, fmt.Sprintf("%s paid %s → %s", s.name, s.before.Format(currency), s.after.Format(currency)))
+		}
+	}
+
+	owed := make(map[primitive.ObjectID]*share)
+	var owedOrder []primitive.ObjectID
+	for _, splits := range [][]db.TransactionSplit{before.Splits, after.Splits} {
+		for _, split := range splits {
+			if owed[split.UserID] == nil {
+				owed[split.UserID] = &share{name: split.UserName}
+				owedOrder = append(owedOrder, split.UserID)
+			}
+		}
+	}
+	for _, split := range before.Splits {
+		owed[split.UserID].before += split.Amount
+	}
+	for _, split := range after.Splits {
+		owed[split.UserID].after += split.Amount
+	}
+	for _, userID := range owedOrder {
+		if s := owed[userID]; s.before != s.after {
+			changes = append(changes, fmt.Sprintf("%s's share %s → %s", s.name, s.before.Format(currency), s.after.Format(currency)))
+		}
+	}
+
+	return changes
+}
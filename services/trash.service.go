@@ -0,0 +1,180 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	db "github.com/ebubekiryigit/golang-mongodb-rest-api-starter/models/db"
+	"github.com/kamva/mgm/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// TrashRetention is how long deleted transactions can be restored before they are purged
+	TrashRetention = 30 * 24 * time.Hour
+
+	// trashPurgeInterval is how often expired transactions are purged
+	trashPurgeInterval = time.Hour
+)
+
+// trashTransaction marks a transaction as deleted and reverses its effect on balances. The
+// update only goes through if nobody changed the transaction since it was loaded.
+func (ts *TransactionService) trashTransaction(sc mongo.SessionContext, transaction *db.Transaction, currency string, userID primitive.ObjectID) error {
+	before := snapshotTransaction(transaction)
+	now := time.Now()
+	result, err := mgm.Coll(transaction).UpdateOne(sc, bson.M{
+		"_id":        transaction.ID,
+		"updated_at": transaction.UpdatedAt,
+		"deleted_at": bson.M{"$exists": false},
+	}, bson.M{"$set": bson.M{
+		"deleted_at": now,
+		"deleted_by": userID,
+		"updated_at": now,
+		"updated_by": userID,
+	}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("the transaction was changed by someone else, reload and try again")
+	}
+
+	if err := ts.applyBalanceEffects(sc, transaction, currency, -1); err != nil {
+		return err
+	}
+
+	transaction.DeletedAt = &now
+	transaction.DeletedBy = userID
+	transaction.UpdatedAt = now
+	transaction.UpdatedBy = userID
+	return recordRevision(sc, db.RevisionDelete, before, transaction, userID)
+}
+
+// GetGroupTrash returns a group's deleted transactions that can still be restored, most
+// recently deleted first
+func (ts *TransactionService) GetGroupTrash(groupID, userID primitive.ObjectID) ([]*db.Transaction, error) {
+	if _, err := GetGroupById(groupID, userID); err != nil {
+		return nil, err
+	}
+
+	transactions := []*db.Transaction{}
+	err := mgm.Coll(&db.Transaction{}).SimpleFind(&transactions, bson.M{
+		"group_id":   groupID,
+		"deleted_at": bson.M{"$gte": time.Now().Add(-TrashRetention)},
+	}, options.Find().SetSort(bson.D{{Key: "deleted_at", Value: -1}}))
+	return transactions, err
+}
+
+// RestoreTransaction takes a transaction out of the trash and applies its effect on
+// balances again. Only its creator can restore it, within TrashRetention of deleting it.
+func (ts *TransactionService) RestoreTransaction(transactionID, userID primitive.ObjectID) (*db.Transaction, error) {
+	transaction, err := ts.GetTransactionById(transactionID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if transaction.CreatedBy != userID {
+		return nil, errors.New("only the creator can restore this transaction")
+	}
+
+	if transaction.DeletedAt == nil {
+		return nil, errors.New("transaction is not deleted")
+	}
+
+	if time.Since(*transaction.DeletedAt) > TrashRetention {
+		return nil, errors.New("this transaction was deleted more than 30 days ago and can no longer be restored")
+	}
+
+	// A refund only makes sense against the expense it was worked out for
+	if transaction.Type == db.TransactionTypeRefund {
+		expense := &db.Transaction{}
+		if err := mgm.Coll(expense).FindByID(transaction.RefundOf, expense); err != nil || expense.DeletedAt != nil {
+			return nil, errors.New("the refunded expense is deleted, restore it first")
+		}
+		if expense.UpdatedAt.After(*transaction.DeletedAt) {
+			return nil, errors.New("the refunded expense has changed since the refund was deleted, record the refund again")
+		}
+		// Refunds recorded since may have used up what this one handed back
+		remaining, err := ts.refundableExpense(expense)
+		if err != nil {
+			return nil, err
+		}
+		if !remaining.covers(transaction) {
+			return nil, errors.New("the expense has been refunded again since the refund was deleted, there is not enough left to restore it")
+		}
+	}
+
+	group, err := GetGroupById(transaction.GroupID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	before := snapshotTransaction(transaction)
+	now := time.Now()
+	transaction.DeletedAt = nil
+	transaction.DeletedBy = primitive.NilObjectID
+	transaction.UpdatedAt = now
+	transaction.UpdatedBy = userID
+
+	err = runInTransaction(func(sc mongo.SessionContext) error {
+		result, err := mgm.Coll(transaction).UpdateOne(sc, bson.M{
+			"_id":        transaction.ID,
+			"deleted_at": before.DeletedAt,
+		}, bson.M{
+			"$set":   bson.M{"updated_at": now, "updated_by": userID},
+			"$unset": bson.M{"deleted_at": "", "deleted_by": ""},
+		})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return errors.New("the transaction was changed by someone else, reload and try again")
+		}
+
+		if err := ts.applyBalanceEffects(sc, transaction, group.Currency, 1); err != nil {
+			return err
+		}
+		return recordRevision(sc, db.RevisionRestore, before, transaction, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+// StartTrashPurgeJob removes transactions that have been in the trash for longer than
+// TrashRetention, once an hour in the background
+func StartTrashPurgeJob() {
+	go func() {
+		ticker := time.NewTicker(trashPurgeInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			purged, err := PurgeExpiredTrash()
+			if err != nil {
+				log.Printf("Trash purge failed: %s", err.Error())
+				continue
+			}
+			if purged > 0 {
+				log.Printf("Purged %d transactions from the trash\n", purged)
+			}
+		}
+	}()
+}
+
+// PurgeExpiredTrash permanently removes transactions deleted more than TrashRetention ago.
+// Their balance effects were reversed when they were deleted, so balances do not change.
+func PurgeExpiredTrash() (int64, error) {
+	result, err := mgm.Coll(&db.Transaction{}).DeleteMany(mgm.Ctx(), bson.M{
+		"deleted_at": bson.M{"$lt": time.Now().Add(-TrashRetention)},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
@@ -6,6 +6,8 @@ import (
 	"github.com/kamva/mgm/v3"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -95,8 +97,8 @@ func UpdateUserProfilePicture(userId primitive.ObjectID, profilePicUrl string) e
 	return nil
 }
 
-// UpdateUserProfile updates user's name only (email is not editable)
-func UpdateUserProfile(userId primitive.ObjectID, name string) (*db.User, error) {
+// UpdateUserProfile updates user's name and timezone (email is not editable)
+func UpdateUserProfile(userId primitive.ObjectID, name, timezone string) (*db.User, error) {
 	user, err := FindUserById(userId)
 	if err != nil {
 		return nil, err
@@ -106,6 +108,9 @@ func UpdateUserProfile(userId primitive.ObjectID, name string) (*db.User, error)
 	if name != "" {
 		user.Name = name
 	}
+	if timezone != "" {
+		user.Timezone = timezone
+	}
 
 	err = mgm.Coll(user).Update(user)
 	if err != nil {
@@ -192,3 +197,27 @@ func GetUserWithProfilePictureURL(userId primitive.ObjectID, urlExpirationMinute
 
 	return user, nil
 }
+
+// EnsureUserIndexes creates the indexes for users and what hangs off them: unique emails,
+// and tokens and push subscriptions looked up by user
+func EnsureUserIndexes() error {
+	_, err := mgm.Coll(&db.User{}).Indexes().CreateOne(mgm.Ctx(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "email", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = mgm.Coll(&db.Token{}).Indexes().CreateOne(mgm.Ctx(), mongo.IndexModel{
+		Keys: bson.D{{Key: "user", Value: 1}, {Key: "type", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = mgm.Coll(&db.PushSubscription{}).Indexes().CreateOne(mgm.Ctx(), mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}},
+	})
+	return err
+}